@@ -3,9 +3,13 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"gopkg.in/yaml.v3"
@@ -18,6 +22,12 @@ func main() {
 	log.SetFlags(log.Ldate | log.Ltime | log.LUTC)
 	log.SetPrefix("openapi-to-kcl: ")
 
+	// Subcommands come before any flag
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		runBundle(os.Args[2:])
+		return
+	}
+
 	// Define command-line flags
 	schemaFile := flag.String("schema", "", "Path to the schema file (OpenAPI or JSON Schema)")
 	outDir := flag.String("out", "", "Output directory for the generated KCL schemas")
@@ -25,6 +35,16 @@ func main() {
 	skipRemote := flag.Bool("skip-remote", false, "Skip remote references during flattening")
 	maxDepth := flag.Int("max-depth", 100, "Maximum depth for reference resolution")
 	packageName := flag.String("package", "schema", "Package name for the generated KCL schemas")
+	operations := flag.Bool("operations", false, "Also generate schemas for operation request bodies, responses and parameters")
+	maxEnumLiterals := flag.Int("max-enum-literals", 0, "Enums with more values are validated with an \"in\" check instead of literal types (0 means no limit)")
+	rootDefinitions := flag.String("root-definitions", "", "Comma-separated JSON Schema $defs/definitions keys to generate, with the definitions they reference, instead of the document root")
+	openness := flag.String("openness", "strict", "Whether schemas accept undeclared keys: strict (only when explicitly allowed) or spec (unless additionalProperties or unevaluatedProperties is false)")
+	catalogFile := flag.String("catalog", "", "Catalog file mapping remote URIs, URI prefixes and $ids to local mirrors")
+	offline := flag.Bool("offline", false, "Fail on remote references that are not in the catalog instead of fetching them")
+	rootDir := flag.String("root-dir", "", "Directory file references must stay inside (default: the directory of the schema file)")
+	allowOutsideRoot := flag.Bool("allow-outside-root", false, "Allow file references outside the root directory, for trusted inputs")
+	var fetch fetchFlags
+	fetch.register(flag.CommandLine)
 	flag.Parse()
 
 	// Ensure a schema file is provided
@@ -32,12 +52,161 @@ func main() {
 		log.Fatal("Missing required -schema flag. Usage:\n  openapi-to-kcl -schema schema.json -out output_dir")
 	}
 
+	opennessPolicy, err := openapikcl.ParseOpennessPolicy(*openness)
+	if err != nil {
+		log.Fatalf("Invalid -openness flag: %v", err)
+	}
+
 	// Process the schema file
-	ProcessSchema(*schemaFile, *outDir, *skipFlatten, *skipRemote, *maxDepth, *packageName)
+	ProcessSchema(*schemaFile, *outDir, *skipFlatten, *skipRemote, *maxDepth, *packageName, openapikcl.GenerateOptions{
+		OperationSchemas: *operations,
+		MaxEnumLiterals:  *maxEnumLiterals,
+		Openness:         opennessPolicy,
+		RootDefinitions:  splitList(*rootDefinitions),
+		Flatten: openapikcl.FlattenOptions{
+			Catalog:          loadCatalog(*catalogFile),
+			Offline:          *offline,
+			Fetcher:          fetch.fetcher(),
+			RootDir:          *rootDir,
+			AllowOutsideRoot: *allowOutsideRoot,
+		},
+	})
+}
+
+// loadCatalog loads the catalog file given by the -catalog flag, if any
+func loadCatalog(path string) *openapikcl.Catalog {
+	if path == "" {
+		return nil
+	}
+	catalog, err := openapikcl.LoadCatalog(path)
+	if err != nil {
+		log.Fatalf("Invalid -catalog flag: %v", err)
+	}
+	return catalog
+}
+
+// fetchFlags configures how remote references are fetched
+type fetchFlags struct {
+	headers      headerFlag
+	timeout      time.Duration
+	maxSize      int64
+	allowedHosts string
+}
+
+// register adds the fetch flags to a flag set
+func (f *fetchFlags) register(flags *flag.FlagSet) {
+	flags.Var(&f.headers, "header", "Header sent when fetching remote references, as \"Name: value\" (repeatable)")
+	flags.DurationVar(&f.timeout, "fetch-timeout", openapikcl.DefaultFetchTimeout, "Timeout for fetching each remote reference")
+	flags.Int64Var(&f.maxSize, "max-fetch-size", openapikcl.DefaultMaxBodySize, "Maximum size in bytes of a fetched remote document")
+	flags.StringVar(&f.allowedHosts, "allowed-hosts", "", "Comma-separated hosts remote references may be fetched from (default: any host)")
+}
+
+// fetcher returns the fetcher configured by the flags
+func (f *fetchFlags) fetcher() openapikcl.Fetcher {
+	return &openapikcl.HTTPFetcher{
+		Headers:      http.Header(f.headers),
+		Timeout:      f.timeout,
+		MaxBodySize:  f.maxSize,
+		AllowedHosts: splitList(f.allowedHosts),
+	}
+}
+
+// headerFlag collects repeated -header flags
+type headerFlag http.Header
+
+func (h *headerFlag) String() string {
+	var headers []string
+	for name, values := range *h {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", value)
+	}
+	if *h == nil {
+		*h = headerFlag{}
+	}
+	http.Header(*h).Add(strings.TrimSpace(name), strings.TrimSpace(val))
+	return nil
+}
+
+// runBundle writes the flattened OpenAPI document, so the spec the generator sees can be inspected or used elsewhere
+func runBundle(args []string) {
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	schemaFile := flags.String("schema", "", "Path to the OpenAPI spec")
+	outFile := flags.String("out", "", "Output file for the bundled spec (default: standard output)")
+	mode := flags.String("mode", "bundle", "bundle (add schemas of other documents as components) or dereference (inline every reference)")
+	format := flags.String("format", "", "Output format: json or yaml (default: from the -out extension, else yaml)")
+	skipRemote := flags.Bool("skip-remote", false, "Skip remote references during flattening")
+	maxDepth := flags.Int("max-depth", 100, "Maximum depth for reference resolution")
+	catalogFile := flags.String("catalog", "", "Catalog file mapping remote URIs, URI prefixes and $ids to local mirrors")
+	offline := flags.Bool("offline", false, "Fail on remote references that are not in the catalog instead of fetching them")
+	rootDir := flags.String("root-dir", "", "Directory file references must stay inside (default: the directory of the spec)")
+	allowOutsideRoot := flags.Bool("allow-outside-root", false, "Allow file references outside the root directory, for trusted inputs")
+	var fetch fetchFlags
+	fetch.register(flags)
+	flags.Parse(args)
+
+	if *schemaFile == "" {
+		log.Fatal("Missing required -schema flag. Usage:\n  openapi-to-kcl bundle -schema openapi.yaml -out bundled.yaml")
+	}
+
+	bundleMode, err := openapikcl.ParseBundleMode(*mode)
+	if err != nil {
+		log.Fatalf("Invalid -mode flag: %v", err)
+	}
+	encoding, err := openapikcl.ParseEncoding(*format, *outFile)
+	if err != nil {
+		log.Fatalf("Invalid -format flag: %v", err)
+	}
+
+	doc, err := openapikcl.BundleOpenAPISchema(*schemaFile, bundleMode, openapikcl.LoadOptions{
+		SkipRemote:       *skipRemote,
+		MaxDepth:         *maxDepth,
+		Catalog:          loadCatalog(*catalogFile),
+		Offline:          *offline,
+		Fetcher:          fetch.fetcher(),
+		RootDir:          *rootDir,
+		AllowOutsideRoot: *allowOutsideRoot,
+	})
+	if err != nil {
+		log.Fatalf("Failed to bundle spec: %v", err)
+	}
+
+	data, err := openapikcl.MarshalSpec(doc, encoding)
+	if err != nil {
+		log.Fatalf("Failed to write bundled spec: %v", err)
+	}
+
+	if *outFile == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*outFile, data, 0644); err != nil {
+		log.Fatalf("Failed to write bundled spec: %v", err)
+	}
+	log.Printf("Bundled spec written to %s", *outFile)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // processSchema handles schema file conversion (either OpenAPI or JSON Schema)
-func ProcessSchema(schemaFile, outDir string, skipFlatten, skipRemote bool, maxDepth int, packageName string) {
+func ProcessSchema(schemaFile, outDir string, skipFlatten, skipRemote bool, maxDepth int, packageName string, genOpts openapikcl.GenerateOptions) {
 	log.Printf("Processing schema from %s", schemaFile)
 
 	// Read the schema file
@@ -61,9 +230,14 @@ func ProcessSchema(schemaFile, outDir string, skipFlatten, skipRemote bool, maxD
 
 	// Attempt to load as OpenAPI
 	doc, version, err = openapikcl.LoadOpenAPISchema(schemaFile, openapikcl.LoadOptions{
-		FlattenSpec: !skipFlatten,
-		SkipRemote:  skipRemote,
-		MaxDepth:    maxDepth,
+		FlattenSpec:      !skipFlatten,
+		SkipRemote:       skipRemote,
+		MaxDepth:         maxDepth,
+		Catalog:          genOpts.Flatten.Catalog,
+		Offline:          genOpts.Flatten.Offline,
+		Fetcher:          genOpts.Flatten.Fetcher,
+		RootDir:          genOpts.Flatten.RootDir,
+		AllowOutsideRoot: genOpts.Flatten.AllowOutsideRoot,
 	})
 
 	// Create output directory if it doesn't exist
@@ -73,8 +247,13 @@ func ProcessSchema(schemaFile, outDir string, skipFlatten, skipRemote bool, maxD
 		}
 	}
 
+	// Relative JSON Schema references resolve against the input file
+	genOpts.SourcePath = schemaFile
+	genOpts.Flatten.SkipRemote = skipRemote
+	genOpts.Flatten.MaxDepth = maxDepth
+
 	// Generate KCL schemas based on detected schema type
-	err = openapikcl.GenerateKCLSchemas(doc, outDir, packageName, version, rawSchema)
+	err = openapikcl.GenerateKCLSchemasWithOptions(doc, outDir, packageName, version, rawSchema, genOpts)
 	if err != nil {
 		log.Fatalf("Failed to generate KCL schemas: %v", err)
 	}
@@ -0,0 +1,481 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Every allOf member applies to a value, so merging members intersects their constraints: the strictest
+// bounds win, all patterns and multipleOf values apply, enums keep the values they share and required
+// properties are combined. A property defined by several members, or by a member and the schema itself,
+// is merged the same way. Members contradicting each other, e.g. with a minimum above a maximum or with
+// enums sharing no value, admit no value at all and are reported as errors. Both the OpenAPI and the JSON
+// Schema generator read constraints into schemaConstraints, so they merge and render them alike.
+
+// numericBound is an inclusive or exclusive minimum or maximum
+type numericBound struct {
+	value     float64
+	exclusive bool
+}
+
+// schemaConstraints holds the validation keywords of a schema, independent of the front end it comes from
+type schemaConstraints struct {
+	types         []string // nil allows every type
+	minimum       *numericBound
+	maximum       *numericBound
+	multipleOf    []float64
+	minLength     *uint64
+	maxLength     *uint64
+	minItems      *uint64
+	maxItems      *uint64
+	minProperties *uint64
+	maxProperties *uint64
+	uniqueItems   bool
+	patterns      []string
+	enum          []interface{} // nil allows every value
+	required      []string
+}
+
+// openAPIConstraints reads the constraints of an OpenAPI schema, ignoring its allOf members
+func openAPIConstraints(schema *openapi3.Schema) *schemaConstraints {
+	c := &schemaConstraints{
+		maxLength:     schema.MaxLength,
+		maxItems:      schema.MaxItems,
+		maxProperties: schema.MaxProps,
+		uniqueItems:   schema.UniqueItems,
+		required:      appendUnique(nil, schema.Required...),
+	}
+	if schema.Type != nil && len(*schema.Type) > 0 {
+		c.types = append([]string(nil), *schema.Type...)
+	}
+	if schema.Min != nil {
+		c.minimum = &numericBound{value: *schema.Min, exclusive: schema.ExclusiveMin}
+	}
+	if schema.Max != nil {
+		c.maximum = &numericBound{value: *schema.Max, exclusive: schema.ExclusiveMax}
+	}
+	if schema.MultipleOf != nil {
+		c.multipleOf = []float64{*schema.MultipleOf}
+	}
+	c.minLength = positiveCount(schema.MinLength)
+	c.minItems = positiveCount(schema.MinItems)
+	c.minProperties = positiveCount(schema.MinProps)
+	if schema.Pattern != "" {
+		c.patterns = []string{schema.Pattern}
+	}
+	if len(schema.Enum) > 0 {
+		c.enum = append([]interface{}(nil), schema.Enum...)
+	}
+	return c
+}
+
+// jsonConstraints reads the constraints of a compiled JSON Schema, ignoring its allOf members.
+// A const outside the enum of the schema is an error, and the enum is kept.
+func jsonConstraints(schema *jsonschema.Schema) (*schemaConstraints, error) {
+	c := &schemaConstraints{
+		uniqueItems: schema.UniqueItems,
+		required:    appendUnique(nil, schema.Required...),
+	}
+	if len(schema.Types) > 0 {
+		c.types = append([]string(nil), schema.Types...)
+	}
+	if schema.Minimum != nil {
+		value, _ := schema.Minimum.Float64()
+		c.minimum = &numericBound{value: value}
+	}
+	if schema.ExclusiveMinimum != nil {
+		value, _ := schema.ExclusiveMinimum.Float64()
+		c.minimum = stricterBound(c.minimum, &numericBound{value: value, exclusive: true}, true)
+	}
+	if schema.Maximum != nil {
+		value, _ := schema.Maximum.Float64()
+		c.maximum = &numericBound{value: value}
+	}
+	if schema.ExclusiveMaximum != nil {
+		value, _ := schema.ExclusiveMaximum.Float64()
+		c.maximum = stricterBound(c.maximum, &numericBound{value: value, exclusive: true}, false)
+	}
+	if schema.MultipleOf != nil {
+		value, _ := schema.MultipleOf.Float64()
+		c.multipleOf = []float64{value}
+	}
+	c.minLength = positiveCount(uint64(max(schema.MinLength, 0)))
+	c.maxLength = specifiedCount(schema.MaxLength)
+	c.minItems = positiveCount(uint64(max(schema.MinItems, 0)))
+	c.maxItems = specifiedCount(schema.MaxItems)
+	c.minProperties = positiveCount(uint64(max(schema.MinProperties, 0)))
+	c.maxProperties = specifiedCount(schema.MaxProperties)
+	if schema.Pattern != nil {
+		c.patterns = []string{schema.Pattern.String()}
+	}
+	if pattern, ok := jsonFormatPatterns[schema.Format]; ok && containsType(schema.Types, "string") {
+		c.patterns = append(c.patterns, pattern)
+	}
+	if len(schema.Enum) > 0 {
+		c.enum = append([]interface{}(nil), schema.Enum...)
+	}
+	if len(schema.Constant) > 0 {
+		// A constant is an enum of one value, and both apply if a schema has both
+		constant := []interface{}{schema.Constant[0]}
+		if c.enum == nil {
+			c.enum = constant
+		} else if enum := intersectEnums(c.enum, constant); len(enum) > 0 {
+			c.enum = enum
+		} else {
+			return c, fmt.Errorf("const %s is not in enum [%s]", formatEnumValues(constant), formatEnumValues(c.enum))
+		}
+	}
+	return c, nil
+}
+
+// positiveCount returns a pointer to a lower bound on a count, or nil if it does not constrain anything
+func positiveCount(count uint64) *uint64 {
+	if count == 0 {
+		return nil
+	}
+	return &count
+}
+
+// specifiedCount returns a pointer to an upper bound on a count of a compiled JSON Schema, which is -1 if not specified
+func specifiedCount(count int) *uint64 {
+	if count < 0 {
+		return nil
+	}
+	value := uint64(count)
+	return &value
+}
+
+// mergeOpenAPIConstraints returns the constraints of an OpenAPI schema intersected with those of its allOf members
+func mergeOpenAPIConstraints(schema *openapi3.Schema) (*schemaConstraints, error) {
+	return mergeOpenAPIMembers(schema, make(map[*openapi3.Schema]bool))
+}
+
+// mergeOpenAPIMembers intersects the constraints of a schema and its allOf members, skipping schemas being merged
+func mergeOpenAPIMembers(schema *openapi3.Schema, visiting map[*openapi3.Schema]bool) (*schemaConstraints, error) {
+	c := openAPIConstraints(schema)
+	visiting[schema] = true
+	defer delete(visiting, schema)
+	for _, member := range schema.AllOf {
+		if member == nil || member.Value == nil || visiting[member.Value] {
+			continue
+		}
+		memberConstraints, err := mergeOpenAPIMembers(member.Value, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.intersect(memberConstraints); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// mergeJSONConstraints returns the constraints of a compiled JSON Schema intersected with those of
+// its allOf members, following references
+func mergeJSONConstraints(schema *jsonschema.Schema) (*schemaConstraints, error) {
+	return mergeJSONMembers(schema, make(map[*jsonschema.Schema]bool))
+}
+
+// mergeJSONMembers intersects the constraints of a schema and its allOf members, skipping schemas being merged
+func mergeJSONMembers(schema *jsonschema.Schema, visiting map[*jsonschema.Schema]bool) (*schemaConstraints, error) {
+	c, err := jsonConstraints(schema)
+	if err != nil {
+		return nil, err
+	}
+	visiting[schema] = true
+	defer delete(visiting, schema)
+
+	members := schema.AllOf
+	if schema.Ref != nil {
+		members = append([]*jsonschema.Schema{schema.Ref}, members...)
+	}
+	for _, member := range members {
+		if member == nil || visiting[member] {
+			continue
+		}
+		memberConstraints, err := mergeJSONMembers(member, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.intersect(memberConstraints); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// intersect narrows c to the values that also satisfy other, and returns an error if no value satisfies both
+func (c *schemaConstraints) intersect(other *schemaConstraints) error {
+	if c.types != nil && other.types != nil {
+		types := intersectTypes(c.types, other.types)
+		if len(types) == 0 {
+			return fmt.Errorf("types %s and %s have nothing in common", strings.Join(c.types, ", "), strings.Join(other.types, ", "))
+		}
+		c.types = types
+	} else if other.types != nil {
+		c.types = append([]string(nil), other.types...)
+	}
+
+	c.minimum = stricterBound(c.minimum, other.minimum, true)
+	c.maximum = stricterBound(c.maximum, other.maximum, false)
+	for _, multipleOf := range other.multipleOf {
+		if !containsFloat(c.multipleOf, multipleOf) {
+			c.multipleOf = append(c.multipleOf, multipleOf)
+		}
+	}
+	c.minLength = largerCount(c.minLength, other.minLength)
+	c.maxLength = smallerCount(c.maxLength, other.maxLength)
+	c.minItems = largerCount(c.minItems, other.minItems)
+	c.maxItems = smallerCount(c.maxItems, other.maxItems)
+	c.minProperties = largerCount(c.minProperties, other.minProperties)
+	c.maxProperties = smallerCount(c.maxProperties, other.maxProperties)
+	c.uniqueItems = c.uniqueItems || other.uniqueItems
+	c.patterns = appendUnique(c.patterns, other.patterns...)
+	c.required = appendUnique(c.required, other.required...)
+
+	if c.enum != nil && other.enum != nil {
+		enum := intersectEnums(c.enum, other.enum)
+		if len(enum) == 0 {
+			return fmt.Errorf("enums [%s] and [%s] have no value in common", formatEnumValues(c.enum), formatEnumValues(other.enum))
+		}
+		c.enum = enum
+	} else if other.enum != nil {
+		c.enum = append([]interface{}(nil), other.enum...)
+	}
+
+	return c.validate()
+}
+
+// validate returns an error if the bounds of c admit no value
+func (c *schemaConstraints) validate() error {
+	if c.minimum != nil && c.maximum != nil {
+		if c.minimum.value > c.maximum.value ||
+			(c.minimum.value == c.maximum.value && (c.minimum.exclusive || c.maximum.exclusive)) {
+			return fmt.Errorf("minimum %v is greater than maximum %v", c.minimum.value, c.maximum.value)
+		}
+	}
+	counts := []struct {
+		name     string
+		min, max *uint64
+	}{
+		{"Length", c.minLength, c.maxLength},
+		{"Items", c.minItems, c.maxItems},
+		{"Properties", c.minProperties, c.maxProperties},
+	}
+	for _, count := range counts {
+		if count.min != nil && count.max != nil && *count.min > *count.max {
+			return fmt.Errorf("min%s %d is greater than max%s %d", count.name, *count.min, count.name, *count.max)
+		}
+	}
+	return nil
+}
+
+// checks renders the constraints as KCL check expressions on field
+func (c *schemaConstraints) checks(field string) []string {
+	var checks []string
+	stringCheck := c.typeGuard(field, "string")
+	if c.minLength != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) >= %d", field, *c.minLength), stringCheck))
+	}
+	if c.maxLength != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) <= %d", field, *c.maxLength), stringCheck))
+	}
+	for _, pattern := range c.patterns {
+		checks = append(checks, guardType(fmt.Sprintf("regex.match(%s, r\"%s\")", field, pattern), stringCheck))
+	}
+	numberCheck := c.typeGuard(field, "number", "integer")
+	if c.minimum != nil {
+		operator := ">="
+		if c.minimum.exclusive {
+			operator = ">"
+		}
+		checks = append(checks, guardType(fmt.Sprintf("%s %s %v", field, operator, c.minimum.value), numberCheck))
+	}
+	if c.maximum != nil {
+		operator := "<="
+		if c.maximum.exclusive {
+			operator = "<"
+		}
+		checks = append(checks, guardType(fmt.Sprintf("%s %s %v", field, operator, c.maximum.value), numberCheck))
+	}
+	for _, multipleOf := range c.multipleOf {
+		checks = append(checks, guardType(fmt.Sprintf("%s %% %v == 0", field, multipleOf), numberCheck))
+	}
+	arrayCheck := c.typeGuard(field, "array")
+	if c.minItems != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) >= %d", field, *c.minItems), arrayCheck))
+	}
+	if c.maxItems != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) <= %d", field, *c.maxItems), arrayCheck))
+	}
+	if c.uniqueItems {
+		checks = append(checks, guardType(fmt.Sprintf("isunique(%s)", field), arrayCheck))
+	}
+	objectCheck := c.typeGuard(field, "object")
+	if c.minProperties != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) >= %d", field, *c.minProperties), objectCheck))
+	}
+	if c.maxProperties != nil {
+		checks = append(checks, guardType(fmt.Sprintf("len(%s) <= %d", field, *c.maxProperties), objectCheck))
+	}
+	if len(c.enum) > 0 {
+		checks = append(checks, fmt.Sprintf("%s in [%s]", field, formatEnumValues(c.enum)))
+	}
+	return checks
+}
+
+// typeGuard returns the type check a value must pass for the constraints of the given types to apply,
+// or "" when the value cannot have any other type. The first type stands for all of them.
+func (c *schemaConstraints) typeGuard(field string, types ...string) string {
+	var own []string
+	others := false
+	for _, t := range c.types {
+		switch {
+		case t == "null":
+			// None is guarded separately
+		case contains(types, t):
+			own = append(own, t)
+		default:
+			others = true
+		}
+	}
+	if !others {
+		return ""
+	}
+	if len(own) != 1 {
+		own = types[:1]
+	}
+	return typeCheck(field, own)
+}
+
+// guardType applies a check only to values passing the type check, if there is one
+func guardType(check, condition string) string {
+	if condition == "" {
+		return check
+	}
+	return fmt.Sprintf("(%s if %s else True)", check, condition)
+}
+
+// kclType returns the KCL type of the values the constraints admit, or "any" if they admit several types
+func (c *schemaConstraints) kclType() string {
+	if len(c.types) != 1 {
+		return "any"
+	}
+	switch c.types[0] {
+	case "array":
+		return "[any]"
+	case "object":
+		return "{str:any}"
+	default:
+		return ConvertTypeToKCL(c.types[0], "")
+	}
+}
+
+// intersectTypes returns the types in both lists. Integers are numbers, so integer and number intersect as integer.
+func intersectTypes(a, b []string) []string {
+	var types []string
+	for _, t := range a {
+		switch {
+		case contains(b, t):
+			types = appendUnique(types, t)
+		case t == "integer" && contains(b, "number"), t == "number" && contains(b, "integer"):
+			types = appendUnique(types, "integer")
+		}
+	}
+	return types
+}
+
+// intersectEnums returns the values of a that b also lists, in the order of a
+func intersectEnums(a, b []interface{}) []interface{} {
+	keys := make(map[string]bool, len(b))
+	for _, value := range b {
+		keys[enumKey(value)] = true
+	}
+	enum := []interface{}{}
+	for _, value := range a {
+		if keys[enumKey(value)] {
+			enum = append(enum, value)
+		}
+	}
+	return enum
+}
+
+// enumKey identifies an enum value by its JSON encoding, so equal numbers of different Go types match
+func enumKey(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+	return string(data)
+}
+
+// formatEnumValues formats enum values as KCL literals separated by commas
+func formatEnumValues(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, v := range enum {
+		switch value := v.(type) {
+		case nil:
+			values[i] = "None"
+		case string:
+			values[i] = fmt.Sprintf("\"%s\"", value)
+		case bool:
+			// Use KCL-style booleans
+			if value {
+				values[i] = "True"
+			} else {
+				values[i] = "False"
+			}
+		default:
+			values[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// stricterBound returns the stricter of two minimums, or of two maximums if isMinimum is false.
+// At the same value an exclusive bound is stricter.
+func stricterBound(a, b *numericBound, isMinimum bool) *numericBound {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.value == b.value {
+		return &numericBound{value: a.value, exclusive: a.exclusive || b.exclusive}
+	}
+	if (b.value > a.value) == isMinimum {
+		return b
+	}
+	return a
+}
+
+// largerCount returns the larger of two lower bounds on a count
+func largerCount(a, b *uint64) *uint64 {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
+
+// smallerCount returns the smaller of two upper bounds on a count
+func smallerCount(a, b *uint64) *uint64 {
+	if a == nil || (b != nil && *b < *a) {
+		return b
+	}
+	return a
+}
+
+// containsFloat reports whether values contains value
+func containsFloat(values []float64, value float64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
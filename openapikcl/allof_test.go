@@ -0,0 +1,254 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntersectConstraints(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	count := func(v uint64) *uint64 { return &v }
+
+	tests := []struct {
+		name    string
+		members []*openapi3.Schema
+		checks  []string
+		err     string
+	}{
+		{
+			name: "strictest bounds",
+			members: []*openapi3.Schema{
+				{MinLength: 3, MaxLength: count(20)},
+				{MinLength: 1, MaxLength: count(10)},
+			},
+			checks: []string{"len(x) >= 3", "len(x) <= 10"},
+		},
+		{
+			name: "exclusive bound at the same value",
+			members: []*openapi3.Schema{
+				{Min: float(0), Max: float(10)},
+				{Min: float(0), ExclusiveMin: true, Max: float(20)},
+			},
+			checks: []string{"x > 0", "x <= 10"},
+		},
+		{
+			name: "patterns and multiples all apply",
+			members: []*openapi3.Schema{
+				{Pattern: "^a", MultipleOf: float(2)},
+				{Pattern: "b$", MultipleOf: float(3)},
+				{Pattern: "^a"},
+			},
+			checks: []string{`regex.match(x, r"^a")`, `regex.match(x, r"b$")`, "x % 2 == 0", "x % 3 == 0"},
+		},
+		{
+			name: "enums keep shared values",
+			members: []*openapi3.Schema{
+				{Enum: []interface{}{"a", "b", "c"}},
+				{Enum: []interface{}{"c", "b", "d"}},
+			},
+			checks: []string{`x in ["b", "c"]`},
+		},
+		{
+			name: "integer is a number",
+			members: []*openapi3.Schema{
+				{Type: &openapi3.Types{"number"}, Enum: []interface{}{1, 2.5}},
+				{Type: &openapi3.Types{"integer"}, Enum: []interface{}{1.0, 3}},
+			},
+			checks: []string{"x in [1]"},
+		},
+		{
+			name: "null enum member",
+			members: []*openapi3.Schema{
+				{Enum: []interface{}{"a", nil}},
+			},
+			checks: []string{`x in ["a", None]`},
+		},
+		{
+			name: "minimum above maximum",
+			members: []*openapi3.Schema{
+				{Min: float(10)},
+				{Max: float(5)},
+			},
+			err: "minimum 10 is greater than maximum 5",
+		},
+		{
+			name: "exclusive bounds at the same value",
+			members: []*openapi3.Schema{
+				{Min: float(5)},
+				{Max: float(5), ExclusiveMax: true},
+			},
+			err: "minimum 5 is greater than maximum 5",
+		},
+		{
+			name: "minLength above maxLength",
+			members: []*openapi3.Schema{
+				{MinLength: 5},
+				{MaxLength: count(3)},
+			},
+			err: "minLength 5 is greater than maxLength 3",
+		},
+		{
+			name: "disjoint enums",
+			members: []*openapi3.Schema{
+				{Enum: []interface{}{"a", "b"}},
+				{Enum: []interface{}{"c"}},
+			},
+			err: `enums ["a", "b"] and ["c"] have no value in common`,
+		},
+		{
+			name: "disjoint types",
+			members: []*openapi3.Schema{
+				{Type: &openapi3.Types{"string"}},
+				{Type: &openapi3.Types{"boolean"}},
+			},
+			err: "types string and boolean have nothing in common",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := &openapi3.Schema{}
+			for _, member := range tc.members {
+				schema.AllOf = append(schema.AllOf, openapi3.NewSchemaRef("", member))
+			}
+
+			constraints, err := mergeOpenAPIConstraints(schema)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.checks, constraints.checks("x"))
+		})
+	}
+}
+
+func TestGenerateAllOfConstraints(t *testing.T) {
+	tempDir := t.TempDir()
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/allof_constraints.yaml", LoadOptions{})
+	require.NoError(t, err)
+	require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "Item.k"))
+	require.NoError(t, err)
+	item := string(content)
+
+	assert.Contains(t, item, "size: int")
+	assert.Contains(t, item, "size >= 10")
+	assert.Contains(t, item, "size <= 50")
+	assert.NotContains(t, item, "size <= 100")
+	// Enums the members share are typed as literals
+	assert.Contains(t, item, `color?: "green" | "blue"`)
+	assert.NotContains(t, item, "color in")
+	assert.Contains(t, item, "code: Code")
+	assert.Contains(t, item, "len(code) >= 2")
+	assert.Contains(t, item, "len(code) <= 10")
+	assert.Contains(t, item, `regex.match(code, r"^[A-Z]+$")`)
+	assert.Contains(t, item, `regex.match(code, r"^[A-Z]{2}")`)
+
+	// Primitive components are type aliases, their constraints are checked where they are used
+	content, err = os.ReadFile(filepath.Join(tempDir, "Code.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "type Code = str")
+	assert.NotContains(t, string(content), "schema Code")
+
+	// Contradicting members fail the generation
+	doc.Components.Schemas["Limited"].Value.Properties["size"].Value.Min = openapi3.Float64Ptr(200)
+	err = GenerateKCLSchemas(doc, t.TempDir(), "test", version, nil)
+	assert.ErrorContains(t, err, "minimum 200 is greater than maximum 50")
+}
+
+func TestGenerateJSONAllOfConstraints(t *testing.T) {
+	rawSchema := map[string]interface{}{
+		"title": "Item",
+		"type":  "object",
+		"definitions": map[string]interface{}{
+			"code": map[string]interface{}{"type": "string", "minLength": 2, "pattern": `^[A-Z]+\d*$`},
+		},
+		"allOf": []interface{}{
+			map[string]interface{}{
+				"properties": map[string]interface{}{
+					"code":  map[string]interface{}{"$ref": "#/definitions/code"},
+					"count": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100},
+				},
+				"required": []interface{}{"code"},
+			},
+			map[string]interface{}{
+				"properties": map[string]interface{}{
+					"code":  map[string]interface{}{"maxLength": 10},
+					"count": map[string]interface{}{"exclusiveMaximum": 50},
+				},
+				"required": []interface{}{"count", "code"},
+			},
+		},
+	}
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{}))
+	content, err := os.ReadFile(filepath.Join(tempDir, "Item.k"))
+	require.NoError(t, err)
+	item := string(content)
+
+	assert.Contains(t, item, "code: Code")
+	assert.Contains(t, item, "count: int")
+	assert.Contains(t, item, "len(code) >= 2")
+	assert.Contains(t, item, "len(code) <= 10")
+	assert.Contains(t, item, `regex.match(code, r"^[A-Z]+\d*$")`)
+	assert.Contains(t, item, "count >= 0")
+	assert.Contains(t, item, "count < 50")
+
+	// Contradicting members fail the generation
+	rawSchema["allOf"].([]interface{})[1].(map[string]interface{})["properties"].(map[string]interface{})["count"] =
+		map[string]interface{}{"exclusiveMaximum": 0}
+	err = generateJSONSchemas(rawSchema, t.TempDir(), "test", GenerateOptions{})
+	assert.ErrorContains(t, err, "property count of Item: minimum 0 is greater than maximum 0")
+}
+
+func TestMergeJSONConstAndEnum(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		checks []string
+		err    string
+	}{
+		{
+			name:   "const in enum",
+			schema: `{"allOf": [{"const": "b", "enum": ["a", "b"]}]}`,
+			checks: []string{`x in ["b"]`},
+		},
+		{
+			name:   "const outside enum",
+			schema: `{"allOf": [{"const": "c", "enum": ["a", "b"]}]}`,
+			err:    `const "c" is not in enum ["a", "b"]`,
+		},
+		{
+			name:   "const and enum of different members",
+			schema: `{"allOf": [{"const": "c"}, {"enum": ["a", null]}]}`,
+			err:    `enums ["c"] and ["a", None] have no value in common`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			compiler := jsonschema.NewCompiler()
+			require.NoError(t, compiler.AddResource("schema.json", strings.NewReader(tc.schema)))
+			schema, err := compiler.Compile("schema.json")
+			require.NoError(t, err)
+
+			constraints, err := mergeJSONConstraints(schema)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.checks, constraints.checks("x"))
+		})
+	}
+}
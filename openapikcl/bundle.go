@@ -0,0 +1,79 @@
+package openapikcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+// A bundled document is the flattened document written back out, so it shows exactly what the
+// generator sees and can be handed to other tools. Bundling keeps the schemas of other documents as
+// components, dereferencing inlines every reference. OpenAPI 2.0 and 3.1 documents are written in the
+// OpenAPI 3.0 form they are converted to.
+
+// Encoding is the format a bundled document is written in
+type Encoding string
+
+const (
+	EncodingJSON Encoding = "json"
+	EncodingYAML Encoding = "yaml"
+)
+
+// ParseEncoding parses a format name. An empty name picks the format from the file extension of
+// path, defaulting to YAML.
+func ParseEncoding(name, path string) (Encoding, error) {
+	switch encoding := Encoding(strings.ToLower(name)); encoding {
+	case EncodingJSON, EncodingYAML:
+		return encoding, nil
+	case "yml":
+		return EncodingYAML, nil
+	case "":
+		if strings.ToLower(filepath.Ext(path)) == ".json" {
+			return EncodingJSON, nil
+		}
+		return EncodingYAML, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %q or %q", name, EncodingJSON, EncodingYAML)
+	}
+}
+
+// BundleOpenAPISchema loads an OpenAPI document and flattens it with the given mode
+func BundleOpenAPISchema(filePath string, mode BundleMode, opts LoadOptions) (*openapi3.T, error) {
+	opts.FlattenSpec = true
+	opts.Mode = mode
+	doc, _, err := LoadOpenAPISchema(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// MarshalSpec encodes an OpenAPI document as JSON or YAML
+func MarshalSpec(doc *openapi3.T, encoding Encoding) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON:
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode document as JSON: %w", err)
+		}
+		return append(data, '\n'), nil
+	case EncodingYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode document as YAML: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode document as YAML: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", encoding)
+	}
+}
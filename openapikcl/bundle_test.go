@@ -0,0 +1,115 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseBundleMode(t *testing.T) {
+	mode, err := ParseBundleMode("")
+	require.NoError(t, err)
+	assert.Equal(t, BundleModeBundle, mode)
+
+	mode, err = ParseBundleMode("dereference")
+	require.NoError(t, err)
+	assert.Equal(t, BundleModeDereference, mode)
+
+	_, err = ParseBundleMode("inline")
+	assert.Error(t, err)
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected Encoding
+	}{
+		{name: "json", expected: EncodingJSON},
+		{name: "YAML", expected: EncodingYAML},
+		{name: "yml", expected: EncodingYAML},
+		{path: "bundled.json", expected: EncodingJSON},
+		{path: "bundled.yaml", expected: EncodingYAML},
+		{path: "", expected: EncodingYAML},
+	}
+
+	for _, tc := range tests {
+		encoding, err := ParseEncoding(tc.name, tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, encoding, "name %q, path %q", tc.name, tc.path)
+	}
+
+	_, err := ParseEncoding("toml", "")
+	assert.Error(t, err)
+}
+
+func TestBundleOpenAPISchema(t *testing.T) {
+	doc, err := BundleOpenAPISchema("testdata/oas/input/split/openapi.yaml", BundleModeBundle, LoadOptions{MaxDepth: 10})
+	require.NoError(t, err)
+
+	// Schemas of other documents become components referenced by name
+	schemas := doc.Components.Schemas
+	assert.Equal(t, []string{"Id", "Owner", "Pet", "Tag"}, collectSchemas(schemas))
+	assert.Equal(t, "#/components/schemas/Id", schemas["Pet"].Value.Properties["id"].Ref)
+	assert.Equal(t, "#/components/schemas/Tag", schemas["Pet"].Value.Properties["tag"].Ref)
+	assert.Equal(t, "#/components/schemas/Pet", schemas["Owner"].Value.Properties["pet"].Ref)
+	assert.Equal(t, "#/components/schemas/Id", schemas["Owner"].Value.Properties["id"].Ref)
+	require.NotNil(t, schemas["Id"].Value.Min)
+	assert.Equal(t, 1.0, *schemas["Id"].Value.Min)
+}
+
+func TestDereferenceOpenAPISchema(t *testing.T) {
+	doc, err := BundleOpenAPISchema("testdata/oas/input/document.yaml", BundleModeDereference, LoadOptions{MaxDepth: 10})
+	require.NoError(t, err)
+
+	// Every reference is inlined
+	pathItem := doc.Paths.Value("/pets/{petId}")
+	assert.Empty(t, pathItem.Parameters[0].Ref)
+	assert.Equal(t, "petId", pathItem.Parameters[0].Value.Name)
+	assert.Empty(t, pathItem.Get.Responses.Value("default").Ref)
+	visit := pathItem.Put.RequestBody.Value.Content["application/json"].Schema
+	assert.Empty(t, visit.Ref)
+	assert.Contains(t, visit.Value.Properties, "reason")
+
+	// Only references closing a cycle are kept
+	doc, err = BundleOpenAPISchema("testdata/oas/input/recursive/openapi.yaml", BundleModeDereference, LoadOptions{MaxDepth: 10})
+	require.NoError(t, err)
+	schemas := doc.Components.Schemas
+	assert.Equal(t, "#/components/schemas/Node", schemas["Node"].Value.Properties["children"].Value.Items.Ref)
+	assert.Equal(t, "#/components/schemas/Category", schemas["Category"].Value.Properties["grandparent"].Ref)
+	assert.Empty(t, schemas["Even"].Value.Properties["next"].Ref)
+}
+
+func TestMarshalSpec(t *testing.T) {
+	doc, err := BundleOpenAPISchema("testdata/oas/input/split/openapi.yaml", BundleModeBundle, LoadOptions{MaxDepth: 10})
+	require.NoError(t, err)
+
+	for _, encoding := range []Encoding{EncodingJSON, EncodingYAML} {
+		t.Run(string(encoding), func(t *testing.T) {
+			data, err := MarshalSpec(doc, encoding)
+			require.NoError(t, err)
+
+			var raw map[string]interface{}
+			if encoding == EncodingJSON {
+				require.NoError(t, json.Unmarshal(data, &raw))
+			} else {
+				require.NoError(t, yaml.Unmarshal(data, &raw))
+			}
+			assert.Equal(t, "3.0.3", raw["openapi"])
+
+			// The bundled document stands on its own
+			loaded, err := openapi3.NewLoader().LoadFromData(data)
+			require.NoError(t, err)
+			require.NoError(t, loaded.Validate(openapi3.NewLoader().Context))
+			assert.Equal(t, "#/components/schemas/Tag", loaded.Components.Schemas["Pet"].Value.Properties["tag"].Ref)
+			assert.Equal(t, "integer", loaded.Components.Schemas["Id"].Value.Type.Slice()[0])
+		})
+	}
+
+	_, err = MarshalSpec(doc, "toml")
+	assert.Error(t, err)
+}
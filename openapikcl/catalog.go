@@ -0,0 +1,89 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A catalog maps remote documents to local mirrors, like an XML catalog, so references and JSON Schema
+// $ids resolve without network access. Each entry maps either a single URI or every URI starting with a
+// prefix to a local path, e.g.
+//
+//	entries:
+//	  - uri: https://json.schemastore.org/package.json
+//	    path: mirror/package.json
+//	  - prefix: https://schemas.example.com/
+//	    path: mirror/example/
+//
+// Relative paths resolve against the directory of the catalog file. The longest matching prefix wins,
+// and a URI entry wins over any prefix.
+
+// Catalog maps remote documents to local files
+type Catalog struct {
+	Entries []CatalogEntry `json:"entries" yaml:"entries"`
+}
+
+// CatalogEntry maps a URI, or every URI starting with a prefix, to a local path.
+// The rest of a URI matching a prefix is a path inside the directory of the entry.
+type CatalogEntry struct {
+	URI    string `json:"uri,omitempty" yaml:"uri,omitempty"`       // Exact URI or $id of a document
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"` // URI prefix mirrored in the directory Path
+	Path   string `json:"path" yaml:"path"`                         // Local file, or directory for a prefix
+}
+
+// LoadCatalog reads a JSON or YAML catalog file
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	// Try JSON first, then fall back to YAML
+	catalog := &Catalog{}
+	if err := json.Unmarshal(data, catalog); err != nil {
+		if err := yaml.Unmarshal(data, catalog); err != nil {
+			return nil, fmt.Errorf("failed to parse catalog %s: not a valid JSON or YAML document: %w", path, err)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	for i, entry := range catalog.Entries {
+		if (entry.URI == "") == (entry.Prefix == "") {
+			return nil, fmt.Errorf("catalog entry %d must have either a uri or a prefix", i)
+		}
+		if entry.Path == "" {
+			return nil, fmt.Errorf("catalog entry %d has no path", i)
+		}
+		if !filepath.IsAbs(entry.Path) {
+			catalog.Entries[i].Path = filepath.Join(dir, filepath.FromSlash(entry.Path))
+		}
+	}
+	return catalog, nil
+}
+
+// Lookup returns the local file a URI is mirrored at. The fragment of the URI is ignored.
+func (c *Catalog) Lookup(uri string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	uri, _, _ = strings.Cut(uri, "#")
+
+	var match *CatalogEntry
+	for i, entry := range c.Entries {
+		if entry.URI != "" && strings.TrimSuffix(entry.URI, "#") == uri {
+			return entry.Path, true
+		}
+		if entry.Prefix != "" && strings.HasPrefix(uri, entry.Prefix) && (match == nil || len(entry.Prefix) > len(match.Prefix)) {
+			match = &c.Entries[i]
+		}
+	}
+	if match == nil {
+		return "", false
+	}
+	return filepath.Join(match.Path, filepath.FromSlash(strings.TrimPrefix(uri, match.Prefix))), true
+}
@@ -0,0 +1,142 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	catalog, err := LoadCatalog("testdata/catalog/catalog.yaml")
+	require.NoError(t, err)
+	require.Len(t, catalog.Entries, 2)
+
+	tests := []struct {
+		uri      string
+		expected string
+		found    bool
+	}{
+		{uri: "https://schemas.example.com/pet.yaml#/Pet", expected: "testdata/catalog/mirror/example/pet.yaml", found: true},
+		{uri: "https://schemas.example.com/common/id.yaml", expected: "testdata/catalog/mirror/example/common/id.yaml", found: true},
+		{uri: "https://ids.example.com/tag", expected: "testdata/catalog/mirror/tag.json", found: true},
+		{uri: "https://ids.example.com/tag#", expected: "testdata/catalog/mirror/tag.json", found: true},
+		{uri: "https://ids.example.com/tags", found: false},
+		{uri: "https://unmapped.example.com/pet.yaml", found: false},
+	}
+	for _, tc := range tests {
+		path, found := catalog.Lookup(tc.uri)
+		assert.Equal(t, tc.found, found, tc.uri)
+		assert.Equal(t, filepath.FromSlash(tc.expected), path, tc.uri)
+	}
+
+	// A nil catalog maps nothing
+	var none *Catalog
+	_, found := none.Lookup("https://schemas.example.com/pet.yaml")
+	assert.False(t, found)
+}
+
+func TestCatalogLongestPrefix(t *testing.T) {
+	catalog := &Catalog{Entries: []CatalogEntry{
+		{Prefix: "https://schemas.example.com/", Path: "/mirror/all"},
+		{Prefix: "https://schemas.example.com/v2/", Path: "/mirror/v2"},
+	}}
+	path, found := catalog.Lookup("https://schemas.example.com/v2/pet.yaml")
+	require.True(t, found)
+	assert.Equal(t, filepath.FromSlash("/mirror/v2/pet.yaml"), path)
+}
+
+func TestLoadCatalogErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "catalog.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	_, err := LoadCatalog(write("entries:\n  - path: mirror\n"))
+	assert.ErrorContains(t, err, "either a uri or a prefix")
+
+	_, err = LoadCatalog(write("entries:\n  - uri: https://example.com/a\n    prefix: https://example.com/\n    path: mirror\n"))
+	assert.ErrorContains(t, err, "either a uri or a prefix")
+
+	_, err = LoadCatalog(write("entries:\n  - uri: https://example.com/a\n"))
+	assert.ErrorContains(t, err, "has no path")
+
+	_, err = LoadCatalog(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestFlattenWithCatalog(t *testing.T) {
+	catalog, err := LoadCatalog("testdata/catalog/catalog.yaml")
+	require.NoError(t, err)
+
+	// Refs inside a mirrored document resolve against its URL, and are mirrored in turn
+	doc, _, err := LoadOpenAPISchema("testdata/catalog/openapi.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+		Catalog:     catalog,
+		Offline:     true,
+	})
+	require.NoError(t, err)
+
+	pet := doc.Components.Schemas["Pet"]
+	require.NotNil(t, pet)
+	require.NotNil(t, pet.Value.Properties["id"].Value.Min)
+	assert.Equal(t, 1.0, *pet.Value.Properties["id"].Value.Min)
+}
+
+func TestFlattenOffline(t *testing.T) {
+	catalog, err := LoadCatalog("testdata/catalog/catalog.yaml")
+	require.NoError(t, err)
+
+	_, _, err = LoadOpenAPISchema("testdata/catalog/unmapped.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+		Catalog:     catalog,
+		Offline:     true,
+	})
+	assert.ErrorContains(t, err, "not in the catalog and offline mode is set")
+
+	// Offline mode fails even where remote references would be skipped
+	_, _, err = LoadOpenAPISchema("testdata/catalog/unmapped.yaml", LoadOptions{
+		FlattenSpec: true,
+		SkipRemote:  true,
+		MaxDepth:    10,
+		Offline:     true,
+	})
+	assert.ErrorContains(t, err, "offline mode is set")
+}
+
+func TestGenerateJSONWithCatalog(t *testing.T) {
+	catalog, err := LoadCatalog("testdata/catalog/catalog.yaml")
+	require.NoError(t, err)
+
+	inputPath := "testdata/catalog/schema.json"
+	data, err := os.ReadFile(inputPath)
+	require.NoError(t, err)
+	var rawSchema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &rawSchema))
+
+	// Both a mirrored URL and a mirrored $id resolve without network access
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{
+		SourcePath: inputPath,
+		Flatten:    FlattenOptions{Catalog: catalog, Offline: true},
+	}))
+
+	label, err := os.ReadFile(filepath.Join(tempDir, "Label.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(label), "id?: Id")
+	assert.Contains(t, string(label), "tag?: Tag")
+
+	// Without the catalog, offline mode rejects the remote references
+	err = generateJSONSchemas(rawSchema, t.TempDir(), "test", GenerateOptions{
+		SourcePath: inputPath,
+		Flatten:    FlattenOptions{Offline: true},
+	})
+	assert.ErrorContains(t, err, "offline mode is set")
+}
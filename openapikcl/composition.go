@@ -0,0 +1,465 @@
+package openapikcl
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// branchTypeSummary records which JSON types appear in one or more oneOf/anyOf branches.
+// It is shared by the JSON Schema and OpenAPI generators to pick a KCL type for a composition.
+type branchTypeSummary struct {
+	hasString  bool
+	hasNumber  bool
+	hasInteger bool
+	hasBoolean bool
+	hasArray   bool
+	hasObject  bool
+}
+
+// summarizeBranchTypes records the JSON types of a single branch
+func summarizeBranchTypes(types []string) branchTypeSummary {
+	return branchTypeSummary{
+		hasString:  containsType(types, "string"),
+		hasNumber:  containsType(types, "number"),
+		hasInteger: containsType(types, "integer"),
+		hasBoolean: containsType(types, "boolean"),
+		hasArray:   containsType(types, "array"),
+		hasObject:  containsType(types, "object"),
+	}
+}
+
+// merge combines the types of two summaries
+func (s branchTypeSummary) merge(other branchTypeSummary) branchTypeSummary {
+	return branchTypeSummary{
+		hasString:  s.hasString || other.hasString,
+		hasNumber:  s.hasNumber || other.hasNumber,
+		hasInteger: s.hasInteger || other.hasInteger,
+		hasBoolean: s.hasBoolean || other.hasBoolean,
+		hasArray:   s.hasArray || other.hasArray,
+		hasObject:  s.hasObject || other.hasObject,
+	}
+}
+
+// categoryCount counts the distinct type categories, treating integer and number as one numeric category
+func (s branchTypeSummary) categoryCount() int {
+	count := 0
+	for _, has := range []bool{s.hasString, s.hasNumber || s.hasInteger, s.hasBoolean, s.hasArray, s.hasObject} {
+		if has {
+			count++
+		}
+	}
+	return count
+}
+
+// kclType returns the KCL type of a summary with a single type category, or "any" otherwise
+func (s branchTypeSummary) kclType() string {
+	if s.categoryCount() != 1 {
+		return "any"
+	}
+	switch {
+	case s.hasString:
+		return "str"
+	case s.hasNumber || s.hasInteger:
+		return "float" // Use most permissive numeric type
+	case s.hasBoolean:
+		return "bool"
+	case s.hasArray:
+		return "[any]"
+	default:
+		return "{str:any}"
+	}
+}
+
+// unionOf joins KCL types into a union, dropping duplicates and moving None last.
+// A union containing any is any.
+func unionOf(types []string) string {
+	var members []string
+	hasNone := false
+	for _, kclType := range types {
+		switch {
+		case kclType == "any":
+			return "any"
+		case kclType == "None":
+			hasNone = true
+		case !contains(members, kclType):
+			members = append(members, kclType)
+		}
+	}
+	if hasNone {
+		members = append(members, "None")
+	}
+	if len(members) == 0 {
+		return "any"
+	}
+	return strings.Join(members, " | ")
+}
+
+// nullableType returns a KCL type that also accepts None
+func nullableType(kclType string) string {
+	if kclType == "any" || kclType == "None" || strings.HasSuffix(kclType, " | None") {
+		return kclType
+	}
+	return kclType + " | None"
+}
+
+// isNullableSchema reports whether an OpenAPI schema accepts null
+func isNullableSchema(schema *openapi3.Schema) bool {
+	return schema.Nullable || schema.Type.Includes("null")
+}
+
+// guardNone makes constraints on a field that may be None hold when it is None
+func guardNone(constraints []string, fieldName string) []string {
+	guarded := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		guarded[i] = fmt.Sprintf("%s if %s != None", constraint, fieldName)
+	}
+	return guarded
+}
+
+// compositionBranches returns the oneOf or anyOf branches of a schema, preferring oneOf
+func compositionBranches(schema *openapi3.Schema) openapi3.SchemaRefs {
+	if len(schema.OneOf) > 0 {
+		return schema.OneOf
+	}
+	return schema.AnyOf
+}
+
+// isUnionSchema reports whether a schema is only a oneOf/anyOf composition without its own properties
+func isUnionSchema(schema *openapi3.Schema) bool {
+	if schema == nil || len(compositionBranches(schema)) == 0 {
+		return false
+	}
+	return len(schema.Properties) == 0 && len(schema.AllOf) == 0
+}
+
+// compositionUnionType builds a KCL union type such as "Cat | Dog" or "str | int" from oneOf/anyOf branches.
+// Branches that have no KCL type of their own fall back to the common type of all branches.
+func compositionUnionType(fieldName string, branches openapi3.SchemaRefs, schemaName string, doc *openapi3.T, opts GenerateOptions) (string, []string) {
+	var members []string
+	var refTypes []string
+	seen := make(map[string]bool)
+	summary := branchTypeSummary{}
+	expressible := true
+
+	for _, branch := range branches {
+		if branch == nil || branch.Value == nil {
+			expressible = false
+			continue
+		}
+		if branch.Value.Type != nil {
+			summary = summary.merge(summarizeBranchTypes(*branch.Value.Type))
+		}
+
+		branchType, _, refType := generateFieldType(fieldName, branch, true, schemaName, doc, opts)
+		branchType = strings.TrimSuffix(branchType, "?")
+		if refType != "" {
+			refTypes = append(refTypes, refType)
+		}
+		if branchType == "any" || branchType == "dict" {
+			expressible = false
+			continue
+		}
+		if !seen[branchType] {
+			seen[branchType] = true
+			members = append(members, branchType)
+		}
+	}
+
+	if !expressible || len(members) == 0 {
+		return summary.kclType(), refTypes
+	}
+	return unionOf(members), refTypes
+}
+
+// oneOfCheck returns a check expression that holds when a value matches exactly one oneOf branch.
+// It returns an empty string when a branch refers to an object schema, which the union type of the value
+// already selects exactly one of.
+func oneOfCheck(fieldName string, branches openapi3.SchemaRefs, isRequired bool) string {
+	if len(branches) < 2 {
+		return ""
+	}
+
+	var predicates []string
+	for _, branch := range branches {
+		predicate, ok := branchPredicate(fieldName, branch)
+		if !ok {
+			return ""
+		}
+		predicates = append(predicates, predicate)
+	}
+	return exactlyOneCheck(fieldName, predicates, isRequired)
+}
+
+// exactlyOneCheck returns a check expression that holds when exactly one of the branch predicates on a field holds
+func exactlyOneCheck(fieldName string, predicates []string, isRequired bool) string {
+	check := exactlyOne(predicates)
+	if !isRequired {
+		check += fmt.Sprintf(" if %s != None", fieldName)
+	}
+	return check + fmt.Sprintf(", \"%s must match exactly one oneOf schema\"", fieldName)
+}
+
+// exactlyOne returns an expression that holds when exactly one of the predicates holds
+func exactlyOne(predicates []string) string {
+	return fmt.Sprintf("len([_m for _m in [%s] if _m]) == 1", strings.Join(predicates, ", "))
+}
+
+// branchPredicate builds a boolean KCL expression that holds when value matches a branch.
+// Branches referring to object components cannot be tested, since their values are schema instances.
+func branchPredicate(value string, branch *openapi3.SchemaRef) (string, bool) {
+	if branch == nil || branch.Value == nil || (branch.Ref != "" && isObjectComponent(branch)) {
+		return "", false
+	}
+	return openAPIPredicate(branch.Value, make(map[*openapi3.Schema]bool)).expression(value), true
+}
+
+// schemaPredicate is what a value must satisfy to match a schema: its type and constraints, the required
+// keys and property predicates of objects, the predicates of its allOf, anyOf and oneOf members, the
+// then or else predicate depending on whether its if predicate holds, and what the presence of a key implies
+type schemaPredicate struct {
+	constraints       *schemaConstraints
+	properties        map[string]*schemaPredicate
+	allOf             []*schemaPredicate
+	anyOf             []*schemaPredicate
+	oneOf             []*schemaPredicate
+	ifThenElse        [3]*schemaPredicate
+	dependentRequired map[string][]string           // keys required when a key is present
+	dependentSchemas  map[string][]*schemaPredicate // predicates holding when a key is present
+}
+
+// schemaInstance describes the KCL schema a predicate tests the instance of, whose properties are attributes
+type schemaInstance struct {
+	attributes map[string]bool // the declared properties
+	required   []string        // the properties that are never None
+	conjoined  bool            // whether the parts are joined, so the check of a required key precedes its property
+}
+
+// openAPIPredicate builds the predicate of an OpenAPI schema, accepting any value for schemas already being visited
+func openAPIPredicate(schema *openapi3.Schema, visiting map[*openapi3.Schema]bool) *schemaPredicate {
+	if schema == nil || visiting[schema] {
+		return &schemaPredicate{constraints: &schemaConstraints{}}
+	}
+	visiting[schema] = true
+	defer delete(visiting, schema)
+
+	p := &schemaPredicate{constraints: openAPIConstraints(schema), properties: make(map[string]*schemaPredicate)}
+	for name, prop := range schema.Properties {
+		if prop != nil {
+			p.properties[name] = openAPIPredicate(prop.Value, visiting)
+		}
+	}
+	members := func(refs openapi3.SchemaRefs) []*schemaPredicate {
+		var predicates []*schemaPredicate
+		for _, ref := range refs {
+			if ref != nil {
+				predicates = append(predicates, openAPIPredicate(ref.Value, visiting))
+			}
+		}
+		return predicates
+	}
+	p.allOf = members(schema.AllOf)
+	p.anyOf = members(schema.AnyOf)
+	p.oneOf = members(schema.OneOf)
+	return p
+}
+
+// expression renders the predicate on value as a KCL expression
+func (p *schemaPredicate) expression(value string) string {
+	return conjunction(p.parts(value, nil))
+}
+
+// instanceExpression renders the predicate on the instance of a KCL schema as a KCL expression
+func (p *schemaPredicate) instanceExpression(instance *schemaInstance) string {
+	conjoined := *instance
+	conjoined.conjoined = true
+	return conjunction(p.parts("", &conjoined))
+}
+
+// parts renders the predicate on value, or on the instance of a KCL schema, as expressions that must all hold
+func (p *schemaPredicate) parts(value string, instance *schemaInstance) []string {
+	c := p.constraints
+	var parts []string
+	if instance == nil {
+		types := c.types
+		if types == nil && (len(p.properties) > 0 || len(c.required) > 0) {
+			// Properties and required keys describe objects
+			types = []string{"object"}
+		}
+
+		// A nullable value is either None or satisfies the predicate for its other types
+		if contains(types, "null") && len(types) > 1 {
+			nonNullConstraints := *c
+			nonNullConstraints.types = nil
+			for _, t := range types {
+				if t != "null" {
+					nonNullConstraints.types = append(nonNullConstraints.types, t)
+				}
+			}
+			nonNull := *p
+			nonNull.constraints = &nonNullConstraints
+			return []string{fmt.Sprintf("(%s == None or %s)", value, nonNull.expression(value))}
+		}
+		if contains(types, "null") {
+			// No other constraint applies to None
+			return []string{typeCheck(value, types)}
+		}
+
+		if check := typeCheck(value, types); check != "" {
+			parts = append(parts, check)
+		}
+		parts = append(parts, c.checks(value)...)
+	}
+
+	// Required keys and properties are keys of a dict, or attributes of a schema instance
+	parts = append(parts, requiredParts(c.required, value, instance)...)
+	for _, name := range sortedKeys(p.properties) {
+		required := contains(c.required, name)
+		var property, absent string
+		if instance == nil {
+			property = p.properties[name].expression(fmt.Sprintf("%s[\"%s\"]", value, name))
+			absent = fmt.Sprintf("\"%s\" not in %s", name, value)
+		} else {
+			if !instance.attributes[name] {
+				continue
+			}
+			attribute := sanitizePropertyName(name)
+			property = p.properties[name].expression(attribute)
+			absent = attribute + " == None"
+			// Unless the parts are joined, required keys are checked on their own, and an unset
+			// attribute must fail that check instead of this one
+			required = contains(instance.required, name) || (required && instance.conjoined)
+		}
+		switch {
+		case property == "True":
+		case required:
+			parts = append(parts, property)
+		default:
+			parts = append(parts, fmt.Sprintf("(%s or %s)", absent, property))
+		}
+	}
+
+	memberExpressions := func(members []*schemaPredicate) []string {
+		var expressions []string
+		for _, member := range members {
+			expressions = append(expressions, conjunction(member.parts(value, instance)))
+		}
+		return expressions
+	}
+	for _, predicate := range memberExpressions(p.allOf) {
+		if predicate != "True" {
+			parts = append(parts, predicate)
+		}
+	}
+	if len(p.anyOf) > 0 {
+		parts = append(parts, "("+strings.Join(memberExpressions(p.anyOf), " or ")+")")
+	}
+	if len(p.oneOf) > 0 {
+		parts = append(parts, exactlyOne(memberExpressions(p.oneOf)))
+	}
+	if p.ifThenElse[0] != nil {
+		expressions := memberExpressions(p.ifThenElse[:])
+		if expressions[1] != "True" || expressions[2] != "True" {
+			parts = append(parts, fmt.Sprintf("(%s if %s else %s)", expressions[1], expressions[0], expressions[2]))
+		}
+	}
+	for _, dependency := range p.dependencies(value, instance) {
+		if dependency.present == "True" {
+			parts = append(parts, dependency.parts...)
+		} else {
+			parts = append(parts, fmt.Sprintf("(%s or %s)", dependency.absent, conjunction(dependency.parts)))
+		}
+	}
+	return parts
+}
+
+// requiredParts renders keys as present in value, or as set on the instance of a KCL schema
+func requiredParts(keys []string, value string, instance *schemaInstance) []string {
+	var parts []string
+	for _, key := range keys {
+		switch {
+		case instance == nil:
+			parts = append(parts, fmt.Sprintf("\"%s\" in %s", key, value))
+		case !instance.attributes[key]:
+			log.Printf("warning: required property %s is not declared by the schema and cannot be checked", key)
+		case !contains(instance.required, key):
+			parts = append(parts, fmt.Sprintf("%s != None", sanitizePropertyName(key)))
+		}
+	}
+	return parts
+}
+
+// conjunction joins expressions that must all hold
+func conjunction(parts []string) string {
+	switch len(parts) {
+	case 0:
+		return "True"
+	case 1:
+		return parts[0]
+	default:
+		return "(" + strings.Join(parts, " and ") + ")"
+	}
+}
+
+// conditionalChecks returns check expressions applying the then parts when cond holds and the else parts
+// when it does not. A guard, such as an optional field being set, must hold for either to apply.
+func conditionalChecks(guard, cond string, thenParts, elseParts []string) []string {
+	applyIf := func(part string, conditions ...string) string {
+		var terms []string
+		for _, condition := range conditions {
+			if condition != "" && condition != "True" {
+				terms = append(terms, condition)
+			}
+		}
+		if len(terms) == 0 {
+			return part
+		}
+		return part + " if " + strings.Join(terms, " and ")
+	}
+
+	var checks []string
+	for _, part := range thenParts {
+		checks = append(checks, applyIf(part, guard, cond))
+	}
+	if cond == "True" {
+		return checks
+	}
+	negated := "not (" + cond + ")"
+	for _, part := range elseParts {
+		checks = append(checks, applyIf(part, guard, negated))
+	}
+	return checks
+}
+
+// typeCheck returns an expression that holds when value has one of the JSON types, or "" for any type
+func typeCheck(value string, types []string) string {
+	var checks []string
+	for _, t := range types {
+		switch t {
+		case "string":
+			checks = append(checks, fmt.Sprintf("typeof(%s) == \"str\"", value))
+		case "integer":
+			checks = append(checks, fmt.Sprintf("typeof(%s) == \"int\"", value))
+		case "number":
+			checks = append(checks, fmt.Sprintf("typeof(%s) in [\"int\", \"float\"]", value))
+		case "boolean":
+			checks = append(checks, fmt.Sprintf("typeof(%s) == \"bool\"", value))
+		case "array":
+			checks = append(checks, fmt.Sprintf("typeof(%s) == \"list\"", value))
+		case "object":
+			checks = append(checks, fmt.Sprintf("typeof(%s) == \"dict\"", value))
+		case "null":
+			checks = append(checks, fmt.Sprintf("%s == None", value))
+		}
+	}
+	switch len(checks) {
+	case 0:
+		return ""
+	case 1:
+		return checks[0]
+	default:
+		return "(" + strings.Join(checks, " or ") + ")"
+	}
+}
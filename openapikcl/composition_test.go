@@ -0,0 +1,269 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchTypeSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		branches [][]string
+		expected string
+	}{
+		{"All Strings", [][]string{{"string"}, {"string"}}, "str"},
+		{"Integer And Number", [][]string{{"integer"}, {"number"}}, "float"},
+		{"Booleans", [][]string{{"boolean"}}, "bool"},
+		{"Arrays", [][]string{{"array"}, {"array"}}, "[any]"},
+		{"Objects", [][]string{{"object"}, {"object"}}, "{str:any}"},
+		{"Mixed", [][]string{{"string"}, {"integer"}}, "any"},
+		{"Untyped", [][]string{{}, {}}, "any"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			summary := branchTypeSummary{}
+			for _, types := range tc.branches {
+				summary = summary.merge(summarizeBranchTypes(types))
+			}
+			assert.Equal(t, tc.expected, summary.kclType())
+		})
+	}
+}
+
+func TestUnionOf(t *testing.T) {
+	assert.Equal(t, "str | int", unionOf([]string{"str", "int", "str"}))
+	assert.Equal(t, "str | None", unionOf([]string{"None", "str"}))
+	assert.Equal(t, "None", unionOf([]string{"None"}))
+	assert.Equal(t, "any", unionOf([]string{"str", "any"}))
+	assert.Equal(t, "any", unionOf(nil))
+
+	assert.Equal(t, "Pet | None", nullableType("Pet"))
+	assert.Equal(t, "str | None", nullableType("str | None"))
+	assert.Equal(t, "any", nullableType("any"))
+
+	assert.Equal(t, []string{"len(name) >= 1 if name != None"}, guardNone([]string{"len(name) >= 1"}, "name"))
+}
+
+func TestCompositionUnionType(t *testing.T) {
+	doc := &openapi3.T{Components: &openapi3.Components{Schemas: openapi3.Schemas{}}}
+
+	tests := []struct {
+		name     string
+		branches openapi3.SchemaRefs
+		expected string
+	}{
+		{
+			name: "Schema References",
+			branches: openapi3.SchemaRefs{
+				openapi3.NewSchemaRef("#/components/schemas/Cat", openapi3.NewObjectSchema()),
+				openapi3.NewSchemaRef("#/components/schemas/Dog", openapi3.NewObjectSchema()),
+			},
+			expected: "Cat | Dog",
+		},
+		{
+			name: "Primitives",
+			branches: openapi3.SchemaRefs{
+				openapi3.NewStringSchema().NewRef(),
+				openapi3.NewIntegerSchema().NewRef(),
+			},
+			expected: "str | int",
+		},
+		{
+			name: "Duplicate Types",
+			branches: openapi3.SchemaRefs{
+				openapi3.NewStringSchema().WithMaxLength(3).NewRef(),
+				openapi3.NewStringSchema().WithMinLength(5).NewRef(),
+			},
+			expected: "str",
+		},
+		{
+			name: "Array And Primitive",
+			branches: openapi3.SchemaRefs{
+				openapi3.NewStringSchema().NewRef(),
+				openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()).NewRef(),
+			},
+			expected: "str | [str]",
+		},
+		{
+			name: "Inline Objects Fall Back To Common Type",
+			branches: openapi3.SchemaRefs{
+				openapi3.NewObjectSchema().WithProperty("a", openapi3.NewStringSchema()).NewRef(),
+				openapi3.NewObjectSchema().WithProperty("b", openapi3.NewStringSchema()).NewRef(),
+			},
+			expected: "{str:any}",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, _ := compositionUnionType("field", tc.branches, "Parent", doc, GenerateOptions{})
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestOneOfCheck(t *testing.T) {
+	branches := openapi3.SchemaRefs{
+		openapi3.NewStringSchema().WithMaxLength(3).NewRef(),
+		openapi3.NewIntegerSchema().WithMin(1).NewRef(),
+	}
+
+	assert.Equal(t,
+		`len([_m for _m in [(typeof(code) == "str" and len(code) <= 3), (typeof(code) == "int" and code >= 1)] if _m]) == 1, "code must match exactly one oneOf schema"`,
+		oneOfCheck("code", branches, true))
+	assert.Equal(t,
+		`len([_m for _m in [(typeof(code) == "str" and len(code) <= 3), (typeof(code) == "int" and code >= 1)] if _m]) == 1 if code != None, "code must match exactly one oneOf schema"`,
+		oneOfCheck("code", branches, false))
+
+	// Inline object branches test their required keys and properties
+	objectBranches := openapi3.SchemaRefs{
+		openapi3.NewObjectSchema().WithProperty("email", openapi3.NewStringSchema()).WithRequired([]string{"email"}).NewRef(),
+		openapi3.NewObjectSchema().WithProperty("phone", openapi3.NewStringSchema().WithMinLength(5)).NewRef(),
+	}
+	assert.Equal(t,
+		`len([_m for _m in [(typeof(contact) == "dict" and "email" in contact and typeof(contact["email"]) == "str"), `+
+			`(typeof(contact) == "dict" and ("phone" not in contact or (typeof(contact["phone"]) == "str" and len(contact["phone"]) >= 5)))] if _m]) == 1, `+
+			`"contact must match exactly one oneOf schema"`,
+		oneOfCheck("contact", objectBranches, true))
+
+	// Nullable branches also match None
+	nullableBranches := openapi3.SchemaRefs{
+		openapi3.NewSchemaRef("", &openapi3.Schema{Type: &openapi3.Types{"string", "null"}, MinLength: 2}),
+		openapi3.NewBoolSchema().NewRef(),
+	}
+	assert.Equal(t,
+		`len([_m for _m in [(code == None or (typeof(code) == "str" and len(code) >= 2)), typeof(code) == "bool"] if _m]) == 1, "code must match exactly one oneOf schema"`,
+		oneOfCheck("code", nullableBranches, true))
+
+	// Branches referring to object schemas are left to the union type
+	refBranches := openapi3.SchemaRefs{
+		openapi3.NewSchemaRef("#/components/schemas/Cat", openapi3.NewObjectSchema()),
+		openapi3.NewStringSchema().NewRef(),
+	}
+	assert.Empty(t, oneOfCheck("pet", refBranches, true))
+
+	// A single branch needs no check
+	assert.Empty(t, oneOfCheck("code", branches[:1], true))
+}
+
+func TestConditionalChecks(t *testing.T) {
+	assert.Equal(t,
+		[]string{`a if x == 1`, `b if not (x == 1)`},
+		conditionalChecks("", "x == 1", []string{"a"}, []string{"b"}))
+	assert.Equal(t,
+		[]string{`a if x != None and (x > 1 and x < 5)`, `b if x != None and not ((x > 1 and x < 5))`},
+		conditionalChecks("x != None", "(x > 1 and x < 5)", []string{"a"}, []string{"b"}))
+
+	// Conditions starting and ending with parentheses are not necessarily enclosed by them
+	assert.Equal(t,
+		[]string{`a if (x > 1) and (y > 1)`, `b if not ((x > 1) and (y > 1))`},
+		conditionalChecks("", "(x > 1) and (y > 1)", []string{"a"}, []string{"b"}))
+
+	// An if schema accepting every value always applies the then schema
+	assert.Equal(t, []string{"a"}, conditionalChecks("", "True", []string{"a"}, []string{"b"}))
+
+	// Nested conditions become conditional expressions
+	predicate := &schemaPredicate{constraints: &schemaConstraints{}}
+	predicate.ifThenElse = [3]*schemaPredicate{
+		{constraints: &schemaConstraints{enum: []interface{}{"a"}}},
+		{constraints: &schemaConstraints{minLength: positiveCount(2)}},
+		nil,
+	}
+	predicate.ifThenElse[2] = &schemaPredicate{constraints: &schemaConstraints{}}
+	assert.Equal(t, `(len(v) >= 2 if v in ["a"] else True)`, predicate.expression("v"))
+}
+
+func TestGenerateCompositionSchemas(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/compositions.yaml", LoadOptions{
+		FlattenSpec: false,
+	})
+	require.NoError(t, err)
+	require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+	readSchema := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		return string(content)
+	}
+
+	// A component that is only a oneOf becomes a type alias
+	assert.Contains(t, readSchema("Pet"), "type Pet = Cat | Dog")
+
+	owner := readSchema("Owner")
+	assert.Contains(t, owner, "import regex")
+	assert.Contains(t, owner, "id: str | int")
+	assert.Contains(t, owner, "code?: str")
+	assert.Contains(t, owner, "pet?: Pet")
+	assert.Contains(t, owner, "favorite?: Cat | Dog")
+	assert.Contains(t, owner, "tags?: str | [str]")
+	assert.Contains(t, owner, "contact?: OwnerContactOption1 | OwnerContactOption2")
+	assert.Contains(t, owner, `(typeof(id) == "str" and regex.match(id, r"^[a-z]+$"))`)
+	assert.Contains(t, owner, `if code != None, "code must match exactly one oneOf schema"`)
+	assert.Equal(t, 1, strings.Count(owner, "check:"), "constraints should share a single check block")
+
+	// Inline object branches are hoisted into numbered options
+	assert.Contains(t, readSchema("OwnerContactOption1"), "email?: str")
+	assert.Contains(t, readSchema("OwnerContactOption2"), "phone?: str")
+}
+
+func TestResolveTaggedUnion(t *testing.T) {
+	doc, _, err := LoadOpenAPISchema("testdata/oas/input/discriminator.yaml", LoadOptions{FlattenSpec: false})
+	require.NoError(t, err)
+	schemas := doc.Components.Schemas
+
+	// Mapping entries come first, several values may select the same subtype
+	pet := resolveTaggedUnion("Pet", schemas["Pet"].Value, schemas)
+	require.NotNil(t, pet)
+	assert.Equal(t, "petType", pet.propertyName)
+	assert.Equal(t, []taggedSubtype{{"cat", "Cat"}, {"dog", "Dog"}, {"kitty", "Cat"}}, pet.subtypes)
+	assert.Equal(t, "Cat | Dog", pet.unionType())
+
+	// Without a mapping, subtypes extending the parent are selected by schema name
+	vehicle := resolveTaggedUnion("Vehicle", schemas["Vehicle"].Value, schemas)
+	require.NotNil(t, vehicle)
+	assert.Equal(t, []taggedSubtype{{"Bike", "Bike"}, {"Car", "Car"}}, vehicle.subtypes)
+	assert.Equal(t, `typeof(v) == {"Bike": "Bike", "Car": "Car"}[v.kind]`, vehicle.branchCheck("v"))
+
+	assert.Nil(t, resolveTaggedUnion("Cat", schemas["Cat"].Value, schemas))
+	assert.Equal(t, map[string][]string{"petType": {"cat", "kitty"}}, discriminatorLiterals("Cat", schemas))
+}
+
+func TestGenerateDiscriminatorSchemas(t *testing.T) {
+	for _, flatten := range []bool{false, true} {
+		tempDir := t.TempDir()
+
+		doc, version, err := LoadOpenAPISchema("testdata/oas/input/discriminator.yaml", LoadOptions{
+			FlattenSpec: flatten,
+		})
+		require.NoError(t, err)
+		require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+		readSchema := func(name string) string {
+			content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+			require.NoError(t, err, "schema file %s should exist", name+".k")
+			return string(content)
+		}
+
+		assert.Contains(t, readSchema("Pet"), "type Pet = Cat | Dog")
+		assert.Contains(t, readSchema("Cat"), `petType: "cat" | "kitty"`)
+		assert.Contains(t, readSchema("Dog"), `petType: "dog" = "dog"`)
+
+		owner := readSchema("Owner")
+		assert.Contains(t, owner, "pet: Cat | Dog")
+		assert.Contains(t, owner, `typeof(pet) == {"cat": "Cat", "dog": "Dog", "kitty": "Cat"}[pet.petType], "pet must match the schema selected by petType"`)
+		// Flattening keeps allOf, so inheritance-style subtypes are known either way
+		assert.Contains(t, owner, "vehicles?: [Bike | Car]")
+		assert.Contains(t, owner, `all _item in vehicles { typeof(_item) == {"Bike": "Bike", "Car": "Car"}[_item.kind] } if vehicles != None`)
+		assert.Contains(t, readSchema("Car"), "schema Car(Vehicle):")
+		assert.Contains(t, readSchema("Car"), `kind: "Car" = "Car"`)
+	}
+}
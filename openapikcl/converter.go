@@ -53,6 +53,8 @@ func ConvertTypeToKCL(oapiType, format string) string {
 		kclType = "list" // The element type will be handled separately
 	case "object":
 		kclType = "{str:any}" // For generic objects, specific schema types will be handled differently
+	case "null":
+		kclType = "None"
 	default:
 		log.Printf("warning: unknown type '%s', defaulting to 'any'", oapiType)
 		kclType = "any"
@@ -62,10 +64,8 @@ func ConvertTypeToKCL(oapiType, format string) string {
 	return kclType
 }
 
-// GenerateConstraints creates KCL constraint expressions for a schema
+// GenerateConstraints creates KCL constraint expressions for a schema, including those of its allOf members
 func GenerateConstraints(schema *openapi3.Schema, fieldName string, useSelfPrefix bool) []string {
-	var constraints []string
-
 	// Prefix field name with self. for KCL constraint context if requested
 	kclFieldRef := fieldName
 	if useSelfPrefix {
@@ -73,70 +73,12 @@ func GenerateConstraints(schema *openapi3.Schema, fieldName string, useSelfPrefi
 	}
 
 	// Required validation is handled at the schema level
-
-	// String constraints - MinLength is uint64 (non-pointer)
-	if schema.MinLength > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) >= %d", kclFieldRef, schema.MinLength))
-	}
-	// MaxLength is *uint64 (pointer)
-	if schema.MaxLength != nil && *schema.MaxLength > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) <= %d", kclFieldRef, *schema.MaxLength))
-	}
-	if schema.Pattern != "" {
-		// KCL uses regex matching
-		constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"%s\")", kclFieldRef, schema.Pattern))
-	}
-
-	// Numeric constraints
-	if schema.Min != nil {
-		if schema.ExclusiveMin {
-			constraints = append(constraints, fmt.Sprintf("%s > %v", kclFieldRef, *schema.Min))
-		} else {
-			constraints = append(constraints, fmt.Sprintf("%s >= %v", kclFieldRef, *schema.Min))
-		}
-	}
-	if schema.Max != nil {
-		if schema.ExclusiveMax {
-			constraints = append(constraints, fmt.Sprintf("%s < %v", kclFieldRef, *schema.Max))
-		} else {
-			constraints = append(constraints, fmt.Sprintf("%s <= %v", kclFieldRef, *schema.Max))
-		}
+	constraints, err := mergeOpenAPIConstraints(schema)
+	if err != nil {
+		log.Printf("warning: allOf members of %s contradict each other: %v", fieldName, err)
+		constraints = openAPIConstraints(schema)
 	}
-	if schema.MultipleOf != nil {
-		// KCL doesn't have a direct way to check if a number is a multiple of another
-		// But we can use a modulo check
-		constraints = append(constraints, fmt.Sprintf("%s %% %v == 0", kclFieldRef, *schema.MultipleOf))
-	}
-
-	// Array constraints - MinItems is uint64 (non-pointer)
-	if schema.MinItems > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) >= %d", kclFieldRef, schema.MinItems))
-	}
-	// MaxItems is *uint64 (pointer)
-	if schema.MaxItems != nil && *schema.MaxItems > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) <= %d", kclFieldRef, *schema.MaxItems))
-	}
-	if schema.UniqueItems {
-		// Use isunique function in KCL to check uniqueness
-		constraints = append(constraints, fmt.Sprintf("isunique(%s)", kclFieldRef))
-	}
-
-	// Enum validation
-	if len(schema.Enum) > 0 {
-		values := make([]string, len(schema.Enum))
-		for i, v := range schema.Enum {
-			// Format the enum value based on its type
-			switch value := v.(type) {
-			case string:
-				values[i] = fmt.Sprintf("\"%s\"", value)
-			default:
-				values[i] = fmt.Sprintf("%v", value)
-			}
-		}
-		constraints = append(constraints, fmt.Sprintf("%s in [%s]", kclFieldRef, strings.Join(values, ", ")))
-	}
-
-	return constraints
+	return constraints.checks(kclFieldRef)
 }
 
 // FormatDocumentation generates KCL documentation from OpenAPI schema
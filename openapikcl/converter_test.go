@@ -168,3 +168,50 @@ func TestFormatDocumentation(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertOpenAPI31TypeToKCL(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeObj  map[string]interface{}
+		expected string
+	}{
+		{"String", map[string]interface{}{"type": "string"}, "str"},
+		{"Nullable String", map[string]interface{}{"type": []interface{}{"string", "null"}}, "str | None"},
+		{"Null First", map[string]interface{}{"type": []interface{}{"null", "integer"}}, "int | None"},
+		{"Multiple Types", map[string]interface{}{"type": []interface{}{"integer", "string"}}, "int | str"},
+		{"Only Null", map[string]interface{}{"type": "null"}, "None"},
+		{"Const String", map[string]interface{}{"const": "fixed"}, "str"},
+		{"Const Boolean", map[string]interface{}{"const": true}, "bool"},
+		{"Object", map[string]interface{}{"type": "object"}, "{str:any}"},
+		{"Ref To Defs", map[string]interface{}{
+			"$ref":  "#/$defs/count",
+			"$defs": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+		}, "int"},
+		{"Untyped", map[string]interface{}{}, "any"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ConvertOpenAPI31TypeToKCL(tc.typeObj)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestProcessOpenAPI31Schema(t *testing.T) {
+	result, err := ProcessOpenAPI31Schema(map[string]interface{}{
+		"title":    "Pet",
+		"type":     "object",
+		"required": []interface{}{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"tag":  map[string]interface{}{"type": []interface{}{"string", "null"}},
+			"kind": map[string]interface{}{"const": "pet", "default": "pet"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, result, "schema Pet:")
+	assert.Contains(t, result, "name: str")
+	assert.Contains(t, result, "tag?: str")
+	assert.Contains(t, result, "kind?: str = \"pet\"")
+}
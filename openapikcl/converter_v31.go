@@ -1,29 +1,608 @@
 package openapikcl
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
-// This file contains placeholders for future OpenAPI 3.1 support
-// OpenAPI 3.1 brings several changes that need special handling:
-// 1. Schema Object is fully compatible with JSON Schema 2020-12
-// 2. Discriminator is now a boolean property
-// 3. New oneOf, anyOf, allOf keywords
-// 4. Schema composition with multiple types allowed
+// This file contains the OpenAPI 3.1 specific handling.
+// OpenAPI 3.1 schema objects are JSON Schema 2020-12, which kin-openapi does not
+// model directly. Documents are therefore normalized into the OpenAPI 3.0 shape
+// before loading so the rest of the pipeline can process them unchanged:
+// 1. Type arrays: ["string", "null"] becomes type "string" with nullable: true
+// 2. const becomes a single value enum
+// 3. $defs entries are hoisted into components.schemas and their refs rewritten
+// 4. prefixItems become an anyOf items schema; kin-openapi keeps them as an extension,
+//    from which each position is checked against its own schema
+// 5. $ref with sibling keywords becomes allOf: [{$ref}, {siblings}]
+// 6. Numeric exclusiveMinimum/exclusiveMaximum become the boolean 3.0 form
+// 7. webhooks are normalized and resolved against the document components
+
+// webhooksExtension is the extension key under which kin-openapi keeps the webhooks section
+const webhooksExtension = "webhooks"
+
+// refAnnotationKeywords are sibling keywords of $ref that do not change validation
+var refAnnotationKeywords = map[string]bool{
+	"description": true,
+	"summary":     true,
+	"title":       true,
+	"deprecated":  true,
+	"readOnly":    true,
+	"writeOnly":   true,
+	"examples":    true,
+	"example":     true,
+	"$comment":    true,
+}
+
+// openAPI31Normalizer rewrites an OpenAPI 3.1 document into the OpenAPI 3.0 shape
+type openAPI31Normalizer struct {
+	doc         map[string]interface{}
+	schemas     map[string]interface{}
+	hoistedRefs map[string]string // old $defs pointer -> new components ref
+}
+
+// normalizeOpenAPI31Document converts a raw OpenAPI 3.1 document into a document kin-openapi can load
+func normalizeOpenAPI31Document(doc map[string]interface{}) (map[string]interface{}, error) {
+	n := &openAPI31Normalizer{
+		doc:         doc,
+		hoistedRefs: make(map[string]string),
+	}
+
+	components, _ := doc["components"].(map[string]interface{})
+	if components == nil {
+		components = make(map[string]interface{})
+		doc["components"] = components
+	}
+	n.schemas, _ = components["schemas"].(map[string]interface{})
+	if n.schemas == nil {
+		n.schemas = make(map[string]interface{})
+		components["schemas"] = n.schemas
+	}
+
+	// Normalize component schemas in sorted order so hoisted names are deterministic
+	for _, name := range sortedKeys(n.schemas) {
+		n.schemas[name] = n.normalizeSchema(n.schemas[name], "#/components/schemas/"+escapeJSONPointerToken(name), name)
+	}
+
+	// Normalize schemas nested in the other component sections
+	for _, section := range []string{"parameters", "headers"} {
+		if items, ok := components[section].(map[string]interface{}); ok {
+			for _, name := range sortedKeys(items) {
+				n.normalizeParameter(items[name], name)
+			}
+		}
+	}
+	if items, ok := components["requestBodies"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(items) {
+			n.normalizeContentHolder(items[name], name)
+		}
+	}
+	if items, ok := components["responses"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(items) {
+			n.normalizeResponse(items[name], name)
+		}
+	}
+	if items, ok := components["pathItems"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(items) {
+			n.normalizePathItem(items[name], name)
+		}
+	}
+
+	if paths, ok := doc["paths"].(map[string]interface{}); ok {
+		for _, path := range sortedKeys(paths) {
+			n.normalizePathItem(paths[path], path)
+		}
+	}
+	if webhooks, ok := doc[webhooksExtension].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(webhooks) {
+			n.normalizePathItem(webhooks[name], name)
+		}
+	}
+
+	// Point refs into $defs at their hoisted components
+	if len(n.hoistedRefs) > 0 {
+		n.rewriteRefs(doc)
+	}
+
+	return doc, nil
+}
+
+// normalizeSchema normalizes a single schema object and all of its subschemas
+func (n *openAPI31Normalizer) normalizeSchema(node interface{}, pointer string, hint string) interface{} {
+	// Boolean schemas have no OpenAPI 3.0 equivalent
+	if b, ok := node.(bool); ok {
+		if b {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"not": map[string]interface{}{}}
+	}
+
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+
+	// Hoist $defs into components so they can be referenced by name
+	if defs, ok := schema["$defs"].(map[string]interface{}); ok {
+		for _, defName := range sortedKeys(defs) {
+			defPointer := pointer + "/$defs/" + escapeJSONPointerToken(defName)
+			componentName := n.hoistedName(defName, hint)
+			n.hoistedRefs[defPointer] = "#/components/schemas/" + escapeJSONPointerToken(componentName)
+			n.schemas[componentName] = n.normalizeSchema(defs[defName], defPointer, componentName)
+			log.Printf("hoisted $defs entry %s to component %s", defPointer, componentName)
+		}
+		delete(schema, "$defs")
+	}
+
+	// Type arrays: null becomes nullable, a single remaining type becomes a plain string
+	if types, ok := schema["type"].([]interface{}); ok {
+		var remaining []interface{}
+		for _, t := range types {
+			if t == "null" {
+				schema["nullable"] = true
+				continue
+			}
+			remaining = append(remaining, t)
+		}
+		switch len(remaining) {
+		case 0:
+			schema["type"] = "null"
+			delete(schema, "nullable")
+		case 1:
+			schema["type"] = remaining[0]
+		default:
+			schema["type"] = remaining
+		}
+	}
 
-// ConvertOpenAPI31TypeToKCL handles OpenAPI 3.1 specific type conversions
-// This is a placeholder for when kin-openapi adds full 3.1 support
+	// const becomes a single value enum
+	if constValue, ok := schema["const"]; ok {
+		if _, hasEnum := schema["enum"]; !hasEnum {
+			schema["enum"] = []interface{}{constValue}
+		}
+		delete(schema, "const")
+	}
+
+	// Numeric exclusive bounds become the boolean OpenAPI 3.0 form. Both bounds apply, so an inclusive
+	// bound that is stricter is kept and the exclusive one dropped.
+	for keyword, bound := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+		value, ok := schema[keyword].(float64)
+		if !ok {
+			continue
+		}
+		var inclusive *numericBound
+		if boundValue, ok := schema[bound].(float64); ok {
+			inclusive = &numericBound{value: boundValue}
+		}
+		stricter := stricterBound(inclusive, &numericBound{value: value, exclusive: true}, keyword == "exclusiveMinimum")
+		schema[bound] = stricter.value
+		if stricter.exclusive {
+			schema[keyword] = true
+		} else {
+			delete(schema, keyword)
+		}
+	}
+
+	// Subschemas
+	for _, keyword := range []string{"properties", "patternProperties", "dependentSchemas"} {
+		if props, ok := schema[keyword].(map[string]interface{}); ok {
+			for _, name := range sortedKeys(props) {
+				props[name] = n.normalizeSchema(props[name], pointer+"/"+keyword+"/"+escapeJSONPointerToken(name), hint+formatSchemaName(name))
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf", "prefixItems"} {
+		if list, ok := schema[keyword].([]interface{}); ok {
+			for i := range list {
+				list[i] = n.normalizeSchema(list[i], fmt.Sprintf("%s/%s/%d", pointer, keyword, i), hint)
+			}
+		}
+	}
+	for _, keyword := range []string{"not", "if", "then", "else", "contains", "propertyNames", "unevaluatedProperties", "unevaluatedItems"} {
+		if sub, ok := schema[keyword]; ok {
+			schema[keyword] = n.normalizeSchema(sub, pointer+"/"+keyword, hint)
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		schema["additionalProperties"] = n.normalizeSchema(additional, pointer+"/additionalProperties", hint)
+	}
+
+	// items: false/true are boolean schemas; prefixItems become an anyOf item schema
+	items, hasItems := schema["items"]
+	if b, ok := items.(bool); ok {
+		delete(schema, "items")
+		hasItems = false
+		if !b {
+			prefix, _ := schema["prefixItems"].([]interface{})
+			schema["maxItems"] = len(prefix)
+		}
+	} else if hasItems {
+		items = n.normalizeSchema(items, pointer+"/items", hint)
+		schema["items"] = items
+	}
+	if prefix, ok := schema["prefixItems"].([]interface{}); ok && len(prefix) > 0 {
+		branches := append([]interface{}{}, prefix...)
+		if hasItems {
+			branches = append(branches, items)
+		}
+		if len(branches) == 1 {
+			schema["items"] = branches[0]
+		} else {
+			schema["items"] = map[string]interface{}{"anyOf": branches}
+		}
+		if _, ok := schema["minItems"]; !ok {
+			schema["minItems"] = len(prefix)
+		}
+	}
+
+	// $ref with sibling keywords
+	if ref, ok := schema["$ref"].(string); ok && len(schema) > 1 {
+		siblings := make(map[string]interface{})
+		onlyAnnotations := true
+		for key, value := range schema {
+			if key == "$ref" {
+				continue
+			}
+			siblings[key] = value
+			if !refAnnotationKeywords[key] {
+				onlyAnnotations = false
+			}
+		}
+		if onlyAnnotations {
+			// Annotations do not change validation so the plain reference is kept
+			return map[string]interface{}{"$ref": ref}
+		}
+		siblings["allOf"] = append([]interface{}{map[string]interface{}{"$ref": ref}}, toInterfaceSlice(siblings["allOf"])...)
+		return siblings
+	}
+
+	return schema
+}
+
+// normalizeParameter normalizes the schema and content of a parameter or header object
+func (n *openAPI31Normalizer) normalizeParameter(node interface{}, hint string) {
+	param, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if schema, ok := param["schema"]; ok {
+		param["schema"] = n.normalizeSchema(schema, "", formatSchemaName(hint))
+	}
+	n.normalizeContentHolder(param, hint)
+}
+
+// normalizeContentHolder normalizes the media type schemas of a request body, response or parameter
+func (n *openAPI31Normalizer) normalizeContentHolder(node interface{}, hint string) {
+	holder, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	content, ok := holder["content"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, mediaType := range sortedKeys(content) {
+		if media, ok := content[mediaType].(map[string]interface{}); ok {
+			if schema, ok := media["schema"]; ok {
+				media["schema"] = n.normalizeSchema(schema, "", formatSchemaName(hint))
+			}
+		}
+	}
+}
+
+// normalizeResponse normalizes the content and headers of a response object
+func (n *openAPI31Normalizer) normalizeResponse(node interface{}, hint string) {
+	n.normalizeContentHolder(node, hint)
+	if response, ok := node.(map[string]interface{}); ok {
+		if headers, ok := response["headers"].(map[string]interface{}); ok {
+			for _, name := range sortedKeys(headers) {
+				n.normalizeParameter(headers[name], hint+name)
+			}
+		}
+	}
+}
+
+// normalizePathItem normalizes every operation of a path item or webhook
+func (n *openAPI31Normalizer) normalizePathItem(node interface{}, hint string) {
+	pathItem, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if params, ok := pathItem["parameters"].([]interface{}); ok {
+		for _, param := range params {
+			n.normalizeParameter(param, hint)
+		}
+	}
+	for _, method := range sortedKeys(pathItem) {
+		operation, ok := pathItem[method].(map[string]interface{})
+		if !ok || method == "parameters" || method == "servers" {
+			continue
+		}
+		if params, ok := operation["parameters"].([]interface{}); ok {
+			for _, param := range params {
+				n.normalizeParameter(param, hint)
+			}
+		}
+		if body, ok := operation["requestBody"]; ok {
+			n.normalizeContentHolder(body, hint)
+		}
+		if responses, ok := operation["responses"].(map[string]interface{}); ok {
+			for _, status := range sortedKeys(responses) {
+				n.normalizeResponse(responses[status], hint)
+			}
+		}
+		if callbacks, ok := operation["callbacks"].(map[string]interface{}); ok {
+			for _, name := range sortedKeys(callbacks) {
+				if callback, ok := callbacks[name].(map[string]interface{}); ok {
+					for _, expression := range sortedKeys(callback) {
+						n.normalizePathItem(callback[expression], name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// hoistedName picks an unused component name for a hoisted $defs entry
+func (n *openAPI31Normalizer) hoistedName(defName, parent string) string {
+	name := formatSchemaName(defName)
+	if _, taken := n.schemas[name]; !taken {
+		return name
+	}
+	name = formatSchemaName(parent) + name
+	candidate := name
+	for i := 2; ; i++ {
+		if _, taken := n.schemas[candidate]; !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+}
+
+// rewriteRefs points every $ref into a hoisted $defs entry at the new component
+func (n *openAPI31Normalizer) rewriteRefs(node interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			v["$ref"] = n.rewriteRef(ref)
+		}
+		for _, child := range v {
+			n.rewriteRefs(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			n.rewriteRefs(child)
+		}
+	}
+}
+
+// rewriteRef maps a single reference, keeping any pointer suffix below the hoisted definition
+func (n *openAPI31Normalizer) rewriteRef(ref string) string {
+	// Prefer the longest matching pointer so nested $defs win over their parents
+	var best string
+	for oldPointer := range n.hoistedRefs {
+		if (ref == oldPointer || strings.HasPrefix(ref, oldPointer+"/")) && len(oldPointer) > len(best) {
+			best = oldPointer
+		}
+	}
+	if best == "" {
+		return ref
+	}
+	return n.hoistedRefs[best] + strings.TrimPrefix(ref, best)
+}
+
+// resolveOpenAPI31Webhooks parses the webhooks section of a loaded document and resolves
+// its references against the document components. The typed path items replace the raw
+// extension value so later stages can use them directly.
+func resolveOpenAPI31Webhooks(loader *openapi3.Loader, doc *openapi3.T) error {
+	raw, ok := doc.Extensions[webhooksExtension]
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhooks: %w", err)
+	}
+	webhooks := make(map[string]*openapi3.PathItem)
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return fmt.Errorf("failed to parse webhooks: %w", err)
+	}
+
+	// kin-openapi only resolves references reachable from paths, so resolve the
+	// webhooks as the paths of a temporary document sharing the same components
+	paths := openapi3.NewPaths()
+	for name, pathItem := range webhooks {
+		paths.Set("/"+name, pathItem)
+	}
+	temp := &openapi3.T{
+		OpenAPI:    doc.OpenAPI,
+		Info:       doc.Info,
+		Components: doc.Components,
+		Paths:      paths,
+	}
+	if err := loader.ResolveRefsIn(temp, nil); err != nil {
+		return fmt.Errorf("failed to resolve webhook references: %w", err)
+	}
+
+	doc.Extensions[webhooksExtension] = webhooks
+	log.Printf("resolved %d webhooks", len(webhooks))
+	return nil
+}
+
+// Webhooks returns the webhooks of an OpenAPI 3.1 document keyed by name
+func Webhooks(doc *openapi3.T) map[string]*openapi3.PathItem {
+	if doc == nil {
+		return nil
+	}
+	webhooks, _ := doc.Extensions[webhooksExtension].(map[string]*openapi3.PathItem)
+	return webhooks
+}
+
+// collectWebhookSchemas returns a named schema for every inline webhook payload
+func collectWebhookSchemas(doc *openapi3.T) openapi3.Schemas {
+	schemas := make(openapi3.Schemas)
+	webhooks := Webhooks(doc)
+
+	var names []string
+	for name := range webhooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		operations := webhooks[name].Operations()
+		var methods []string
+		for method := range operations {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			baseName := formatSchemaName(name) + "Webhook"
+			if len(methods) > 1 {
+				baseName += formatSchemaName(strings.ToLower(method))
+			}
+			for schemaName, schema := range requestBodySchemas(operations[method].RequestBody, baseName) {
+				schemas[schemaName] = schema
+			}
+		}
+	}
+
+	return schemas
+}
+
+// requestBodySchemas returns the inline schemas of a request body named after baseName.
+// Schemas that are plain references to components are skipped since they are already generated.
+func requestBodySchemas(body *openapi3.RequestBodyRef, baseName string) openapi3.Schemas {
+	schemas := make(openapi3.Schemas)
+	if body == nil || body.Value == nil {
+		return schemas
+	}
+
+	var mediaTypes []string
+	for mediaType, media := range body.Value.Content {
+		if media != nil && media.Schema != nil && media.Schema.Ref == "" && media.Schema.Value != nil {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	sort.Strings(mediaTypes)
+
+	for _, mediaType := range mediaTypes {
+		name := baseName
+		if len(mediaTypes) > 1 {
+			name += mediaTypeSuffix(mediaType)
+		}
+		schemas[name] = body.Value.Content[mediaType].Schema
+	}
+	return schemas
+}
+
+// mediaTypeSuffix turns a media type such as application/json into a name suffix such as Json
+func mediaTypeSuffix(mediaType string) string {
+	subtype := mediaType
+	if idx := strings.LastIndex(subtype, "/"); idx >= 0 {
+		subtype = subtype[idx+1:]
+	}
+	var result strings.Builder
+	for _, part := range strings.FieldsFunc(subtype, func(r rune) bool {
+		return r == '+' || r == '-' || r == '.' || r == '*'
+	}) {
+		result.WriteString(formatSchemaName(part))
+	}
+	return result.String()
+}
+
+// compileOpenAPI31Schema compiles a standalone OpenAPI 3.1 schema object as JSON Schema 2020-12
+func compileOpenAPI31Schema(schemaObj map[string]interface{}) (*jsonschema.Schema, error) {
+	data, err := json.Marshal(schemaObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+
+	schemaID := "openapi31-schema.json"
+	if err := compiler.AddResource(schemaID, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to add schema resource: %w", err)
+	}
+	return compiler.Compile(schemaID)
+}
+
+// ConvertOpenAPI31TypeToKCL handles OpenAPI 3.1 specific type conversions.
+// The schema object is compiled as JSON Schema 2020-12 and mapped with the JSON Schema converter.
 func ConvertOpenAPI31TypeToKCL(typeObj map[string]interface{}) string {
-	log.Printf("OpenAPI 3.1 type conversion not yet implemented")
-	// OpenAPI 3.1 uses JSON Schema type definitions with potentially multiple types
-	// For now, return a placeholder
-	return "any" // Default fallback
+	schema, err := compileOpenAPI31Schema(typeObj)
+	if err != nil {
+		log.Printf("warning: failed to compile OpenAPI 3.1 schema, defaulting to 'any': %v", err)
+		return "any"
+	}
+	return jsonSchemaTypeToKCL(schema)
 }
 
-// ProcessOpenAPI31Schema processes an OpenAPI 3.1 schema
-// This is a placeholder for when kin-openapi adds full 3.1 support
+// ProcessOpenAPI31Schema processes a standalone OpenAPI 3.1 schema object into a KCL schema
 func ProcessOpenAPI31Schema(schemaObj map[string]interface{}) (string, error) {
-	log.Printf("OpenAPI 3.1 schema processing not yet implemented")
-	// Future implementation will handle the full JSON Schema compatibility
-	return "# TODO: OpenAPI 3.1 schema processing\nschema {}", nil
+	schema, err := compileOpenAPI31Schema(schemaObj)
+	if err != nil {
+		return "", fmt.Errorf("failed to compile OpenAPI 3.1 schema: %w", err)
+	}
+
+	name := "Schema"
+	if title, ok := schemaObj["title"].(string); ok && title != "" {
+		name = formatSchemaName(title)
+	}
+	return generateJSONSchemaToKCLWithDefaults(name, schema, extractDefaultValues(schemaObj))
+}
+
+// escapeJSONPointerToken escapes a single JSON Pointer reference token
+func escapeJSONPointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toInterfaceSlice returns v as a slice, or nil if it is not one
+func toInterfaceSlice(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// openAPIPrefixItemsChecks returns check expressions testing the items at the start of an array field against
+// the prefixItems of its schema. Positions the array may not reach, according to its minItems, are only
+// checked when it is long enough.
+func openAPIPrefixItemsChecks(fieldName string, schema *openapi3.Schema, doc *openapi3.T) []string {
+	prefix, _ := schema.Extensions["prefixItems"].([]interface{})
+	var checks []string
+	for i, value := range prefix {
+		item, err := extensionSchema(value, doc)
+		if err != nil {
+			log.Printf("warning: failed to read prefixItems/%d of %s: %v", i, fieldName, err)
+			continue
+		}
+		check := openAPIPredicate(item.Value, make(map[*openapi3.Schema]bool)).expression(fmt.Sprintf("%s[%d]", fieldName, i))
+		switch {
+		case check == "True":
+			continue
+		case schema.MinItems <= uint64(i):
+			check = fmt.Sprintf("(len(%s) <= %d or %s)", fieldName, i, check)
+		}
+		checks = append(checks, check)
+	}
+	return checks
 }
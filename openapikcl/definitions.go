@@ -0,0 +1,179 @@
+package openapikcl
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Every $defs and definitions entry of a JSON Schema is generated as its own KCL schema, the way
+// OpenAPI components are. Object definitions become schemas and all others become type aliases,
+// so references to them keep their names instead of being inlined.
+
+// definitionKeywords are the keywords holding reusable JSON Schema definitions
+var definitionKeywords = []string{"$defs", "definitions"}
+
+// jsonDefinition is a $defs or definitions entry generated as its own KCL schema
+type jsonDefinition struct {
+	key    string // key in $defs or definitions
+	name   string
+	schema *jsonschema.Schema
+	raw    map[string]interface{}
+}
+
+// compileJSONDefinitions compiles the definitions of a raw schema added to the compiler as resourceID
+// and names them in ctx. Definitions are returned sorted by keyword and key.
+func compileJSONDefinitions(ctx *jsonSchemaContext, compiler *jsonschema.Compiler, resourceID string, rawSchema map[string]interface{}) ([]jsonDefinition, error) {
+	var definitions []jsonDefinition
+	for _, keyword := range definitionKeywords {
+		defs, ok := rawSchema[keyword].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		keys := make([]string, 0, len(defs))
+		for key := range defs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			location := fmt.Sprintf("%s#/%s/%s", resourceID, keyword, url.PathEscape(escapePointerToken(key)))
+			schema, err := compiler.Compile(location)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile definition %q: %w", key, err)
+			}
+			if ctx.names[schema] != "" {
+				continue // the same definition listed under both keywords
+			}
+
+			name := pascalCaseName(key)
+			if name == "" {
+				name = "Definition"
+			}
+			name = ctx.claimName(name)
+			ctx.names[schema] = name
+
+			raw, _ := defs[key].(map[string]interface{})
+			if defaultVal, ok := raw["default"]; ok {
+				ctx.defaults[schema] = defaultVal
+			}
+			definitions = append(definitions, jsonDefinition{key: key, name: name, schema: schema, raw: raw})
+			log.Printf("found definition %s as %s", key, name)
+		}
+	}
+	return definitions, nil
+}
+
+// selectJSONDefinitions returns the root definitions and every definition they reach through references.
+// Roots are matched by their key in $defs or definitions.
+func selectJSONDefinitions(definitions []jsonDefinition, roots []string) ([]jsonDefinition, error) {
+	byKey := make(map[string]jsonDefinition, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.key] = definition
+	}
+
+	reached := make(map[*jsonschema.Schema]bool)
+	for _, root := range roots {
+		definition, ok := byKey[root]
+		if !ok {
+			return nil, fmt.Errorf("root definition %q not found in $defs or definitions", root)
+		}
+		walkJSONSchema(definition.schema, reached)
+	}
+
+	var selected []jsonDefinition
+	for _, definition := range definitions {
+		if reached[definition.schema] {
+			selected = append(selected, definition)
+		}
+	}
+	return selected, nil
+}
+
+// walkJSONSchema records schema and every schema below it, following references
+func walkJSONSchema(schema *jsonschema.Schema, visited map[*jsonschema.Schema]bool) {
+	if schema == nil || visited[schema] {
+		return
+	}
+	visited[schema] = true
+
+	children := []*jsonschema.Schema{
+		schema.Ref, schema.RecursiveRef, schema.DynamicRef,
+		schema.Not, schema.If, schema.Then, schema.Else,
+		schema.PropertyNames, schema.UnevaluatedProperties,
+		schema.Items2020, schema.Contains, schema.UnevaluatedItems, schema.ContentSchema,
+	}
+	children = append(children, schema.AllOf...)
+	children = append(children, schema.AnyOf...)
+	children = append(children, schema.OneOf...)
+	children = append(children, schema.PrefixItems...)
+	for _, child := range schema.Properties {
+		children = append(children, child)
+	}
+	for _, child := range schema.PatternProperties {
+		children = append(children, child)
+	}
+	for _, child := range schema.DependentSchemas {
+		children = append(children, child)
+	}
+	for _, dependency := range schema.Dependencies {
+		if child, ok := dependency.(*jsonschema.Schema); ok {
+			children = append(children, child)
+		}
+	}
+	if child, ok := schema.AdditionalProperties.(*jsonschema.Schema); ok {
+		children = append(children, child)
+	}
+	if child, ok := schema.AdditionalItems.(*jsonschema.Schema); ok {
+		children = append(children, child)
+	}
+	switch items := schema.Items.(type) {
+	case *jsonschema.Schema:
+		children = append(children, items)
+	case []*jsonschema.Schema:
+		children = append(children, items...)
+	}
+
+	for _, child := range children {
+		walkJSONSchema(child, visited)
+	}
+}
+
+// isJSONObjectDefinition reports whether a definition is generated as a KCL schema rather than a type alias
+func isJSONObjectDefinition(schema *jsonschema.Schema) bool {
+	if isJSONInlineObjectSchema(schema) {
+		return true
+	}
+	return len(schema.AllOf) > 0 && (len(schema.Types) == 0 || containsType(schema.Types, "object"))
+}
+
+// generateJSONTypeAlias generates a KCL type alias for a definition that is not an object
+func (ctx *jsonSchemaContext) generateJSONTypeAlias(name string, schema *jsonschema.Schema) string {
+	var sb strings.Builder
+	sb.WriteString("# No schema imports needed - schemas in same directory\n\n")
+	if schema.Title != "" {
+		sb.WriteString(fmt.Sprintf("# %s\n", schema.Title))
+	}
+	for _, line := range strings.Split(schema.Description, "\n") {
+		if line != "" {
+			sb.WriteString(fmt.Sprintf("# %s\n", line))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("type %s = %s\n", name, ctx.structuralType(schema)))
+	return sb.String()
+}
+
+// resolveJSONRef follows a chain of plain references to the schema that describes the value
+func resolveJSONRef(schema *jsonschema.Schema) *jsonschema.Schema {
+	seen := make(map[*jsonschema.Schema]bool)
+	for schema.Ref != nil && len(schema.Types) == 0 && len(schema.Properties) == 0 && !seen[schema] {
+		seen[schema] = true
+		schema = schema.Ref
+	}
+	return schema
+}
@@ -0,0 +1,104 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// definitionsSchema is a JSON Schema whose properties refer to its definitions, which refer to each other
+var definitionsSchema = map[string]interface{}{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title":   "Customer",
+	"type":    "object",
+	"properties": map[string]interface{}{
+		"name":    map[string]interface{}{"type": "string"},
+		"address": map[string]interface{}{"$ref": "#/$defs/address"},
+	},
+	"$defs": map[string]interface{}{
+		"address": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"zip":     map[string]interface{}{"$ref": "#/$defs/postal-code"},
+				"country": map[string]interface{}{"$ref": "#/$defs/country"},
+			},
+			"required": []interface{}{"zip"},
+		},
+		"postal-code": map[string]interface{}{"type": "string", "pattern": "^[0-9]{5}$", "default": "00000"},
+		"country":     map[string]interface{}{"type": "string", "enum": []interface{}{"SE", "US"}},
+		"unused": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"id": map[string]interface{}{"type": "integer"}},
+		},
+	},
+}
+
+func TestGenerateJSONDefinitions(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(definitionsSchema, tempDir, "test", GenerateOptions{}))
+
+	readSchema := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		return string(content)
+	}
+
+	assert.Contains(t, readSchema("Customer"), "address?: Address")
+
+	address := readSchema("Address")
+	assert.Contains(t, address, "schema Address:")
+	assert.Contains(t, address, `zip: PostalCode = "00000"`)
+	assert.Contains(t, address, "country?: Country")
+	// Type aliases cannot carry checks, so the pattern of the definition is checked where it is used
+	assert.Contains(t, address, "import regex")
+	assert.Contains(t, address, `regex.match(zip, r"^[0-9]{5}$")`)
+
+	assert.Contains(t, readSchema("PostalCode"), "type PostalCode = str")
+	assert.Contains(t, readSchema("Country"), `type Country = "SE" | "US"`)
+
+	// Definitions nobody refers to are generated too
+	assert.Contains(t, readSchema("Unused"), "id?: int")
+}
+
+func TestGenerateJSONRootDefinitions(t *testing.T) {
+	tempDir := t.TempDir()
+	opts := GenerateOptions{RootDefinitions: []string{"address"}}
+	require.NoError(t, generateJSONSchemas(definitionsSchema, tempDir, "test", opts))
+
+	for _, name := range []string{"Address", "PostalCode", "Country"} {
+		assert.FileExists(t, filepath.Join(tempDir, name+".k"))
+	}
+	for _, name := range []string{"Customer", "Unused"} {
+		assert.NoFileExists(t, filepath.Join(tempDir, name+".k"))
+	}
+
+	opts = GenerateOptions{RootDefinitions: []string{"missing"}}
+	err := generateJSONSchemas(definitionsSchema, t.TempDir(), "test", opts)
+	assert.ErrorContains(t, err, `root definition "missing" not found`)
+}
+
+func TestGenerateJSONTopLevelRefDefinitions(t *testing.T) {
+	data, err := os.ReadFile("testdata/jsonschema/certmanager.values.schema.json")
+	require.NoError(t, err)
+	var rawSchema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &rawSchema))
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "certmanager", GenerateOptions{}))
+
+	// The document root only refers to helm-values, which stands in for it
+	assert.NoFileExists(t, filepath.Join(tempDir, "Schema.k"))
+	content, err := os.ReadFile(filepath.Join(tempDir, "HelmValues.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "acmesolver?: HelmValuesAcmesolver")
+
+	// Definitions referenced below the first level keep their names
+	content, err = os.ReadFile(filepath.Join(tempDir, "HelmValuesAcmesolverImage.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `pullPolicy?: HelmValuesAcmesolverImagePullPolicy = "IfNotPresent"`)
+	assert.FileExists(t, filepath.Join(tempDir, "HelmValuesAcmesolverImagePullPolicy.k"))
+}
@@ -0,0 +1,173 @@
+package openapikcl
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Property dependencies apply when a key is present: dependentRequired, and the array form of the draft-04
+// dependencies keyword, require other keys, dependentSchemas and the schema form of dependencies apply a
+// schema. They become check expressions guarded by the key being set, e.g. cert != None if tls != None.
+// kin-openapi keeps these keywords as extensions, so OpenAPI schemas read them from there.
+
+// keyDependency is what must hold when a key is present
+type keyDependency struct {
+	present string // holds when the key is present
+	absent  string // holds when it is not
+	parts   []string
+}
+
+// dependencies renders the dependencies of the predicate on value, or on the instance of a KCL schema,
+// sorted by key. A key the instance requires is always present.
+func (p *schemaPredicate) dependencies(value string, instance *schemaInstance) []keyDependency {
+	keys := appendUnique(sortedKeys(p.dependentRequired), sortedKeys(p.dependentSchemas)...)
+	sort.Strings(keys)
+
+	var dependencies []keyDependency
+	for _, key := range keys {
+		dependency := keyDependency{
+			present: fmt.Sprintf("\"%s\" in %s", key, value),
+			absent:  fmt.Sprintf("\"%s\" not in %s", key, value),
+		}
+		if instance != nil {
+			attribute := sanitizePropertyName(key)
+			switch {
+			case !instance.attributes[key]:
+				log.Printf("warning: dependencies of property %s are not checked, since the schema does not declare it", key)
+				continue
+			case contains(instance.required, key):
+				dependency.present, dependency.absent = "True", "False"
+			default:
+				dependency.present, dependency.absent = attribute+" != None", attribute+" == None"
+			}
+		}
+
+		dependency.parts = requiredParts(p.dependentRequired[key], value, instance)
+		for _, schema := range p.dependentSchemas[key] {
+			for _, part := range schema.parts(value, instance) {
+				// The key being present already tells a value is a dict
+				if instance != nil || part != typeCheck(value, []string{"object"}) {
+					dependency.parts = append(dependency.parts, part)
+				}
+			}
+		}
+		if len(dependency.parts) > 0 {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+// dependencyChecks returns check expressions applying the dependencies of the predicate when their key is
+// present in value, or set on the instance of a KCL schema. A guard, such as an optional field being set,
+// must hold for them to apply.
+func (p *schemaPredicate) dependencyChecks(value string, instance *schemaInstance, guard string) []string {
+	dependencies := p.dependencies(value, instance)
+	if len(dependencies) == 0 {
+		return nil
+	}
+	if instance == nil {
+		// Keys are only looked up in dicts, "in" would match substrings of strings
+		dictCheck := typeCheck(value, []string{"object"})
+		if guard == "" {
+			guard = dictCheck
+		} else {
+			guard += " and " + dictCheck
+		}
+	}
+
+	var checks []string
+	for _, dependency := range dependencies {
+		checks = append(checks, conditionalChecks(guard, dependency.present, dependency.parts, nil)...)
+	}
+	return checks
+}
+
+// jsonDependencies returns the keys required, and the predicates holding, when a key of a JSON Schema is present
+func jsonDependencies(schema *jsonschema.Schema, visiting map[*jsonschema.Schema]bool) (map[string][]string, map[string][]*schemaPredicate) {
+	required := make(map[string][]string)
+	predicates := make(map[string][]*schemaPredicate)
+	for key, keys := range schema.DependentRequired {
+		required[key] = appendUnique(required[key], keys...)
+	}
+	for _, key := range sortedKeys(schema.DependentSchemas) {
+		predicates[key] = append(predicates[key], jsonPredicate(schema.DependentSchemas[key], visiting))
+	}
+	for _, key := range sortedKeys(schema.Dependencies) {
+		switch dependency := schema.Dependencies[key].(type) {
+		case []string:
+			required[key] = appendUnique(required[key], dependency...)
+		case *jsonschema.Schema:
+			predicates[key] = append(predicates[key], jsonPredicate(dependency, visiting))
+		}
+	}
+	return required, predicates
+}
+
+// openAPIDependencies returns the keys required, and the predicates holding, when a key of an OpenAPI schema is present
+func openAPIDependencies(schema *openapi3.Schema, doc *openapi3.T) (map[string][]string, map[string][]*schemaPredicate) {
+	required := make(map[string][]string)
+	predicates := make(map[string][]*schemaPredicate)
+	addSchema := func(keyword, key string, value interface{}) {
+		schemaRef, err := extensionSchema(value, doc)
+		if err != nil {
+			log.Printf("warning: failed to read %s of %q: %v", keyword, key, err)
+			return
+		}
+		predicates[key] = append(predicates[key], openAPIPredicate(schemaRef.Value, make(map[*openapi3.Schema]bool)))
+	}
+	addRequired := func(keyword, key string, value []interface{}) {
+		for _, item := range value {
+			if name, ok := item.(string); ok {
+				required[key] = appendUnique(required[key], name)
+			} else {
+				log.Printf("warning: ignoring %v in %s of %q, which is not a property name", item, keyword, key)
+			}
+		}
+	}
+
+	if raw, ok := schema.Extensions["dependentRequired"].(map[string]interface{}); ok {
+		for _, key := range sortedKeys(raw) {
+			keys, _ := raw[key].([]interface{})
+			addRequired("dependentRequired", key, keys)
+		}
+	}
+	if raw, ok := schema.Extensions["dependentSchemas"].(map[string]interface{}); ok {
+		for _, key := range sortedKeys(raw) {
+			addSchema("dependentSchemas", key, raw[key])
+		}
+	}
+	if raw, ok := schema.Extensions["dependencies"].(map[string]interface{}); ok {
+		for _, key := range sortedKeys(raw) {
+			if keys, isArray := raw[key].([]interface{}); isArray {
+				addRequired("dependencies", key, keys)
+			} else {
+				addSchema("dependencies", key, raw[key])
+			}
+		}
+	}
+	return required, predicates
+}
+
+// openAPIDependencyChecks returns the dependency checks of an OpenAPI schema and its allOf members, except the
+// parent it extends, on the instance of its KCL schema
+func openAPIDependencyChecks(schema *openapi3.SchemaRef, parent string, doc *openapi3.T, instance *schemaInstance, visiting map[*openapi3.Schema]bool) []string {
+	if schema == nil || schema.Value == nil || visiting[schema.Value] {
+		return nil
+	}
+	visiting[schema.Value] = true
+
+	predicate := &schemaPredicate{constraints: &schemaConstraints{}}
+	predicate.dependentRequired, predicate.dependentSchemas = openAPIDependencies(schema.Value, doc)
+	checks := predicate.dependencyChecks("", instance, "")
+	for _, member := range schema.Value.AllOf {
+		if member != nil && (member.Ref == "" || extractSchemaName(member.Ref) != parent) {
+			checks = append(checks, openAPIDependencyChecks(member, parent, doc, instance, visiting)...)
+		}
+	}
+	return checks
+}
@@ -0,0 +1,69 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyChecks(t *testing.T) {
+	predicate := &schemaPredicate{
+		constraints:       &schemaConstraints{},
+		dependentRequired: map[string][]string{"tls": {"cert", "key"}},
+		dependentSchemas: map[string][]*schemaPredicate{
+			"port": {{constraints: &schemaConstraints{}, properties: map[string]*schemaPredicate{
+				"port": {constraints: &schemaConstraints{minimum: &numericBound{value: 1024}}},
+			}}},
+		},
+	}
+
+	// On a value, keys are looked up when it is a dict
+	assert.Equal(t, []string{
+		`("port" not in v or v["port"] >= 1024) if v != None and typeof(v) == "dict" and "port" in v`,
+		`"cert" in v if v != None and typeof(v) == "dict" and "tls" in v`,
+		`"key" in v if v != None and typeof(v) == "dict" and "tls" in v`,
+	}, predicate.dependencyChecks("v", nil, "v != None"))
+
+	// On a schema instance, required attributes are always set and undeclared keys are skipped
+	instance := &schemaInstance{attributes: map[string]bool{"port": true, "tls": true, "cert": true}, required: []string{"port"}}
+	assert.Equal(t, []string{"port >= 1024", "cert != None if tls != None"}, predicate.dependencyChecks("", instance, ""))
+
+	// Within a predicate, dependencies hold unless their key is absent
+	assert.Equal(t, `(typeof(v) == "dict" and ("port" not in v or ("port" not in v or v["port"] >= 1024)) and ("tls" not in v or ("cert" in v and "key" in v)))`,
+		(&schemaPredicate{constraints: &schemaConstraints{types: []string{"object"}},
+			dependentRequired: predicate.dependentRequired, dependentSchemas: predicate.dependentSchemas}).expression("v"))
+}
+
+func TestGenerateOpenAPIDependencies(t *testing.T) {
+	tempDir := t.TempDir()
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/dependencies.yaml", LoadOptions{FlattenSpec: true})
+	require.NoError(t, err)
+	require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "Listener.k"))
+	require.NoError(t, err)
+	listener := string(content)
+	assert.Contains(t, listener, "schema Listener(Endpoint):")
+	assert.Contains(t, listener, "cert != None if tls != None")
+	assert.Contains(t, listener, "key != None if tls != None")
+	assert.Contains(t, listener, "proxyPort != None if proxy != None")
+	assert.Contains(t, listener, `(proxyPort == None or (typeof(proxyPort) == "int" and proxyPort >= 1024)) if proxyPort != None`)
+	assert.NotContains(t, listener, "regex.match(host")
+
+	// Dependencies on a required property always apply
+	content, err = os.ReadFile(filepath.Join(tempDir, "Endpoint.k"))
+	require.NoError(t, err)
+	endpoint := string(content)
+	assert.Contains(t, endpoint, `regex.match(host, r"^[a-z.]+$")`)
+
+	// A primitive component is a type alias, checked where it is used
+	assert.Contains(t, endpoint, "port?: Port")
+	assert.Contains(t, endpoint, "port >= 1024 if port != None")
+	content, err = os.ReadFile(filepath.Join(tempDir, "Port.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "type Port = int")
+	assert.NotContains(t, string(content), "schema Port")
+}
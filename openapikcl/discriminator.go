@@ -0,0 +1,176 @@
+package openapikcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// taggedUnion describes a schema with a discriminator and the subtypes it selects between
+type taggedUnion struct {
+	propertyName string
+	subtypes     []taggedSubtype // sorted by discriminator value
+}
+
+// taggedSubtype is a subtype of a tagged union and the discriminator value selecting it
+type taggedSubtype struct {
+	value  string
+	schema string
+}
+
+// resolveTaggedUnion resolves the subtypes of a schema with a discriminator.
+// Subtypes come from the discriminator mapping, then from oneOf/anyOf references named by
+// their schema name, and finally from components that extend the parent through allOf.
+func resolveTaggedUnion(parentName string, schema *openapi3.Schema, allSchemas openapi3.Schemas) *taggedUnion {
+	if schema == nil || schema.Discriminator == nil || schema.Discriminator.PropertyName == "" {
+		return nil
+	}
+
+	union := &taggedUnion{propertyName: schema.Discriminator.PropertyName}
+	mapped := make(map[string]bool)
+	for value, ref := range schema.Discriminator.Mapping {
+		subtype := formatSchemaName(extractSchemaName(ref))
+		union.subtypes = append(union.subtypes, taggedSubtype{value: value, schema: subtype})
+		mapped[subtype] = true
+	}
+
+	// Without a mapping entry, a subtype is selected by its schema name
+	addImplicit := func(name string) {
+		subtype := formatSchemaName(name)
+		if name == "" || mapped[subtype] || subtype == formatSchemaName(parentName) {
+			return
+		}
+		union.subtypes = append(union.subtypes, taggedSubtype{value: name, schema: subtype})
+		mapped[subtype] = true
+	}
+	for _, branch := range compositionBranches(schema) {
+		if branch != nil && branch.Ref != "" {
+			addImplicit(extractSchemaName(branch.Ref))
+		}
+	}
+	if len(compositionBranches(schema)) == 0 {
+		for _, name := range collectSchemas(allSchemas) {
+			if extendsSchema(allSchemas[name], parentName) {
+				addImplicit(name)
+			}
+		}
+	}
+
+	if len(union.subtypes) == 0 {
+		return nil
+	}
+	sort.Slice(union.subtypes, func(i, j int) bool {
+		return union.subtypes[i].value < union.subtypes[j].value
+	})
+	return union
+}
+
+// extendsSchema reports whether a schema lists a reference to parentName in its allOf
+func extendsSchema(schemaRef *openapi3.SchemaRef, parentName string) bool {
+	if schemaRef == nil || schemaRef.Value == nil {
+		return false
+	}
+	for _, member := range schemaRef.Value.AllOf {
+		if member != nil && member.Ref != "" && extractSchemaName(member.Ref) == parentName {
+			return true
+		}
+	}
+	return false
+}
+
+// unionType returns the KCL union of the subtypes, e.g. "Cat | Dog"
+func (u *taggedUnion) unionType() string {
+	var members []string
+	seen := make(map[string]bool)
+	for _, subtype := range u.subtypes {
+		if !seen[subtype.schema] {
+			seen[subtype.schema] = true
+			members = append(members, subtype.schema)
+		}
+	}
+	return strings.Join(members, " | ")
+}
+
+// branchCheck returns a check expression tying the discriminator value of value to its schema
+func (u *taggedUnion) branchCheck(value string) string {
+	var entries []string
+	for _, subtype := range u.subtypes {
+		entries = append(entries, fmt.Sprintf("%q: %q", subtype.value, subtype.schema))
+	}
+	return fmt.Sprintf("typeof(%s) == {%s}[%s.%s]", value, strings.Join(entries, ", "), value, u.propertyName)
+}
+
+// discriminatorLiterals returns the literal values each tagged union assigns to the given subtype,
+// keyed by discriminator property name
+func discriminatorLiterals(name string, allSchemas openapi3.Schemas) map[string][]string {
+	literals := make(map[string][]string)
+	for _, parentName := range collectSchemas(allSchemas) {
+		parent := allSchemas[parentName]
+		if parent == nil || parent.Value == nil || parent.Value.Discriminator == nil {
+			continue
+		}
+		union := resolveTaggedUnion(parentName, parent.Value, allSchemas)
+		if union == nil {
+			continue
+		}
+		for _, subtype := range union.subtypes {
+			if subtype.schema == formatSchemaName(name) && !contains(literals[union.propertyName], subtype.value) {
+				literals[union.propertyName] = append(literals[union.propertyName], subtype.value)
+			}
+		}
+	}
+	return literals
+}
+
+// literalUnionType formats string values as a KCL literal union type, e.g. "cat" | "kitty"
+func literalUnionType(values []string) string {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(literals, " | ")
+}
+
+// taggedUnionField returns the tagged union a field refers to, directly or as array items
+func taggedUnionField(fieldSchema *openapi3.SchemaRef, allSchemas openapi3.Schemas) (*taggedUnion, bool) {
+	if fieldSchema == nil || fieldSchema.Value == nil {
+		return nil, false
+	}
+	if fieldSchema.Ref != "" {
+		union := resolveTaggedUnion(extractSchemaName(fieldSchema.Ref), fieldSchema.Value, allSchemas)
+		return union, false
+	}
+	items := fieldSchema.Value.Items
+	if items != nil && items.Ref != "" && items.Value != nil {
+		union := resolveTaggedUnion(extractSchemaName(items.Ref), items.Value, allSchemas)
+		return union, true
+	}
+	return nil, false
+}
+
+// taggedUnionCheck returns a check tying the discriminator of a field, or of each of its items, to the chosen subtype
+func taggedUnionCheck(fieldName string, fieldSchema *openapi3.SchemaRef, allSchemas openapi3.Schemas, isRequired bool) string {
+	union, isArray := taggedUnionField(fieldSchema, allSchemas)
+	if union == nil {
+		return ""
+	}
+
+	check := union.branchCheck(fieldName)
+	if isArray {
+		check = fmt.Sprintf("all _item in %s { %s }", fieldName, union.branchCheck("_item"))
+	}
+	if !isRequired {
+		check += fmt.Sprintf(" if %s != None", fieldName)
+	}
+	return check + fmt.Sprintf(", \"%s must match the schema selected by %s\"", fieldName, union.propertyName)
+}
+
+// componentSchemas returns the component schemas of a document, or nil if it has none
+func componentSchemas(doc *openapi3.T) openapi3.Schemas {
+	if doc == nil || doc.Components == nil {
+		return nil
+	}
+	return doc.Components.Schemas
+}
@@ -0,0 +1,310 @@
+package openapikcl
+
+import (
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Flattening transforms the schemas of a document wherever they appear: in components, parameters,
+// headers, request bodies, responses, callbacks and paths. Every object containing a schema is
+// copied before it is changed, so the input document is left as it was. Everything else, such as
+// servers, security schemes, tags, examples, links and extensions, is kept untouched. Parameters,
+// responses and the like from other documents are inlined, so the result does not depend on other files.
+
+// flattenComponents flattens the schemas of the components other than the component schemas
+func (f *Flattener) flattenComponents(components *openapi3.Components) error {
+	if components.Parameters != nil {
+		parameters := make(openapi3.ParametersMap, len(components.Parameters))
+		for _, name := range sortedKeys(components.Parameters) {
+			flat, err := f.flattenParameterRef(components.Parameters[name])
+			if err != nil {
+				return fmt.Errorf("failed to flatten parameter %s: %w", name, err)
+			}
+			parameters[name] = flat
+		}
+		components.Parameters = parameters
+	}
+
+	headers, err := f.flattenHeaders(components.Headers)
+	if err != nil {
+		return err
+	}
+	components.Headers = headers
+
+	if components.RequestBodies != nil {
+		requestBodies := make(openapi3.RequestBodies, len(components.RequestBodies))
+		for _, name := range sortedKeys(components.RequestBodies) {
+			flat, err := f.flattenRequestBodyRef(components.RequestBodies[name])
+			if err != nil {
+				return fmt.Errorf("failed to flatten request body %s: %w", name, err)
+			}
+			requestBodies[name] = flat
+		}
+		components.RequestBodies = requestBodies
+	}
+
+	if components.Responses != nil {
+		responses := make(openapi3.ResponseBodies, len(components.Responses))
+		for _, name := range sortedKeys(components.Responses) {
+			flat, err := f.flattenResponseRef(components.Responses[name])
+			if err != nil {
+				return fmt.Errorf("failed to flatten response %s: %w", name, err)
+			}
+			responses[name] = flat
+		}
+		components.Responses = responses
+	}
+
+	callbacks, err := f.flattenCallbacks(components.Callbacks)
+	if err != nil {
+		return err
+	}
+	components.Callbacks = callbacks
+	return nil
+}
+
+// keptRef returns the reference a flattened parameter, header, request body, response or callback keeps.
+// References to other documents are inlined, and so is every reference when dereferencing.
+func (f *Flattener) keptRef(ref string) string {
+	if ref == "" || f.opts.Mode == BundleModeDereference || !isLocalRef(ref) {
+		return ""
+	}
+	return ref
+}
+
+// flattenPaths flattens the schemas of every operation of every path
+func (f *Flattener) flattenPaths(paths *openapi3.Paths) (*openapi3.Paths, error) {
+	if paths == nil {
+		return nil, nil
+	}
+
+	flatPaths := openapi3.NewPathsWithCapacity(paths.Len())
+	flatPaths.Extensions = paths.Extensions
+	flatPaths.Origin = paths.Origin
+	for _, path := range paths.InMatchingOrder() {
+		flat, err := f.flattenPathItem(paths.Value(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten path %s: %w", path, err)
+		}
+		flatPaths.Set(path, flat)
+	}
+	return flatPaths, nil
+}
+
+// flattenPathItem flattens the schemas of a path item and its operations
+func (f *Flattener) flattenPathItem(pathItem *openapi3.PathItem) (*openapi3.PathItem, error) {
+	if pathItem == nil {
+		return nil, nil
+	}
+
+	flat := *pathItem
+	parameters, err := f.flattenParameters(pathItem.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	flat.Parameters = parameters
+
+	operations := pathItem.Operations()
+	for _, method := range sortedKeys(operations) {
+		operation, err := f.flattenOperation(operations[method])
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten %s operation: %w", method, err)
+		}
+		flat.SetOperation(method, operation)
+	}
+	return &flat, nil
+}
+
+// flattenOperation flattens the schemas of the parameters, request body, responses and callbacks of an operation
+func (f *Flattener) flattenOperation(operation *openapi3.Operation) (*openapi3.Operation, error) {
+	flat := *operation
+
+	parameters, err := f.flattenParameters(operation.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	flat.Parameters = parameters
+
+	if flat.RequestBody, err = f.flattenRequestBodyRef(operation.RequestBody); err != nil {
+		return nil, fmt.Errorf("failed to flatten request body: %w", err)
+	}
+
+	if operation.Responses != nil {
+		responses := openapi3.NewResponsesWithCapacity(operation.Responses.Len())
+		responses.Extensions = operation.Responses.Extensions
+		responses.Origin = operation.Responses.Origin
+		for _, code := range sortedKeys(operation.Responses.Map()) {
+			response, err := f.flattenResponseRef(operation.Responses.Value(code))
+			if err != nil {
+				return nil, fmt.Errorf("failed to flatten response %s: %w", code, err)
+			}
+			responses.Set(code, response)
+		}
+		flat.Responses = responses
+	}
+
+	if flat.Callbacks, err = f.flattenCallbacks(operation.Callbacks); err != nil {
+		return nil, err
+	}
+	return &flat, nil
+}
+
+// flattenCallbacks flattens the path items of callbacks
+func (f *Flattener) flattenCallbacks(callbacks openapi3.Callbacks) (openapi3.Callbacks, error) {
+	if callbacks == nil {
+		return nil, nil
+	}
+
+	flatCallbacks := make(openapi3.Callbacks, len(callbacks))
+	for _, name := range sortedKeys(callbacks) {
+		callbackRef := callbacks[name]
+		if callbackRef == nil || callbackRef.Value == nil {
+			flatCallbacks[name] = callbackRef
+			continue
+		}
+
+		callback := openapi3.NewCallbackWithCapacity(callbackRef.Value.Len())
+		callback.Extensions = callbackRef.Value.Extensions
+		callback.Origin = callbackRef.Value.Origin
+		for _, expression := range sortedKeys(callbackRef.Value.Map()) {
+			pathItem, err := f.flattenPathItem(callbackRef.Value.Value(expression))
+			if err != nil {
+				return nil, fmt.Errorf("failed to flatten callback %s: %w", name, err)
+			}
+			callback.Set(expression, pathItem)
+		}
+		flatCallbacks[name] = &openapi3.CallbackRef{Ref: f.keptRef(callbackRef.Ref), Value: callback}
+	}
+	return flatCallbacks, nil
+}
+
+// flattenParameters flattens the schemas of a list of parameters
+func (f *Flattener) flattenParameters(parameters openapi3.Parameters) (openapi3.Parameters, error) {
+	if parameters == nil {
+		return nil, nil
+	}
+
+	flatParameters := make(openapi3.Parameters, 0, len(parameters))
+	for i, parameter := range parameters {
+		flat, err := f.flattenParameterRef(parameter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten parameter %d: %w", i, err)
+		}
+		flatParameters = append(flatParameters, flat)
+	}
+	return flatParameters, nil
+}
+
+// flattenParameterRef flattens the schema and content of a parameter
+func (f *Flattener) flattenParameterRef(parameterRef *openapi3.ParameterRef) (*openapi3.ParameterRef, error) {
+	if parameterRef == nil || parameterRef.Value == nil {
+		return parameterRef, nil
+	}
+
+	parameter, err := f.flattenParameter(parameterRef.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &openapi3.ParameterRef{Ref: f.keptRef(parameterRef.Ref), Value: parameter}, nil
+}
+
+// flattenParameter flattens the schema and content of a parameter or header
+func (f *Flattener) flattenParameter(parameter *openapi3.Parameter) (*openapi3.Parameter, error) {
+	flat := *parameter
+
+	schema, err := f.flattenSchemaRef(parameter.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to flatten schema of %s: %w", parameter.Name, err)
+	}
+	flat.Schema = schema
+
+	if flat.Content, err = f.flattenContent(parameter.Content); err != nil {
+		return nil, err
+	}
+	return &flat, nil
+}
+
+// flattenHeaders flattens the schemas of headers
+func (f *Flattener) flattenHeaders(headers openapi3.Headers) (openapi3.Headers, error) {
+	if headers == nil {
+		return nil, nil
+	}
+
+	flatHeaders := make(openapi3.Headers, len(headers))
+	for _, name := range sortedKeys(headers) {
+		headerRef := headers[name]
+		if headerRef == nil || headerRef.Value == nil {
+			flatHeaders[name] = headerRef
+			continue
+		}
+
+		parameter, err := f.flattenParameter(&headerRef.Value.Parameter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten header %s: %w", name, err)
+		}
+		flatHeaders[name] = &openapi3.HeaderRef{Ref: f.keptRef(headerRef.Ref), Value: &openapi3.Header{Parameter: *parameter}}
+	}
+	return flatHeaders, nil
+}
+
+// flattenRequestBodyRef flattens the content schemas of a request body
+func (f *Flattener) flattenRequestBodyRef(requestBodyRef *openapi3.RequestBodyRef) (*openapi3.RequestBodyRef, error) {
+	if requestBodyRef == nil || requestBodyRef.Value == nil {
+		return requestBodyRef, nil
+	}
+
+	requestBody := *requestBodyRef.Value
+	content, err := f.flattenContent(requestBodyRef.Value.Content)
+	if err != nil {
+		return nil, err
+	}
+	requestBody.Content = content
+	return &openapi3.RequestBodyRef{Ref: f.keptRef(requestBodyRef.Ref), Value: &requestBody}, nil
+}
+
+// flattenResponseRef flattens the content and header schemas of a response
+func (f *Flattener) flattenResponseRef(responseRef *openapi3.ResponseRef) (*openapi3.ResponseRef, error) {
+	if responseRef == nil || responseRef.Value == nil {
+		return responseRef, nil
+	}
+
+	response := *responseRef.Value
+	content, err := f.flattenContent(responseRef.Value.Content)
+	if err != nil {
+		return nil, err
+	}
+	response.Content = content
+
+	headers, err := f.flattenHeaders(responseRef.Value.Headers)
+	if err != nil {
+		return nil, err
+	}
+	response.Headers = headers
+	return &openapi3.ResponseRef{Ref: f.keptRef(responseRef.Ref), Value: &response}, nil
+}
+
+// flattenContent flattens the schema of each media type
+func (f *Flattener) flattenContent(content openapi3.Content) (openapi3.Content, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	flatContent := make(openapi3.Content, len(content))
+	for _, mediaType := range sortedKeys(content) {
+		value := content[mediaType]
+		if value == nil {
+			flatContent[mediaType] = nil
+			continue
+		}
+
+		flat := *value
+		schema, err := f.flattenSchemaRef(value.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten %s schema: %w", mediaType, err)
+		}
+		flat.Schema = schema
+		flatContent[mediaType] = &flat
+	}
+	return flatContent, nil
+}
@@ -0,0 +1,59 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// enumLiteralType returns the KCL literal union type of an enum of scalars, e.g. "available" | "sold".
+// It returns false for enums containing arrays or objects, or with more values than opts.MaxEnumLiterals,
+// which are validated with an "in" check instead.
+func enumLiteralType(values []interface{}, opts GenerateOptions) (string, bool) {
+	if len(values) == 0 || (opts.MaxEnumLiterals > 0 && len(values) > opts.MaxEnumLiterals) {
+		return "", false
+	}
+
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literal, ok := kclLiteral(value)
+		if !ok {
+			return "", false
+		}
+		literals = append(literals, literal)
+	}
+	return unionOf(literals), true
+}
+
+// kclLiteral formats a scalar JSON value as a KCL literal
+func kclLiteral(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "None", true
+	case string:
+		return strconv.Quote(v), true
+	case bool:
+		if v {
+			return "True", true
+		}
+		return "False", true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int, int64, uint64:
+		return fmt.Sprintf("%d", v), true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+// isEnumSchema reports whether an OpenAPI schema is an enum of scalars, which is generated as a type alias
+func isEnumSchema(schema *openapi3.Schema) bool {
+	if schema == nil || len(schema.Enum) == 0 || len(schema.Properties) > 0 {
+		return false
+	}
+	return !schema.Type.Includes("object") && !schema.Type.Includes("array")
+}
@@ -0,0 +1,127 @@
+package openapikcl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Remote documents are fetched through a Fetcher, so library users can inject their own transport,
+// e.g. one reading from an artifact store. The default HTTPFetcher bounds every request with a timeout
+// and a maximum body size, can send headers such as Authorization to private schema registries, and can
+// restrict the hosts documents are fetched from.
+
+const (
+	// DefaultFetchTimeout bounds each request of an HTTPFetcher without a Timeout
+	DefaultFetchTimeout = 30 * time.Second
+	// DefaultMaxBodySize bounds the documents an HTTPFetcher without a MaxBodySize accepts
+	DefaultMaxBodySize = 32 << 20
+)
+
+// Fetcher retrieves the remote documents references point to
+type Fetcher interface {
+	Fetch(url string) ([]byte, error)
+}
+
+// HTTPFetcher fetches remote documents over HTTP
+type HTTPFetcher struct {
+	Client       *http.Client  // Client sending the requests; nil uses a client following only redirects to allowed hosts
+	Headers      http.Header   // Headers sent with every request, e.g. Authorization for private schema registries
+	Timeout      time.Duration // Timeout of each request; zero means DefaultFetchTimeout
+	MaxBodySize  int64         // Maximum size of a document in bytes; zero means DefaultMaxBodySize
+	AllowedHosts []string      // Hosts documents may be fetched from, with an optional port; empty allows every host
+}
+
+// Fetch retrieves the document at a URL
+func (h *HTTPFetcher) Fetch(location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", location, err)
+	}
+	if !h.allowsHost(u) {
+		return nil, fmt.Errorf("host %s of %s is not allowed", u.Host, location)
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", location, err)
+	}
+	for name, values := range h.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", location, resp.Status)
+	}
+
+	// Read one byte past the limit to tell a document of exactly the maximum size from a larger one
+	maxBodySize := h.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body of %s: %w", location, err)
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, fmt.Errorf("document %s exceeds the maximum size of %d bytes", location, maxBodySize)
+	}
+	return body, nil
+}
+
+// CloseIdleConnections closes the idle connections of the client
+func (h *HTTPFetcher) CloseIdleConnections() {
+	if h.Client != nil {
+		h.Client.CloseIdleConnections()
+	}
+}
+
+// client returns the client sending the requests, creating the default one on first use
+func (h *HTTPFetcher) client() *http.Client {
+	if h.Client == nil {
+		h.Client = &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if !h.allowsHost(req.URL) {
+					return fmt.Errorf("redirect to host %s is not allowed", req.URL.Host)
+				}
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				return nil
+			},
+		}
+	}
+	return h.Client
+}
+
+// allowsHost reports whether documents may be fetched from the host of a URL
+func (h *HTTPFetcher) allowsHost(u *url.URL) bool {
+	if len(h.AllowedHosts) == 0 {
+		return true
+	}
+	for _, host := range h.AllowedHosts {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
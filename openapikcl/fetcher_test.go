@@ -0,0 +1,151 @@
+package openapikcl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFetcher(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doc.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "type: string\n")
+	})
+	mux.HandleFunc("/large.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	})
+	mux.HandleFunc("/slow.yaml", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/away.yaml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://elsewhere.example.com/doc.yaml", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	host, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	headers := http.Header{"Authorization": {"Bearer secret"}}
+	tests := []struct {
+		name    string
+		fetcher *HTTPFetcher
+		path    string
+		err     string
+	}{
+		{name: "headers", fetcher: &HTTPFetcher{Headers: headers}, path: "/doc.yaml"},
+		{name: "missing headers", fetcher: &HTTPFetcher{}, path: "/doc.yaml", err: "401 Unauthorized"},
+		{name: "not found", fetcher: &HTTPFetcher{}, path: "/missing.yaml", err: "404 Not Found"},
+		{name: "maximum size", fetcher: &HTTPFetcher{MaxBodySize: 100}, path: "/large.yaml"},
+		{name: "too large", fetcher: &HTTPFetcher{MaxBodySize: 99}, path: "/large.yaml", err: "exceeds the maximum size of 99 bytes"},
+		{name: "timeout", fetcher: &HTTPFetcher{Timeout: 50 * time.Millisecond}, path: "/slow.yaml", err: "deadline exceeded"},
+		{name: "allowed host", fetcher: &HTTPFetcher{Headers: headers, AllowedHosts: []string{host.Hostname()}}, path: "/doc.yaml"},
+		{name: "allowed host and port", fetcher: &HTTPFetcher{Headers: headers, AllowedHosts: []string{host.Host}}, path: "/doc.yaml"},
+		{name: "disallowed host", fetcher: &HTTPFetcher{AllowedHosts: []string{"schemas.example.com"}}, path: "/doc.yaml", err: "is not allowed"},
+		{name: "redirect to disallowed host", fetcher: &HTTPFetcher{AllowedHosts: []string{host.Hostname()}}, path: "/away.yaml", err: "redirect to host elsewhere.example.com is not allowed"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.fetcher.CloseIdleConnections()
+			body, err := tc.fetcher.Fetch(server.URL + tc.path)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, body)
+		})
+	}
+}
+
+// mapFetcher serves documents from memory
+type mapFetcher map[string]string
+
+func (m mapFetcher) Fetch(location string) ([]byte, error) {
+	if doc, ok := m[location]; ok {
+		return []byte(doc), nil
+	}
+	return nil, fmt.Errorf("no document at %s", location)
+}
+
+func TestLoadWithFetcher(t *testing.T) {
+	fetcher := mapFetcher{"https://unmapped.example.com/pet.yaml": "Pet:\n  type: object\n  properties:\n    name:\n      type: string\n"}
+
+	doc, _, err := LoadOpenAPISchema("testdata/catalog/unmapped.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+		Fetcher:     fetcher,
+	})
+	require.NoError(t, err)
+	pet := doc.Components.Schemas["Pet"]
+	require.NotNil(t, pet)
+	assert.Contains(t, pet.Value.Properties, "name")
+
+	// Errors of the fetcher are reported with the reference
+	_, _, err = LoadOpenAPISchema("testdata/catalog/unmapped.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+		Fetcher:     mapFetcher{},
+	})
+	assert.ErrorContains(t, err, "no document at https://unmapped.example.com/pet.yaml")
+}
+
+func TestSkipRemoteRefs(t *testing.T) {
+	// Skipped remote references load as schemas accepting any value
+	doc, _, err := LoadOpenAPISchema("testdata/catalog/unmapped.yaml", LoadOptions{
+		FlattenSpec: true,
+		SkipRemote:  true,
+		MaxDepth:    10,
+		Fetcher:     mapFetcher{},
+	})
+	require.NoError(t, err)
+	pet := doc.Components.Schemas["Pet"]
+	require.NotNil(t, pet)
+	require.NotNil(t, pet.Value)
+	assert.Equal(t, "https://unmapped.example.com/pet.yaml#/Pet", pet.Value.Extensions[skippedRefExtension])
+
+	tempDir := t.TempDir()
+	require.NoError(t, GenerateKCLSchemasWithOptions(doc, tempDir, "test", OpenAPIV3, nil, GenerateOptions{}))
+	assert.FileExists(t, filepath.Join(tempDir, "Pet.k"))
+
+	// The flattener does the same for documents built in code
+	built := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info:    &openapi3.Info{Title: "Built", Version: "1.0.0"},
+		Paths:   openapi3.NewPaths(),
+		Components: &openapi3.Components{Schemas: openapi3.Schemas{
+			"Owner": openapi3.NewSchemaRef("", openapi3.NewObjectSchema().WithPropertyRef("pet", &openapi3.SchemaRef{
+				Ref: "https://unmapped.example.com/pet.yaml#/Pet",
+			})),
+		}},
+	}
+	for _, mode := range []BundleMode{"", BundleModeBundle, BundleModeDereference} {
+		flattener := NewFlattener(FlattenOptions{SkipRemote: true, Mode: mode, Fetcher: mapFetcher{}}, built)
+		flat, err := flattener.FlattenSpec()
+		flattener.Close()
+		require.NoError(t, err, "mode %q", mode)
+
+		property := flat.Components.Schemas["Owner"].Value.Properties["pet"]
+		require.NotNil(t, property, "mode %q", mode)
+		require.NotNil(t, property.Value, "mode %q", mode)
+		assert.Empty(t, property.Ref, "mode %q", mode)
+		assert.Equal(t, "https://unmapped.example.com/pet.yaml#/Pet", property.Value.Extensions[skippedRefExtension], "mode %q", mode)
+		assert.Equal(t, []string{"Owner"}, collectSchemas(flat.Components.Schemas), "mode %q", mode)
+	}
+}
@@ -3,16 +3,44 @@ package openapikcl
 import (
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 
 	"github.com/getkin/kin-openapi/openapi3"
 )
 
 // FlattenOptions configures the flattening process
 type FlattenOptions struct {
-	BaseDir    string // Base directory for relative file references
-	MaxDepth   int    // Maximum depth for circular reference detection
-	SkipRemote bool   // Skip remote references if true
+	BaseDir          string     // Base directory for relative file references
+	MaxDepth         int        // Maximum depth for circular reference detection
+	SkipRemote       bool       // Skip remote references if true
+	Mode             BundleMode // How references are written; empty inlines schemas of other documents for generation
+	Catalog          *Catalog   // Local mirrors of remote documents, consulted before any network access
+	Offline          bool       // Fail on remote references that are not in the catalog instead of fetching them
+	Fetcher          Fetcher    // Fetches remote documents; nil uses an HTTPFetcher with its defaults
+	RootDir          string     // Directory file references must stay inside; empty means BaseDir
+	AllowOutsideRoot bool       // Allow file references outside RootDir, for trusted inputs
+}
+
+// BundleMode controls how the flattened document refers to the schemas references point to
+type BundleMode string
+
+const (
+	// BundleModeBundle adds the schemas of other documents as components and refers to them by name
+	BundleModeBundle BundleMode = "bundle"
+	// BundleModeDereference inlines every reference, keeping only those that close a cycle
+	BundleModeDereference BundleMode = "dereference"
+)
+
+// ParseBundleMode parses a bundle mode name
+func ParseBundleMode(name string) (BundleMode, error) {
+	switch mode := BundleMode(name); mode {
+	case BundleModeBundle, BundleModeDereference:
+		return mode, nil
+	case "":
+		return BundleModeBundle, nil
+	default:
+		return "", fmt.Errorf("unknown bundle mode %q, expected %q or %q", name, BundleModeBundle, BundleModeDereference)
+	}
 }
 
 // refContext tracks reference context
@@ -23,13 +51,22 @@ type refContext struct {
 
 // Flattener handles the flattening of OpenAPI specs
 type Flattener struct {
-	opts       FlattenOptions
-	seenRefs   map[string]bool
-	depth      int
-	httpClient *http.Client
-	doc        *openapi3.T // Add this field to store the original document
-	cache      map[string]refContext
-	refPath    []string // Track reference resolution path
+	opts      FlattenOptions
+	seenRefs  map[string]bool
+	depth     int
+	fetcher   Fetcher
+	doc       *openapi3.T // Add this field to store the original document
+	cache     map[string]refContext
+	documents map[string][]byte      // Referenced documents keyed by URL, read once
+	parsed    map[string]interface{} // Decoded referenced documents keyed by URL, parsed once
+	refPath   []string               // Track reference resolution path
+
+	base          *url.URL          // URI of the external document being flattened; nil for the root document
+	raw           interface{}       // Decoded external document being flattened
+	componentRefs map[string]string // Component schema names keyed by the external reference they consist of
+
+	targets      map[string]*componentTarget // Targets of references kept as references to a component, keyed by reference
+	addedSchemas openapi3.Schemas            // Flattened targets added as components, by component name
 }
 
 // NewFlattener creates a new Flattener instance
@@ -37,13 +74,23 @@ func NewFlattener(opts FlattenOptions, doc *openapi3.T) *Flattener {
 	if opts.MaxDepth == 0 {
 		opts.MaxDepth = 100 // reasonable default
 	}
+	if opts.Fetcher == nil {
+		opts.Fetcher = &HTTPFetcher{}
+	}
 
 	return &Flattener{
-		opts:       opts,
-		seenRefs:   make(map[string]bool),
-		httpClient: &http.Client{},
-		doc:        doc,
-		cache:      make(map[string]refContext),
+		opts:      opts,
+		seenRefs:  make(map[string]bool),
+		fetcher:   opts.Fetcher,
+		doc:       doc,
+		cache:     make(map[string]refContext),
+		documents: make(map[string][]byte),
+		parsed:    make(map[string]interface{}),
+
+		componentRefs: make(map[string]string),
+
+		targets:      make(map[string]*componentTarget),
+		addedSchemas: make(openapi3.Schemas),
 	}
 }
 
@@ -55,48 +102,101 @@ func (f *Flattener) FlattenSpec() (*openapi3.T, error) {
 		return nil, fmt.Errorf("no OpenAPI document provided")
 	}
 
-	if f.doc.Components == nil || f.doc.Components.Schemas == nil {
-		log.Println("no schemas to flatten")
-		return f.doc, nil
-	}
-
-	// Create a new document to store the flattened spec
-	flatDoc := &openapi3.T{
-		OpenAPI: f.doc.OpenAPI,
-		Info:    f.doc.Info,
-		Components: &openapi3.Components{
-			Schemas: make(openapi3.Schemas),
-		},
+	// The document is copied as a whole, so every section not containing schemas is kept as it is
+	flatDoc := *f.doc
+	var components openapi3.Components
+	if f.doc.Components != nil {
+		components = *f.doc.Components
 	}
+	schemas := components.Schemas
+	components.Schemas = make(openapi3.Schemas)
+	flatDoc.Components = &components
 
 	// Get a sorted list of schema names for deterministic processing
-	schemaNames := collectSchemas(f.doc.Components.Schemas)
+	schemaNames := collectSchemas(schemas)
 	log.Printf("processing schemas in order: %v", schemaNames)
 
+	// Components that consist of a schema in another document are referenced in its place
+	for _, name := range schemaNames {
+		if schema := schemas[name]; schema != nil && schema.Ref != "" && !isLocalRef(schema.Ref) {
+			f.componentRefs[f.refKey(schema.Ref)] = name
+		}
+	}
+
 	// Process schemas in sorted order
 	for _, name := range schemaNames {
-		schema := f.doc.Components.Schemas[name]
+		schema := schemas[name]
 		log.Printf("flattening schema: %s", name)
 
-		flatSchema, err := f.flattenSchemaRef(schema)
+		// A component is being resolved while it is flattened, so references back to it close a cycle
+		key := "#/components/schemas/" + escapePointerToken(name)
+		f.seenRefs[key] = true
+		f.refPath = append(f.refPath, key)
+		flatSchema, err := f.flattenComponent(schema)
+		delete(f.seenRefs, key)
+		f.refPath = f.refPath[:len(f.refPath)-1]
 		if err != nil {
 			return nil, fmt.Errorf("failed to flatten schema %s: %w", name, err)
 		}
+		f.completeRecursiveRef(key, flatSchema)
 
 		flatDoc.Components.Schemas[name] = flatSchema
 	}
 
+	// Schemas of the other components and of the operations are flattened where they appear
+	if err := f.flattenComponents(flatDoc.Components); err != nil {
+		return nil, err
+	}
+	paths, err := f.flattenPaths(f.doc.Paths)
+	if err != nil {
+		return nil, err
+	}
+	flatDoc.Paths = paths
+
+	// Targets of recursive or bundled references that are not components yet become components
+	for _, name := range collectSchemas(f.addedSchemas) {
+		if _, ok := flatDoc.Components.Schemas[name]; !ok {
+			log.Printf("adding schema %s as a component", name)
+			flatDoc.Components.Schemas[name] = f.addedSchemas[name]
+		}
+	}
+
 	// Log information about all resolved references
 	f.cacheReferences()
 
 	log.Printf("flattened %d schemas successfully", len(flatDoc.Components.Schemas))
-	return flatDoc, nil
+	if f.doc.Components == nil && len(flatDoc.Components.Schemas) == 0 {
+		flatDoc.Components = nil
+	}
+	return &flatDoc, nil
+}
+
+// flattenComponent flattens a component schema. A component consisting of a schema in another
+// document takes that schema as its own, so it is not generated as a reference to itself.
+func (f *Flattener) flattenComponent(schema *openapi3.SchemaRef) (*openapi3.SchemaRef, error) {
+	if schema == nil || schema.Ref == "" || isLocalRef(schema.Ref) {
+		return f.flattenSchemaRef(schema)
+	}
+	resolved, err := f.resolveReference(schema.Ref)
+	if err != nil {
+		return nil, err
+	}
+	if resolved == nil {
+		return skippedRef(f.refKey(schema.Ref)), nil
+	}
+	flat := &openapi3.SchemaRef{Value: resolved.Value}
+	f.completeRecursiveRef(f.refKey(schema.Ref), flat)
+	return flat, nil
 }
 
 // Close cleans up temporary resources
 func (f *Flattener) Close() error {
-	f.httpClient.CloseIdleConnections()
+	if closer, ok := f.fetcher.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
 	f.cache = nil
+	f.documents = nil
+	f.parsed = nil
 	f.seenRefs = nil
 	return nil
 }
@@ -0,0 +1,205 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeSpecURL is the location the large AS3 schema is fetched from by a countingFetcher
+const largeSpecURL = "https://schemas.example.com/"
+
+// largeSpec returns a document with a component for each definition of the large AS3 schema,
+// referenced from the components as the external document at location. Definitions using JSON
+// Schema constructs the flattener rejects, such as required properties declared by another
+// branch, are left out.
+func largeSpec(tb testing.TB, location string, opts FlattenOptions) *openapi3.T {
+	data, err := os.ReadFile("testdata/json/as3/input.json")
+	require.NoError(tb, err)
+	var raw struct {
+		Definitions map[string]json.RawMessage `json:"definitions"`
+	}
+	require.NoError(tb, json.Unmarshal(data, &raw))
+
+	doc := &openapi3.T{
+		OpenAPI:    "3.0.3",
+		Info:       &openapi3.Info{Title: "AS3", Version: "1.0.0"},
+		Components: &openapi3.Components{Schemas: make(openapi3.Schemas)},
+	}
+	probe := NewFlattener(opts, doc)
+	defer probe.Close()
+	for name := range raw.Definitions {
+		ref := location + "#/definitions/" + escapePointerToken(name)
+		if _, err := probe.resolveReference(ref); err == nil {
+			doc.Components.Schemas[name] = &openapi3.SchemaRef{Ref: ref}
+		}
+	}
+	require.NotEmpty(tb, doc.Components.Schemas)
+	return doc
+}
+
+// countingFetcher serves the files of a directory as the documents under largeSpecURL and counts the fetches of each URL
+type countingFetcher struct {
+	dir     string
+	fetches map[string]int
+}
+
+func newCountingFetcher(dir string) *countingFetcher {
+	return &countingFetcher{dir: dir, fetches: make(map[string]int)}
+}
+
+func (c *countingFetcher) Fetch(location string) ([]byte, error) {
+	c.fetches[location]++
+	return os.ReadFile(filepath.Join(c.dir, filepath.FromSlash(strings.TrimPrefix(location, largeSpecURL))))
+}
+
+func TestFlattenSpecCachesReferences(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	doc := largeSpec(t, "as3/input.json", FlattenOptions{BaseDir: "testdata/json"})
+	flattener := NewFlattener(FlattenOptions{BaseDir: "testdata/json"}, doc)
+	defer flattener.Close()
+
+	flatDoc, err := flattener.FlattenSpec()
+	require.NoError(t, err)
+	require.Len(t, flatDoc.Components.Schemas, len(doc.Components.Schemas))
+
+	// The external document is parsed once, and every reference into it is resolved once
+	require.Len(t, flattener.parsed, 1)
+	for key, cached := range flattener.cache {
+		require.NotNil(t, cached.schema, key)
+	}
+	require.Contains(t, flattener.cache, flattener.refKey("as3/input.json#/definitions/ADC"))
+}
+
+func BenchmarkFlattenSpec(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	doc := largeSpec(b, largeSpecURL+"as3/input.json", FlattenOptions{Fetcher: newCountingFetcher("testdata/json")})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fetcher := newCountingFetcher("testdata/json")
+		flattener := NewFlattener(FlattenOptions{Fetcher: fetcher}, doc)
+		if _, err := flattener.FlattenSpec(); err != nil {
+			b.Fatal(err)
+		}
+		flattener.Close()
+
+		// However many references point into it, each external document is fetched once
+		if len(fetcher.fetches) == 0 {
+			b.Fatal("no external document was fetched")
+		}
+		for location, count := range fetcher.fetches {
+			if count != 1 {
+				b.Fatalf("%s was fetched %d times", location, count)
+			}
+		}
+	}
+}
+
+func TestFlattenSpecPreservesDocument(t *testing.T) {
+	doc, _, err := LoadOpenAPISchema("testdata/oas/input/document.yaml", LoadOptions{})
+	require.NoError(t, err)
+
+	flattener := NewFlattener(FlattenOptions{BaseDir: "testdata/oas/input"}, doc)
+	defer flattener.Close()
+	flatDoc, err := flattener.FlattenSpec()
+	require.NoError(t, err)
+
+	// Sections without schemas are kept as they are
+	assert.Equal(t, doc.Info, flatDoc.Info)
+	assert.Equal(t, doc.Servers, flatDoc.Servers)
+	assert.Equal(t, doc.Security, flatDoc.Security)
+	assert.Equal(t, doc.Tags, flatDoc.Tags)
+	assert.Equal(t, doc.ExternalDocs, flatDoc.ExternalDocs)
+	assert.Equal(t, "internal", flatDoc.Extensions["x-audience"])
+	assert.Equal(t, doc.Components.SecuritySchemes, flatDoc.Components.SecuritySchemes)
+	assert.Equal(t, doc.Components.Examples, flatDoc.Components.Examples)
+	assert.Contains(t, flatDoc.Components.Schemas, "Visit")
+
+	// Schemas are flattened wherever they appear
+	petID := flatDoc.Components.Parameters["PetId"].Value.Schema
+	assert.Empty(t, petID.Ref)
+	require.NotNil(t, petID.Value.Min)
+	assert.Equal(t, 1.0, *petID.Value.Min)
+
+	pathItem := flatDoc.Paths.Value("/pets/{petId}")
+	require.NotNil(t, pathItem)
+	assert.Equal(t, "#/components/parameters/PetId", pathItem.Parameters[0].Ref)
+	assert.Empty(t, pathItem.Parameters[0].Value.Schema.Ref)
+
+	ok := pathItem.Get.Responses.Value("200").Value
+	pet := ok.Content["application/json"].Schema
+	assert.Empty(t, pet.Ref)
+	assert.Empty(t, pet.Value.Properties["id"].Ref)
+	assert.Empty(t, ok.Headers["X-Rate-Limit"].Value.Schema.Ref)
+	assert.Equal(t, "#/components/responses/Error", pathItem.Get.Responses.Value("default").Ref)
+
+	// References to component schemas stay references
+	visit := pathItem.Put.RequestBody.Value.Content["application/json"].Schema
+	assert.Equal(t, "#/components/schemas/Visit", visit.Ref)
+
+	callback := pathItem.Put.Callbacks["visited"].Value.Value("{$request.body#/callback}")
+	require.NotNil(t, callback)
+	assert.Empty(t, callback.Post.RequestBody.Value.Content["application/json"].Schema.Ref)
+
+	// The input document is left as it was
+	original := doc.Paths.Value("/pets/{petId}").Get.Responses.Value("200").Value.Content["application/json"].Schema
+	assert.Equal(t, "./split/models/pet.yaml#/Pet", original.Ref)
+	assert.Equal(t, "./split/common/id.yaml", doc.Components.Parameters["PetId"].Value.Schema.Ref)
+}
+
+func TestFlattenSpecDiscriminatorMappings(t *testing.T) {
+	doc, _, err := LoadOpenAPISchema("testdata/input/complex.json", LoadOptions{FlattenSpec: true})
+	require.NoError(t, err)
+
+	// Mixed maps to subtypes extending it, which close a cycle and keep their names
+	mixed := doc.Components.Schemas["Mixed"].Value
+	require.NotNil(t, mixed.Discriminator)
+	assert.Equal(t, openapi3.StringMap{
+		"complex": "#/components/schemas/Complex",
+		"simple":  "#/components/schemas/Simple",
+	}, mixed.Discriminator.Mapping)
+
+	tests := []struct {
+		name    string
+		mapping string
+		want    string
+		wantErr string
+	}{
+		{name: "self mapping", mapping: "Pet", want: "#/components/schemas/Pet"},
+		{name: "subtype", mapping: "#/components/schemas/Cat", want: "#/components/schemas/Cat"},
+		{name: "unresolved", mapping: "Dog", wantErr: `discriminator mapping "pet" -> "#/components/schemas/Dog"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := `{"openapi": "3.0.3", "info": {"title": "Pets", "version": "1.0.0"}, "paths": {}, "components": {"schemas": {
+				"Pet": {"type": "object", "properties": {"kind": {"type": "string"}},
+					"discriminator": {"propertyName": "kind", "mapping": {"pet": "` + tt.mapping + `"}}},
+				"Cat": {"allOf": [{"$ref": "#/components/schemas/Pet"}, {"type": "object", "properties": {"lives": {"type": "integer"}}}]}}}}`
+			doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+			require.NoError(t, err)
+
+			flattener := NewFlattener(FlattenOptions{}, doc)
+			defer flattener.Close()
+			flatDoc, err := flattener.FlattenSpec()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, flatDoc.Components.Schemas["Pet"].Value.Discriminator.Mapping["pet"])
+		})
+	}
+}
@@ -78,8 +78,47 @@ func DetectSchemaType(doc *openapi3.T, rawSchema map[string]interface{}) SchemaT
 	return SchemaTypeUnknown
 }
 
+// OpennessPolicy controls whether generated schemas accept keys that are not declared as properties
+type OpennessPolicy string
+
+const (
+	// OpennessStrict only accepts extra keys that additionalProperties, patternProperties or
+	// unevaluatedProperties explicitly allow. This is the default.
+	OpennessStrict OpennessPolicy = "strict"
+	// OpennessSpec follows JSON Schema, where extra keys are accepted unless
+	// additionalProperties or unevaluatedProperties is false.
+	OpennessSpec OpennessPolicy = "spec"
+)
+
+// ParseOpennessPolicy parses an openness policy name
+func ParseOpennessPolicy(name string) (OpennessPolicy, error) {
+	switch policy := OpennessPolicy(name); policy {
+	case OpennessStrict, OpennessSpec:
+		return policy, nil
+	case "":
+		return OpennessStrict, nil
+	default:
+		return "", fmt.Errorf("unknown openness policy %q, expected %q or %q", name, OpennessStrict, OpennessSpec)
+	}
+}
+
+// GenerateOptions configures KCL generation
+type GenerateOptions struct {
+	OperationSchemas bool           // Generate schemas for operation request bodies, responses and parameters
+	MaxEnumLiterals  int            // Enums with more values keep their base type and are validated with an "in" check; 0 means no limit
+	Openness         OpennessPolicy // Whether schemas accept undeclared keys; empty means OpennessStrict
+	RootDefinitions  []string       // JSON Schema $defs/definitions keys to generate, with the definitions they reference, instead of the document root
+	SourcePath       string         // File or URL the JSON Schema was read from; relative references resolve against it
+	Flatten          FlattenOptions // How external JSON Schema references are fetched
+}
+
 // GenerateKCLSchemas generates KCL schemas from either an OpenAPI spec or a JSON Schema
 func GenerateKCLSchemas(doc *openapi3.T, outputDir string, packageName string, version OpenAPIVersion, rawSchema map[string]interface{}) error {
+	return GenerateKCLSchemasWithOptions(doc, outputDir, packageName, version, rawSchema, GenerateOptions{})
+}
+
+// GenerateKCLSchemasWithOptions generates KCL schemas using the given generation options
+func GenerateKCLSchemasWithOptions(doc *openapi3.T, outputDir string, packageName string, version OpenAPIVersion, rawSchema map[string]interface{}, opts GenerateOptions) error {
 	log.Printf("starting KCL schema generation")
 
 	// Determine the schema type
@@ -89,9 +128,9 @@ func GenerateKCLSchemas(doc *openapi3.T, outputDir string, packageName string, v
 	// Handle different schema types
 	switch schemaType {
 	case SchemaTypeOpenAPI2, SchemaTypeOpenAPI3, SchemaTypeOpenAPI31:
-		return generateOpenAPISchemas(doc, outputDir, packageName, version)
+		return generateOpenAPISchemas(doc, outputDir, packageName, version, opts)
 	case SchemaTypeJSONSchema:
-		return generateJSONSchemas(rawSchema, outputDir, packageName)
+		return generateJSONSchemas(rawSchema, outputDir, packageName, opts)
 	default:
 		return fmt.Errorf("unable to determine schema type or unsupported schema type")
 	}
@@ -129,9 +168,34 @@ func formatSchemaName(name string) string {
 	return string(runes)
 }
 
+// pascalCaseName joins the words of an arbitrary string into a PascalCase KCL identifier,
+// e.g. "get /pets/{petId}" becomes "GetPetsPetId"
+func pascalCaseName(name string) string {
+	var result strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		result.WriteString(string(runes))
+	}
+
+	pascal := result.String()
+	if pascal != "" && unicode.IsDigit([]rune(pascal)[0]) {
+		// KCL identifiers can't start with a digit, so prefix with underscore
+		pascal = "_" + pascal
+	}
+	return pascal
+}
+
 // extractSchemaName extracts the schema name from a reference string
 func extractSchemaName(ref string) string {
 	// For "#/components/schemas/Pet" or "#/definitions/Pet", return "Pet"
+	if _, fragment, ok := strings.Cut(ref, "#"); ok {
+		if tokens, err := parseJSONPointer(fragment); err == nil && len(tokens) > 0 {
+			return tokens[len(tokens)-1]
+		}
+	}
 	parts := strings.Split(ref, "/")
 	if len(parts) > 0 {
 		return parts[len(parts)-1]
@@ -293,7 +357,7 @@ func GenerateKCL(schemaFilePath, outputDir, packageName string) error {
 		}
 
 		// Generate KCL from JSON Schema
-		return generateJSONSchemas(rawSchema, outputDir, packageName)
+		return generateJSONSchemas(rawSchema, outputDir, packageName, GenerateOptions{SourcePath: schemaFilePath})
 
 	default:
 		return fmt.Errorf("unknown or unsupported specification format")
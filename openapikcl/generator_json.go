@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,7 +16,7 @@ import (
 )
 
 // generateJSONSchemas handles KCL generation from JSON Schema
-func generateJSONSchemas(rawSchema map[string]interface{}, outputDir string, packageName string) error {
+func generateJSONSchemas(rawSchema map[string]interface{}, outputDir string, packageName string, opts GenerateOptions) error {
 	log.Printf("processing JSON Schema")
 
 	// Create output directory based on packageName if outputDir is empty
@@ -34,118 +33,25 @@ func generateJSONSchemas(rawSchema map[string]interface{}, outputDir string, pac
 		rootName = formatSchemaName(title)
 	}
 
-	// Handle top-level $ref
-	if ref, ok := rawSchema["$ref"].(string); ok && ref != "" {
-		log.Printf("schema uses top-level $ref: %s", ref)
-		// If the ref is to a local definition, extract it
-		if strings.HasPrefix(ref, "#/$defs/") || strings.HasPrefix(ref, "#/definitions/") {
-			defName := strings.TrimPrefix(strings.TrimPrefix(ref, "#/$defs/"), "#/definitions/")
-			log.Printf("resolving top-level $ref to local definition: %s", defName)
-
-			// Try to get the definition from $defs or definitions
-			var defs map[string]interface{}
-			if defsObj, ok := rawSchema["$defs"].(map[string]interface{}); ok {
-				defs = defsObj
-			} else if defsObj, ok := rawSchema["definitions"].(map[string]interface{}); ok {
-				defs = defsObj
-			}
-
-			if defs != nil {
-				if defSchema, ok := defs[defName].(map[string]interface{}); ok {
-					// Use the definition as our schema
-					log.Printf("found definition %s, using it as root schema", defName)
-
-					// If the definition has a title, use it for the root name
-					if defTitle, ok := defSchema["title"].(string); ok && defTitle != "" {
-						rootName = formatSchemaName(defTitle)
-					} else {
-						// Otherwise use the definition name
-						rootName = formatSchemaName(defName)
-					}
-
-					// Create a new schema with the definition's contents
-					newSchema := make(map[string]interface{})
-					for k, v := range defSchema {
-						newSchema[k] = v
-					}
-
-					// Add all definitions to the new schema so references work
-					if defs != nil {
-						newSchema["$defs"] = defs
-					}
-
-					// Replace the raw schema with our extracted definition
-					rawSchema = newSchema
-				}
-			}
-		}
-	}
-
-	// Preprocess properties to resolve references
-	if props, ok := rawSchema["properties"].(map[string]interface{}); ok {
-		for _, propData := range props {
-			if propObj, ok := propData.(map[string]interface{}); ok {
-				if ref, ok := propObj["$ref"].(string); ok && ref != "" {
-					// Try to resolve the reference
-					if strings.HasPrefix(ref, "#/$defs/") || strings.HasPrefix(ref, "#/definitions/") {
-						defName := strings.TrimPrefix(strings.TrimPrefix(ref, "#/$defs/"), "#/definitions/")
-
-						// Try to get the definition from $defs or definitions
-						var defs map[string]interface{}
-						if defsObj, ok := rawSchema["$defs"].(map[string]interface{}); ok {
-							defs = defsObj
-						} else if defsObj, ok := rawSchema["definitions"].(map[string]interface{}); ok {
-							defs = defsObj
-						}
-
-						if defs != nil {
-							if defSchema, ok := defs[defName].(map[string]interface{}); ok {
-								// Replace the reference with the actual definition
-								log.Printf("resolving reference to %s", defName)
-								for k, v := range defSchema {
-									propObj[k] = v
-								}
-								// Keep the $ref for reference
-								propObj["_originalRef"] = ref
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
 	// Compile the JSON Schema
 	compiler := jsonschema.NewCompiler()
 
-	// Add support for loading local references
-	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
-		if strings.HasPrefix(url, "#") {
-			// Local reference, handled by the compiler
-			return nil, fmt.Errorf("local ref should be handled by compiler: %s", url)
-		}
-		return nil, fmt.Errorf("loading external references not supported: %s", url)
+	// Load external references from files and URLs. File references stay inside the directory
+	// of the input unless another root is given.
+	if opts.Flatten.BaseDir == "" && opts.SourcePath != "" && !isURLRef(opts.SourcePath) {
+		opts.Flatten.BaseDir = filepath.Dir(opts.SourcePath)
 	}
+	loader := newJSONSchemaLoader(opts.Flatten)
+	defer loader.flattener.Close()
+	compiler.LoadURL = loader.load
 
 	schemaBytes, err := json.Marshal(rawSchema)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON schema: %w", err)
 	}
 
-	// Extract default values before compilation
-	defaultValues := make(map[string]interface{})
-	if props, ok := rawSchema["properties"].(map[string]interface{}); ok {
-		for propName, propData := range props {
-			if propObj, ok := propData.(map[string]interface{}); ok {
-				if defaultVal, hasDefault := propObj["default"]; hasDefault {
-					defaultValues[propName] = defaultVal
-				}
-			}
-		}
-	}
-
 	// Add the schema to the compiler
-	schemaID := "root-schema"
+	schemaID := jsonSchemaResourceID(opts.SourcePath)
 	err = compiler.AddResource(schemaID, bytes.NewReader(schemaBytes))
 	if err != nil {
 		return fmt.Errorf("failed to add schema resource: %w", err)
@@ -157,22 +63,92 @@ func generateJSONSchemas(rawSchema map[string]interface{}, outputDir string, pac
 		return fmt.Errorf("failed to compile schema: %w", err)
 	}
 
-	// Generate KCL for the root schema
-	kclSchema, err := generateJSONSchemaToKCLWithDefaults(rootName, schema, defaultValues)
+	// Name the definitions first so references to them keep their names
+	ctx := newJSONSchemaContext(opts)
+	ctx.claimName(rootName)
+	definitions, err := compileJSONDefinitions(ctx, compiler, schemaID, rawSchema)
 	if err != nil {
-		return fmt.Errorf("failed to generate KCL schema for %s: %w", rootName, err)
+		return err
 	}
 
-	// Write the schema to a file
-	schemaPath := filepath.Join(outputDir, rootName+".k")
-	err = os.MkdirAll(outputDir, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	// The document root is generated unless definitions are selected as roots,
+	// or it only refers to a definition, which then stands in for it
+	generateRoot := true
+	if len(opts.RootDefinitions) > 0 {
+		definitions, err = selectJSONDefinitions(definitions, opts.RootDefinitions)
+		if err != nil {
+			return err
+		}
+		for _, definition := range definitions {
+			if definition.key == opts.RootDefinitions[0] {
+				rootName = definition.name
+			}
+		}
+		generateRoot = false
+	} else if name, ok := ctx.names[schema.Ref]; ok && len(schema.Properties) == 0 && len(schema.Types) == 0 {
+		log.Printf("schema uses top-level $ref to definition %s", name)
+		rootName = name
+		generateRoot = false
 	}
 
-	err = os.WriteFile(schemaPath, []byte(kclSchema), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write schema file: %w", err)
+	// Schemas of other documents are generated like definitions
+	generated := make([]*jsonschema.Schema, 0, len(definitions)+1)
+	if generateRoot {
+		generated = append(generated, schema)
+	}
+	for _, definition := range definitions {
+		generated = append(generated, definition.schema)
+	}
+	external := collectExternalSchemas(ctx, loader, jsonSchemaDocument(schema.Location), generated)
+	definitions = append(definitions, external...)
+
+	// Name the inline objects so they are generated as their own schemas
+	var hoisted []hoistedJSONSchema
+	if generateRoot {
+		hoisted = hoistJSONInlineObjects(ctx, rootName, schema, rawSchema)
+	}
+	for _, definition := range definitions {
+		if isJSONObjectDefinition(definition.schema) {
+			hoisted = append(hoisted, hoistedJSONSchema{
+				name:          definition.name,
+				schema:        definition.schema,
+				defaultValues: extractDefaultValues(definition.raw),
+			})
+			hoisted = append(hoisted, hoistJSONInlineObjects(ctx, definition.name, definition.schema, definition.raw)...)
+		}
+	}
+
+	// Generate KCL for the root schema
+	if generateRoot {
+		kclSchema, err := generateJSONSchemaToKCLWithContext(rootName, schema, extractDefaultValues(rawSchema), ctx)
+		if err != nil {
+			return fmt.Errorf("failed to generate KCL schema for %s: %w", rootName, err)
+		}
+		if err := writeKCLSchemaFile(outputDir, rootName, kclSchema); err != nil {
+			return fmt.Errorf("failed to write KCL schema for %s: %w", rootName, err)
+		}
+	}
+
+	// Write the definitions that are not objects as type aliases
+	for _, definition := range definitions {
+		if isJSONObjectDefinition(definition.schema) {
+			continue
+		}
+		alias := ctx.generateJSONTypeAlias(definition.name, definition.schema)
+		if err := writeKCLSchemaFile(outputDir, definition.name, alias); err != nil {
+			return fmt.Errorf("failed to write KCL schema for %s: %w", definition.name, err)
+		}
+	}
+
+	// Write the object definitions and hoisted inline object schemas
+	for _, h := range hoisted {
+		hoistedSchema, err := generateJSONSchemaToKCLWithContext(h.name, h.schema, h.defaultValues, ctx)
+		if err != nil {
+			return fmt.Errorf("failed to generate KCL schema for %s: %w", h.name, err)
+		}
+		if err := writeKCLSchemaFile(outputDir, h.name, hoistedSchema); err != nil {
+			return fmt.Errorf("failed to write KCL schema for %s: %w", h.name, err)
+		}
 	}
 
 	// Generate a simple main.k file
@@ -221,6 +197,22 @@ check_instance = %s {
 	return nil
 }
 
+// extractDefaultValues collects the default values of the top-level properties of a raw schema.
+// The compiler only keeps defaults when annotations are extracted, so they are read before compilation.
+func extractDefaultValues(rawSchema map[string]interface{}) map[string]interface{} {
+	defaultValues := make(map[string]interface{})
+	if props, ok := rawSchema["properties"].(map[string]interface{}); ok {
+		for propName, propData := range props {
+			if propObj, ok := propData.(map[string]interface{}); ok {
+				if defaultVal, hasDefault := propObj["default"]; hasDefault {
+					defaultValues[propName] = defaultVal
+				}
+			}
+		}
+	}
+	return defaultValues
+}
+
 // generateJSONSchemaToKCL converts a JSON Schema to KCL
 func generateJSONSchemaToKCL(name string, schema *jsonschema.Schema) (string, error) {
 	log.Printf("generating KCL schema for %s from JSON Schema", name)
@@ -339,8 +331,98 @@ func generateJSONSchemaToKCL(name string, schema *jsonschema.Schema) (string, er
 	return builder.String(), nil
 }
 
+// jsonSchemaContext carries document-wide state through JSON Schema generation
+type jsonSchemaContext struct {
+	names map[*jsonschema.Schema]string // schemas generated as named KCL schemas
+	taken map[string]bool               // names already given to a KCL schema
+	// defaults of definitions, applied to the properties referring to them
+	defaults map[*jsonschema.Schema]interface{}
+	opts     GenerateOptions
+}
+
+// newJSONSchemaContext creates an empty generation context
+func newJSONSchemaContext(opts GenerateOptions) *jsonSchemaContext {
+	return &jsonSchemaContext{
+		names:    make(map[*jsonschema.Schema]string),
+		taken:    make(map[string]bool),
+		defaults: make(map[*jsonschema.Schema]interface{}),
+		opts:     opts,
+	}
+}
+
+// claimName reserves a unique KCL schema name based on name
+func (ctx *jsonSchemaContext) claimName(name string) string {
+	name = uniqueSchemaName(name, func(candidate string) bool { return ctx.taken[candidate] })
+	ctx.taken[name] = true
+	return name
+}
+
+// options returns the generation options, or the defaults without a context
+func (ctx *jsonSchemaContext) options() GenerateOptions {
+	if ctx == nil {
+		return GenerateOptions{}
+	}
+	return ctx.opts
+}
+
+// typeToKCL converts a JSON Schema type to a KCL type, referring to named schemas where possible
+func (ctx *jsonSchemaContext) typeToKCL(schema *jsonschema.Schema) string {
+	if kclType, ok := ctx.namedType(schema); ok {
+		return kclType
+	}
+	return ctx.structuralType(schema)
+}
+
+// structuralType converts a JSON Schema type to a KCL type without using the name of the schema itself
+func (ctx *jsonSchemaContext) structuralType(schema *jsonschema.Schema) string {
+	if literalType, ok := enumLiteralType(schema.Enum, ctx.options()); ok {
+		return literalType
+	}
+	if schema.Ref != nil && len(schema.Types) == 0 {
+		return ctx.typeToKCL(schema.Ref)
+	}
+	if ref := singleJSONAllOfRef(schema); ref != nil && len(schema.Types) == 0 {
+		return ctx.typeToKCL(ref)
+	}
+	if isJSONMapSchema(schema) {
+		if valueType, ok := ctx.mapValueType(schema); ok {
+			kclType := mapType(valueType)
+			if containsType(schema.Types, "null") {
+				kclType = nullableType(kclType)
+			}
+			return kclType
+		}
+	}
+	return jsonSchemaTypeToKCL(schema)
+}
+
+// namedType returns the KCL type of a schema that is, or is an array of, a named schema
+func (ctx *jsonSchemaContext) namedType(schema *jsonschema.Schema) (string, bool) {
+	if ctx == nil || schema == nil {
+		return "", false
+	}
+	if name, ok := ctx.names[schema]; ok {
+		return name, true
+	}
+	if schema.Ref != nil && len(schema.Types) == 0 {
+		return ctx.namedType(schema.Ref)
+	}
+	if containsType(schema.Types, "array") {
+		if itemType, ok := ctx.namedType(jsonSchemaItems(schema)); ok {
+			return "[" + itemType + "]", true
+		}
+	}
+	return "", false
+}
+
 // generateJSONSchemaToKCLWithDefaults converts a JSON Schema to KCL with supplied default values
 func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema, defaultValues map[string]interface{}) (string, error) {
+	return generateJSONSchemaToKCLWithContext(name, schema, defaultValues, nil)
+}
+
+// generateJSONSchemaToKCLWithContext converts a JSON Schema to KCL with supplied default values,
+// referring to the named schemas of the generation context
+func generateJSONSchemaToKCLWithContext(name string, schema *jsonschema.Schema, defaultValues map[string]interface{}, ctx *jsonSchemaContext) (string, error) {
 	log.Printf("generating KCL schema for %s from JSON Schema with defaults", name)
 
 	var builder strings.Builder
@@ -378,40 +460,33 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 	// Process properties
 	var properties = make(map[string]*jsonschema.Schema)
 
-	// Handle allOf for schema inheritance
-	if schema.AllOf != nil && len(schema.AllOf) > 0 {
-		allOfProps, allOfConstraints, err := handleAllOf(schema, name)
+	// Merge the properties of allOf members, and the properties they require
+	var required []string
+	if len(schema.AllOf) > 0 {
+		allOfProps, allOfRequired, err := handleAllOf(schema, name)
 		if err != nil {
 			return "", fmt.Errorf("failed to process allOf: %w", err)
 		}
-
-		// Add merged properties from allOf
-		for propName, propDef := range allOfProps {
-			properties[propName] = propDef
-		}
-
-		// Add constraints from allOf
-		constraints = append(constraints, allOfConstraints...)
+		properties = allOfProps
+		required = allOfRequired
 	}
+	required = appendUnique(required, schema.Required...)
 
-	// Handle if-then-else for conditional validation
-	if schema.If != nil {
-		ifThenElseConstraints := handleIfThenElse(schema, "")
-		if len(ifThenElseConstraints) > 0 {
-			constraints = append(constraints, ifThenElseConstraints...)
+	// Extract properties from the schema, which apply together with those of allOf members
+	for _, propName := range sortedKeys(schema.Properties) {
+		if err := mergeJSONProperty(properties, propName, schema.Properties[propName], name); err != nil {
+			return "", err
 		}
 	}
 
-	// Extract properties from the schema
-	if schema.Properties != nil {
-		for propName, propSchema := range schema.Properties {
-			// Add to our properties map
-			properties[propName] = propSchema
-		}
+	// Properties only oneOf and anyOf members declare are optional attributes
+	if err := addJSONCompositionProperties(schema, name, properties); err != nil {
+		return "", err
 	}
 
 	// Process each property and generate KCL field definitions
 	propCount := 0
+	var attributeTypes []string
 	for propName, propSchema := range properties {
 		// Skip if this is a reserved field in KCL
 		originalPropName := propName
@@ -420,7 +495,7 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 		propCount++
 
 		// Determine if property is required
-		isRequired := contains(schema.Required, originalPropName)
+		isRequired := contains(required, originalPropName)
 		optionalMarker := "?"
 		if isRequired {
 			optionalMarker = ""
@@ -468,7 +543,7 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 		}
 
 		// Get the KCL type for this property
-		kclType := jsonSchemaTypeToKCL(propSchema)
+		kclType := ctx.typeToKCL(propSchema)
 
 		// Determine the default value if one exists
 		var defaultValueStr string
@@ -479,6 +554,11 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 				defaultValueStr = " = " + formatKCLDefaultValue(defaultVal)
 			}
 		}
+		if defaultValueStr == "" && ctx != nil {
+			if defaultVal, ok := ctx.defaults[resolveJSONRef(propSchema)]; ok {
+				defaultValueStr = " = " + formatKCLDefaultValue(defaultVal)
+			}
+		}
 
 		// Add the property definition
 		builder.WriteString(fmt.Sprintf("\n    %s%s: %s%s", propName, optionalMarker, kclType, defaultValueStr))
@@ -488,17 +568,57 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 			builder.WriteString(fmt.Sprintf(" # %s", propSchema.Description))
 		}
 
-		// Add property constraints (validation rules)
-		propConstraints := generateJSONSchemaConstraints(propSchema, propName)
+		// Add property constraints (validation rules); optional and nullable fields may be None.
+		// Type aliases cannot carry checks, so the constraints of a referenced definition apply here.
+		valueSchema := resolveJSONRef(propSchema)
+		constraintSchema := valueSchema
+		if _, ok := enumLiteralType(valueSchema.Enum, ctx.options()); ok {
+			// Enums typed as literals need no "in" check
+			withoutEnum := *valueSchema
+			withoutEnum.Enum = nil
+			constraintSchema = &withoutEnum
+		}
+		if _, err := mergeJSONConstraints(propSchema); err != nil {
+			return "", fmt.Errorf("property %s of %s: %w", originalPropName, name, err)
+		}
+		propConstraints := generateJSONSchemaConstraints(constraintSchema, propName)
+		if !isRequired || containsType(valueSchema.Types, "null") {
+			propConstraints = guardNone(propConstraints, propName)
+		}
 		if len(propConstraints) > 0 {
 			constraints = append(constraints, propConstraints...)
 		}
+		if check := jsonPatternKeysCheck(propName, valueSchema, isRequired && !containsType(valueSchema.Types, "null")); check != "" {
+			constraints = append(constraints, check)
+		}
+		// Named schemas check their own conditions
+		if _, named := ctx.namedType(propSchema); !named {
+			guard := ""
+			if !isRequired {
+				guard = propName + " != None"
+			}
+			constraints = append(constraints, jsonConditionalChecks(valueSchema, propName, nil, guard)...)
+		}
 
+		attributeTypes = append(attributeTypes, kclType)
 		propCount++
 	}
 
-	// If no properties, add a placeholder comment
-	if propCount == 0 {
+	// Conditions of the schema and its allOf members apply to the attributes of the schema instance
+	instance := &schemaInstance{attributes: make(map[string]bool), required: required}
+	for propName := range properties {
+		instance.attributes[propName] = true
+	}
+	for _, conditional := range append([]*jsonschema.Schema{schema}, schema.AllOf...) {
+		constraints = append(constraints, jsonConditionalChecks(conditional, "", instance, "")...)
+		constraints = append(constraints, jsonCompositionChecks(conditional, name, instance)...)
+	}
+
+	// Extra keys allowed by additionalProperties or patternProperties are typed by an index signature,
+	// otherwise a schema without properties gets a placeholder comment
+	if valueType, ok := ctx.extraValueType(schema); ok {
+		builder.WriteString("\n    " + indexSignature(valueType, attributeTypes))
+	} else if propCount == 0 {
 		builder.WriteString("\n    # No properties defined")
 	}
 
@@ -511,29 +631,59 @@ func generateJSONSchemaToKCLWithDefaults(name string, schema *jsonschema.Schema,
 	}
 
 	log.Printf("generated %d properties for schema %s", len(properties), name)
-	return builder.String(), nil
+	if needsRegexImport {
+		return builder.String(), nil
+	}
+	return withRegexImport(builder.String(), constraints), nil
 }
 
 // jsonSchemaTypeToKCL converts a JSON Schema type to a KCL type
 func jsonSchemaTypeToKCL(schema *jsonschema.Schema) string {
-	// If schema explicitly defines a type, use that
+	// Follow references to the target schema
+	if schema.Ref != nil && len(schema.Types) == 0 {
+		return jsonSchemaTypeToKCL(schema.Ref)
+	}
+
+	// If schema explicitly defines types, use a union of them with null as None
 	if len(schema.Types) > 0 {
-		schemaType := schema.Types[0]
-		switch schemaType {
-		case "string":
+		var members []string
+		for _, schemaType := range schema.Types {
+			switch schemaType {
+			case "string":
+				members = append(members, "str")
+			case "number":
+				members = append(members, "float")
+			case "integer":
+				members = append(members, "int")
+			case "boolean":
+				members = append(members, "bool")
+			case "array":
+				members = append(members, "[any]")
+			case "object":
+				members = append(members, "{str:any}") // More specific type instead of dict
+			case "null":
+				members = append(members, "None")
+			default:
+				members = append(members, "any")
+			}
+		}
+		return unionOf(members)
+	}
+
+	// Infer the type from a constant value
+	if len(schema.Constant) > 0 {
+		switch schema.Constant[0].(type) {
+		case string:
 			return "str"
-		case "number":
-			return "float"
-		case "integer":
-			return "int"
-		case "boolean":
+		case bool:
 			return "bool"
-		case "array":
-			return "[any]"
-		case "object":
-			return "{str:any}" // More specific type instead of dict
-		default:
-			return "any"
+		case json.Number:
+			if _, err := schema.Constant[0].(json.Number).Int64(); err == nil {
+				return "int"
+			}
+			return "float"
+		case float64:
+			return "float"
 		}
 	}
 
@@ -553,9 +703,11 @@ func jsonSchemaTypeToKCL(schema *jsonschema.Schema) string {
 
 	// Handle composition keywords
 	if schema.AllOf != nil && len(schema.AllOf) > 0 {
-		// For allOf, we'll implement inheritance where possible
-		// This is the first step - we'll expand on this in subsequent implementations
-		return "{str:any}" // Using more specific type for allOf composition
+		// Scalars composed through allOf have the type their members agree on, objects are dicts
+		if constraints, err := mergeJSONConstraints(schema); err == nil && len(constraints.types) == 1 {
+			return constraints.kclType()
+		}
+		return "{str:any}"
 	}
 
 	if schema.AnyOf != nil && len(schema.AnyOf) > 0 {
@@ -604,10 +756,6 @@ func jsonSchemaTypeToKCL(schema *jsonschema.Schema) string {
 	if containsType(schema.Types, "boolean") {
 		return "bool"
 	}
-	if containsType(schema.Types, "null") {
-		// KCL doesn't have a direct null type, but we could use nullable
-		return "any"
-	}
 
 	// Default to any if no type is specified
 	return "any"
@@ -635,628 +783,345 @@ func containsType(types []string, typeToCheck string) bool {
 	return false
 }
 
-// generateJSONSchemaConstraints creates KCL constraint expressions for a JSON Schema
+// generateJSONSchemaConstraints creates KCL constraint expressions for a JSON Schema, including those of its allOf members
 func generateJSONSchemaConstraints(schema *jsonschema.Schema, fieldName string) []string {
-	var constraints []string
-	kclFieldRef := fieldName
-
-	// String constraints
-	if schema.MinLength > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) >= %d", kclFieldRef, schema.MinLength))
-	}
-	if schema.MaxLength > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) <= %d", kclFieldRef, schema.MaxLength))
-	}
-	if schema.Pattern != nil {
-		// KCL uses regex matching
-		patternStr := schema.Pattern.String()
-		// Simplify the pattern if needed for KCL compatibility
-		patternStr = strings.ReplaceAll(patternStr, "\\", "\\\\")
-		constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"%s\")", kclFieldRef, patternStr))
-	}
-
-	// Format validation for strings
-	if containsType(schema.Types, "string") && schema.Format != "" {
-		switch schema.Format {
-		case "email":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^[a-zA-Z0-9._%%-]++@[a-zA-Z0-9.-]+\\.[a-zA-Z]{2,}$\")", kclFieldRef))
-		case "ipv4":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\\.){3}(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$\")", kclFieldRef))
-		case "ipv6":
-			// Simplified IPv6 regex for KCL compatibility
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}|::1$\")", kclFieldRef))
-		case "uri":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^(https?|ftp)://[^\\s/$.?#].[^\\s]*$\")", kclFieldRef))
-		case "uri-reference":
-			// Simplified URI reference pattern
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^(https?://)?[^\\s]+$\")", kclFieldRef))
-		case "hostname":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$\")", kclFieldRef))
-		case "date":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^([0-9]{4})-(1[0-2]|0[1-9])-(3[01]|0[1-9]|[12][0-9])$\")", kclFieldRef))
-		case "date-time":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^([0-9]{4})-(1[0-2]|0[1-9])-(3[01]|0[1-9]|[12][0-9])T(2[0-3]|[01][0-9]):([0-5][0-9]):([0-5][0-9])(\\.[0-9]+)?(Z|[+-](2[0-3]|[01][0-9]):([0-5][0-9]))$\")", kclFieldRef))
-		case "time":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^(2[0-3]|[01][0-9]):([0-5][0-9]):([0-5][0-9])(\\.[0-9]+)?$\")", kclFieldRef))
-		case "uuid":
-			constraints = append(constraints, fmt.Sprintf("regex.match(%s, r\"^[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$\")", kclFieldRef))
-		}
-	}
-
-	// Numeric constraints - simplified to avoid complex expressions
-	if schema.Minimum != nil {
-		constraints = append(constraints, fmt.Sprintf("%s >= %v", kclFieldRef, schema.Minimum))
-	}
-
-	if schema.Maximum != nil {
-		constraints = append(constraints, fmt.Sprintf("%s <= %v", kclFieldRef, schema.Maximum))
-	}
-
-	// Array constraints
-	if schema.MinItems > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) >= %d", kclFieldRef, schema.MinItems))
-	}
-	if schema.MaxItems > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) <= %d", kclFieldRef, schema.MaxItems))
-	}
-	if schema.UniqueItems {
-		// Use isunique function in KCL
-		constraints = append(constraints, fmt.Sprintf("isunique(%s)", kclFieldRef))
-	}
-
-	// Enum validation
-	if len(schema.Enum) > 0 {
-		values := make([]string, len(schema.Enum))
-		for i, v := range schema.Enum {
-			// Format the enum value based on its type
-			switch value := v.(type) {
-			case string:
-				values[i] = fmt.Sprintf("\"%s\"", value)
-			case bool:
-				// Use KCL-style booleans
-				if value {
-					values[i] = "True"
-				} else {
-					values[i] = "False"
-				}
-			default:
-				values[i] = fmt.Sprintf("%v", value)
-			}
-		}
-		constraints = append(constraints, fmt.Sprintf("%s in [%s]", kclFieldRef, strings.Join(values, ", ")))
+	constraints, err := mergeJSONConstraints(schema)
+	if err != nil {
+		log.Printf("warning: constraints of %s contradict each other: %v", fieldName, err)
+		constraints, _ = jsonConstraints(schema)
 	}
+	return constraints.checks(fieldName)
+}
 
-	// Check property counts if specified
-	if schema.MinProperties > 0 {
-		constraints = append(constraints, fmt.Sprintf("len(%s) >= %d", fieldName, schema.MinProperties))
-	}
-	return constraints
+// jsonFormatPatterns are the regular expressions strings of a format are checked against
+var jsonFormatPatterns = map[string]string{
+	"email": `^[a-zA-Z0-9._%-]++@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`,
+	"ipv4":  `^((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`,
+	// Simplified IPv6 regex for KCL compatibility
+	"ipv6": `^([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}|::1$`,
+	"uri":  `^(https?|ftp)://[^\s/$.?#].[^\s]*$`,
+	// Simplified URI reference pattern
+	"uri-reference": `^(https?://)?[^\s]+$`,
+	"hostname":      `^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`,
+	"date":          `^([0-9]{4})-(1[0-2]|0[1-9])-(3[01]|0[1-9]|[12][0-9])$`,
+	"date-time":     `^([0-9]{4})-(1[0-2]|0[1-9])-(3[01]|0[1-9]|[12][0-9])T(2[0-3]|[01][0-9]):([0-5][0-9]):([0-5][0-9])(\.[0-9]+)?(Z|[+-](2[0-3]|[01][0-9]):([0-5][0-9]))$`,
+	"time":          `^(2[0-3]|[01][0-9]):([0-5][0-9]):([0-5][0-9])(\.[0-9]+)?$`,
+	"uuid":          `^[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
 }
 
-// handleAllOf processes JSON Schema allOf compositions and returns merged properties and constraints
+// handleAllOf merges the properties and required property names of the allOf members of a JSON Schema,
+// following references. A property defined differently by several members becomes the allOf of its
+// definitions, which must not contradict each other.
 func handleAllOf(schema *jsonschema.Schema, name string) (map[string]*jsonschema.Schema, []string, error) {
-	// Change return type to match what generateJSONSchemaToKCLWithDefaults expects
 	properties := make(map[string]*jsonschema.Schema)
-	var constraints []string
+	required, err := mergeJSONAllOfMembers(schema, name, properties, make(map[*jsonschema.Schema]bool))
+	return properties, required, err
+}
 
-	if schema.AllOf == nil || len(schema.AllOf) == 0 {
-		return properties, constraints, nil
-	}
+// mergeJSONAllOfMembers adds the properties of the allOf members of a schema to properties, skipping schemas being merged
+func mergeJSONAllOfMembers(schema *jsonschema.Schema, name string, properties map[string]*jsonschema.Schema, visiting map[*jsonschema.Schema]bool) ([]string, error) {
+	visiting[schema] = true
+	defer delete(visiting, schema)
 
-	// Track parent schemas for inheritance
-	var parentSchemas []string
-	var mergedRequired []string
+	// A reference applies like another allOf member
+	members := schema.AllOf
+	if schema.Ref != nil {
+		members = append([]*jsonschema.Schema{schema.Ref}, members...)
+	}
 
-	// Process each schema in the allOf array
-	for _, subSchema := range schema.AllOf {
-		// Note: In the real jsonschema library, Ref might be a different field or structure
-		// This is a placeholder for the concept - actual implementation depends on the library
+	var required []string
+	for _, member := range members {
+		if member == nil || visiting[member] {
+			continue
+		}
+		memberRequired, err := mergeJSONAllOfMembers(member, name, properties, visiting)
+		if err != nil {
+			return nil, err
+		}
+		required = appendUnique(required, memberRequired...)
 
-		// Merge required properties
-		mergedRequired = append(mergedRequired, subSchema.Required...)
+		for _, propName := range sortedKeys(member.Properties) {
+			if err := mergeJSONProperty(properties, propName, member.Properties[propName], name); err != nil {
+				return nil, err
+			}
+		}
+		required = appendUnique(required, member.Required...)
+	}
+	return required, nil
+}
 
-		// Merge properties from this subschema
-		for propName, propSchema := range subSchema.Properties {
-			// If we already have this property, we need to merge constraints
-			if existing, found := properties[propName]; found {
-				// For now, choose the most restrictive constraints
-				// In a more advanced implementation, we would merge them properly
-				if propSchema.MinLength > existing.MinLength {
-					existing.MinLength = propSchema.MinLength
-				}
-				if propSchema.MaxLength < existing.MaxLength || existing.MaxLength == 0 {
-					existing.MaxLength = propSchema.MaxLength
-				}
+// mergeJSONProperty adds a definition of a property to properties. A property already defined differently
+// becomes the allOf of both definitions, which must not contradict each other.
+func mergeJSONProperty(properties map[string]*jsonschema.Schema, propName string, prop *jsonschema.Schema, name string) error {
+	existing, ok := properties[propName]
+	if !ok || existing == prop {
+		properties[propName] = prop
+		return nil
+	}
+
+	merged := &jsonschema.Schema{
+		AllOf:         []*jsonschema.Schema{existing, prop},
+		Description:   prop.Description,
+		Default:       prop.Default,
+		MinLength:     -1,
+		MaxLength:     -1,
+		MinItems:      -1,
+		MaxItems:      -1,
+		MinProperties: -1,
+		MaxProperties: -1,
+		MinContains:   1,
+		MaxContains:   -1,
+	}
+	if merged.Description == "" {
+		merged.Description = existing.Description
+	}
+	if merged.Default == nil {
+		merged.Default = existing.Default
+	}
+	if _, err := mergeJSONConstraints(merged); err != nil {
+		return fmt.Errorf("property %s of %s: %w", propName, name, err)
+	}
+	properties[propName] = merged
+	return nil
+}
 
-				// Handle numeric constraints - compare the values, not the pointers
-				if propSchema.Minimum != nil && (existing.Minimum == nil ||
-					(propSchema.Minimum != nil && existing.Minimum != nil)) {
-					// In a real implementation, we'd compare the actual values
-					existing.Minimum = propSchema.Minimum
+// addJSONCompositionProperties adds the properties declared only by the oneOf and anyOf members of a schema,
+// and of its allOf members, to properties. The constraints of a member only apply to values matching it,
+// which the composition checks test, so these properties are declared by their type alone.
+func addJSONCompositionProperties(schema *jsonschema.Schema, name string, properties map[string]*jsonschema.Schema) error {
+	definitions := make(map[string][]*jsonschema.Schema)
+	for _, composed := range append([]*jsonschema.Schema{schema}, schema.AllOf...) {
+		if composed == nil {
+			continue
+		}
+		for _, member := range append(append([]*jsonschema.Schema(nil), composed.OneOf...), composed.AnyOf...) {
+			if member == nil {
+				continue
+			}
+			memberProps, _, err := handleAllOf(member, name)
+			if err != nil {
+				return err
+			}
+			for _, propName := range sortedKeys(member.Properties) {
+				if err := mergeJSONProperty(memberProps, propName, member.Properties[propName], name); err != nil {
+					return err
 				}
-				if propSchema.Maximum != nil && (existing.Maximum == nil ||
-					(propSchema.Maximum != nil && existing.Maximum != nil)) {
-					// In a real implementation, we'd compare the actual values
-					existing.Maximum = propSchema.Maximum
+			}
+			for propName, prop := range memberProps {
+				if _, declared := properties[propName]; !declared {
+					definitions[propName] = append(definitions[propName], prop)
 				}
-				// Merge other constraints as needed
-
-				// Update the merged property
-				properties[propName] = existing
-			} else {
-				// Add the new property
-				properties[propName] = propSchema
 			}
 		}
 	}
-
-	// Track which properties are required
-	for _, req := range mergedRequired {
-		if prop, ok := properties[req]; ok {
-			// Mark this property as required in its schema
-			// This is a bit of a hack - in a real implementation we'd handle this differently
-			// but it allows us to integrate with the existing code
-			prop.Required = append(prop.Required, req)
-		}
-	}
-
-	// If we have parent schemas, add a comment indicating inheritance
-	if len(parentSchemas) > 0 {
-		parentList := strings.Join(parentSchemas, ", ")
-		constraints = append(constraints, fmt.Sprintf("# This schema inherits from: %s", parentList))
+	for propName, propDefinitions := range definitions {
+		properties[propName] = jsonDeclarationSchema(propDefinitions)
 	}
-
-	return properties, constraints, nil
+	return nil
 }
 
-// handleOneOf processes JSON Schema oneOf compositions
-func handleOneOf(schema *jsonschema.Schema, fieldName string) (string, []string, error) {
-	// Default type if we can't determine anything better
-	kclType := "any"
-	var constraints []string
+// jsonDeclarationSchema returns a schema without constraints typing a property like all its definitions do:
+// by the reference they share, or else by the union of their types, or as any value.
+func jsonDeclarationSchema(definitions []*jsonschema.Schema) *jsonschema.Schema {
+	declaration := &jsonschema.Schema{
+		MinLength:     -1,
+		MaxLength:     -1,
+		MinItems:      -1,
+		MaxItems:      -1,
+		MinProperties: -1,
+		MaxProperties: -1,
+		MinContains:   1,
+		MaxContains:   -1,
+	}
+	sharedRef, typed := definitions[0].Ref, true
+	for _, definition := range definitions {
+		if declaration.Description == "" {
+			declaration.Description = definition.Description
+		}
+		if definition.Ref != sharedRef || len(definition.Types) > 0 {
+			sharedRef = nil
+		}
+		typed = typed && len(definition.Types) > 0
+		declaration.Types = appendUnique(declaration.Types, definition.Types...)
+	}
+	switch {
+	case sharedRef != nil:
+		declaration.Ref, declaration.Types = sharedRef, nil
+	case !typed:
+		declaration.Types = nil
+	}
+	return declaration
+}
 
-	if schema.OneOf == nil || len(schema.OneOf) == 0 {
-		return kclType, constraints, nil
+// jsonCompositionChecks returns check expressions that hold when the instance of a KCL schema matches exactly
+// one oneOf member, and at least one anyOf member, of a JSON Schema. Members typed as something other than
+// objects cannot match an instance, so compositions listing them are left unchecked.
+func jsonCompositionChecks(schema *jsonschema.Schema, name string, instance *schemaInstance) []string {
+	if schema == nil {
+		return nil
 	}
 
-	// First, see if all types in oneOf are the same basic type
-	// This would allow us to use a single type instead of any
-	allString := true
-	allNumber := true
-	allInteger := true
-	allBoolean := true
-	allArray := true
-	allObject := true
-
-	for _, subSchema := range schema.OneOf {
-		hasString := containsType(subSchema.Types, "string")
-		hasNumber := containsType(subSchema.Types, "number")
-		hasInteger := containsType(subSchema.Types, "integer")
-		hasBoolean := containsType(subSchema.Types, "boolean")
-		hasArray := containsType(subSchema.Types, "array")
-		hasObject := containsType(subSchema.Types, "object")
-
-		allString = allString && hasString && !hasNumber && !hasInteger && !hasBoolean && !hasArray && !hasObject
-		allNumber = allNumber && !hasString && (hasNumber || hasInteger) && !hasBoolean && !hasArray && !hasObject
-		allInteger = allInteger && !hasString && !hasNumber && hasInteger && !hasBoolean && !hasArray && !hasObject
-		allBoolean = allBoolean && !hasString && !hasNumber && !hasInteger && hasBoolean && !hasArray && !hasObject
-		allArray = allArray && !hasString && !hasNumber && !hasInteger && !hasBoolean && hasArray && !hasObject
-		allObject = allObject && !hasString && !hasNumber && !hasInteger && !hasBoolean && !hasArray && hasObject
-	}
-
-	// Determine best type based on analysis
-	if allString {
-		kclType = "str"
-	} else if allNumber {
-		kclType = "float"
-	} else if allInteger {
-		kclType = "int"
-	} else if allBoolean {
-		kclType = "bool"
-	} else if allArray {
-		kclType = "list"
-	} else if allObject {
-		kclType = "{str:any}" // More specific type instead of dict
-	}
-
-	// Try to identify a discriminator property for validation
-	discriminator := ""
-
-	// Look for a common property with enum that has different values in each oneOf
-	if allObject {
-		propMap := make(map[string][]interface{})
-
-		// First pass: collect all properties with enum values
-		for _, subSchema := range schema.OneOf {
-			if subSchema.Properties != nil {
-				for propName, propSchema := range subSchema.Properties {
-					if propSchema.Enum != nil && len(propSchema.Enum) > 0 {
-						for _, enumVal := range propSchema.Enum {
-							propMap[propName] = append(propMap[propName], enumVal)
-						}
-					}
-				}
-			}
-		}
-
-		// Second pass: find a property whose enum values can distinguish the schemas
-		for propName, enumValues := range propMap {
-			if len(enumValues) >= len(schema.OneOf) {
-				// This property has enough enum values to potentially work as a discriminator
-				discriminator = propName
-				break
+	predicates := func(keyword string, members []*jsonschema.Schema) []*schemaPredicate {
+		var predicates []*schemaPredicate
+		for _, member := range members {
+			if member != nil && len(member.Types) > 0 && !containsType(member.Types, "object") {
+				log.Printf("warning: %s of %s has members that are not objects and is not checked", keyword, name)
+				return nil
 			}
+			predicates = append(predicates, jsonPredicate(member, make(map[*jsonschema.Schema]bool)))
 		}
+		return predicates
+	}
+	var checks []string
+	if oneOf := predicates("oneOf", schema.OneOf); len(oneOf) > 0 {
+		predicate := &schemaPredicate{constraints: &schemaConstraints{}, oneOf: oneOf}
+		checks = append(checks, fmt.Sprintf("%s, \"%s must match exactly one oneOf schema\"", predicate.instanceExpression(instance), name))
+	}
+	if anyOf := predicates("anyOf", schema.AnyOf); len(anyOf) > 0 {
+		predicate := &schemaPredicate{constraints: &schemaConstraints{}, anyOf: anyOf}
+		checks = append(checks, fmt.Sprintf("%s, \"%s must match at least one anyOf schema\"", predicate.instanceExpression(instance), name))
 	}
+	return checks
+}
 
-	// Generate validation logic based on discriminator if found
-	if discriminator != "" {
-		constraints = append(constraints, fmt.Sprintf("# oneOf validation using discriminator: %s", discriminator))
-
-		// For each oneOf option, generate a conditional check based on the discriminator
-		for _, subSchema := range schema.OneOf {
-			if subSchema.Properties != nil {
-				if discProp, ok := subSchema.Properties[discriminator]; ok {
-					if discProp.Enum != nil && len(discProp.Enum) > 0 {
-						for _, enumVal := range discProp.Enum {
-							if strVal, ok := enumVal.(string); ok {
-								// Add the discriminator check
-								constraints = append(constraints, fmt.Sprintf("if %s.%s == %s:", fieldName, discriminator, strVal))
-
-								// Check for if-then-else conditions within this branch
-								if subSchema.If != nil {
-									ifConstraints := handleIfThenElse(subSchema, fieldName)
-									for _, c := range ifConstraints {
-										constraints = append(constraints, fmt.Sprintf("    %s", c))
-									}
-								}
-
-								// Check for region-based conditions
-								if region, ok := subSchema.Properties["region"]; ok && region.Enum != nil {
-									var regionValues []string
-									for _, regionVal := range region.Enum {
-										if regionStr, ok := regionVal.(string); ok {
-											regionValues = append(regionValues, fmt.Sprintf("\"%s\"", regionStr))
-										}
-									}
-
-									if len(regionValues) > 0 {
-										constraints = append(constraints, "    # Conditional validation for region")
-										constraints = append(constraints, fmt.Sprintf("    if %s.region in [%s]:", fieldName, strings.Join(regionValues, ", ")))
-									}
-								}
-							}
-						}
-					}
-				}
+// singleJSONAllOfRef returns the only reference among the allOf members of a schema and of its inline members,
+// or nil if there are several or none
+func singleJSONAllOfRef(schema *jsonschema.Schema) *jsonschema.Schema {
+	var ref *jsonschema.Schema
+	for _, member := range schema.AllOf {
+		if member == nil {
+			continue
+		}
+		memberRef := member.Ref
+		if memberRef == nil {
+			if memberRef = singleJSONAllOfRef(member); memberRef == nil {
+				continue
 			}
 		}
-	} else {
-		// No discriminator found, use generic validation
-		constraints = append(constraints, "# oneOf validation with no discriminator - exactly one of these schemas must match")
+		if ref != nil && ref != memberRef {
+			return nil
+		}
+		ref = memberRef
 	}
-
-	return kclType, constraints, nil
+	return ref
 }
 
-// handleAnyOf processes JSON Schema anyOf compositions
-func handleAnyOf(schema *jsonschema.Schema, fieldName string) (string, []string, error) {
+// handleOneOf processes JSON Schema oneOf compositions
+func handleOneOf(schema *jsonschema.Schema, fieldName string) (string, []string, error) {
 	// Default type if we can't determine anything better
 	kclType := "any"
 	var constraints []string
 
-	if schema.AnyOf == nil || len(schema.AnyOf) == 0 {
+	if schema.OneOf == nil || len(schema.OneOf) == 0 {
 		return kclType, constraints, nil
 	}
 
-	// Similar to oneOf, let's see if we can determine a common type
-	// But the rules are a bit more relaxed since anyOf means "at least one of"
-	hasString := false
-	hasNumber := false
-	hasInteger := false
-	hasBoolean := false
-	hasArray := false
-	hasObject := false
-
-	// Track all types found in any of the schemas
-	for _, subSchema := range schema.AnyOf {
-		if containsType(subSchema.Types, "string") {
-			hasString = true
-		}
-		if containsType(subSchema.Types, "number") {
-			hasNumber = true
-		}
-		if containsType(subSchema.Types, "integer") {
-			hasInteger = true
-		}
-		if containsType(subSchema.Types, "boolean") {
-			hasBoolean = true
-		}
-		if containsType(subSchema.Types, "array") {
-			hasArray = true
-		}
-		if containsType(subSchema.Types, "object") {
-			hasObject = true
-		}
-	}
-
-	// Count how many different types we have
-	typeCount := 0
-	if hasString {
-		typeCount++
-	}
-	if hasNumber || hasInteger {
-		typeCount++
-	}
-	if hasBoolean {
-		typeCount++
-	}
-	if hasArray {
-		typeCount++
-	}
-	if hasObject {
-		typeCount++
-	}
-
-	// Gather validation constraints for each subschema
-	var typeValidations []string
-
-	// Process each subschema and create validation code
-	for i, subSchema := range schema.AnyOf {
-		var validation string
-
-		// Generate different validation based on the type
-		if containsType(subSchema.Types, "string") {
-			validation = fmt.Sprintf("# String validation for anyOf option %d", i+1)
-			if subSchema.MinLength > 0 {
-				validation += fmt.Sprintf("\nlen(%s) >= %d", fieldName, subSchema.MinLength)
-			}
-			if subSchema.MaxLength > 0 {
-				validation += fmt.Sprintf("\nlen(%s) <= %d", fieldName, subSchema.MaxLength)
-			}
-			if subSchema.Pattern != nil {
-				pattern := subSchema.Pattern.String()
-				// Simplified pattern for KCL compatibility
-				pattern = strings.ReplaceAll(pattern, "\\", "\\\\")
-				validation += fmt.Sprintf("\nregex.match(%s, r\"%s\")", fieldName, pattern)
-			}
-		} else if containsType(subSchema.Types, "number") || containsType(subSchema.Types, "integer") {
-			validation = fmt.Sprintf("# Numeric validation for anyOf option %d", i+1)
-			if subSchema.Minimum != nil {
-				validation += fmt.Sprintf("\n%s >= %v", fieldName, subSchema.Minimum)
-			}
-			if subSchema.Maximum != nil {
-				validation += fmt.Sprintf("\n%s <= %v", fieldName, subSchema.Maximum)
-			}
-		} else if containsType(subSchema.Types, "array") {
-			validation = fmt.Sprintf("# Array validation for anyOf option %d", i+1)
-			if subSchema.MinItems > 0 {
-				validation += fmt.Sprintf("\nlen(%s) >= %d", fieldName, subSchema.MinItems)
-			}
-			if subSchema.MaxItems > 0 {
-				validation += fmt.Sprintf("\nlen(%s) <= %d", fieldName, subSchema.MaxItems)
-			}
-		} else if containsType(subSchema.Types, "object") {
-			validation = fmt.Sprintf("# Object validation for anyOf option %d", i+1)
-			// For objects, we might validate required properties
-			for _, req := range subSchema.Required {
-				validation += fmt.Sprintf("\n%s has %s", fieldName, req)
-			}
-		}
-
-		if validation != "" {
-			typeValidations = append(typeValidations, validation)
+	// First, see if all types in oneOf are the same basic type
+	// This would allow us to use a single type instead of any
+	for i, subSchema := range schema.OneOf {
+		branchType := summarizeBranchTypes(subSchema.Types).kclType()
+		if branchType == "any" || (i > 0 && branchType != kclType) {
+			kclType = "any"
+			break
 		}
+		kclType = branchType
 	}
 
-	// If we only have one type, use that
-	if typeCount == 1 {
-		if hasString {
-			kclType = "str"
-		} else if hasNumber || hasInteger {
-			kclType = "float" // Use most permissive numeric type
-		} else if hasBoolean {
-			kclType = "bool"
-		} else if hasArray {
-			kclType = "[any]"
-		} else if hasObject {
-			kclType = "{str:any}" // More specific type instead of dict
-		}
-	} else {
-		// Mixed types, we need to use a more flexible type
-		// There's no direct union type in KCL, so we use 'any'
-		kclType = "any"
-
-		// Add a comment explaining the type union
-		constraints = append(constraints, fmt.Sprintf("# anyOf type union: %s",
-			describeTypeUnion(hasString, hasNumber, hasInteger, hasBoolean, hasArray, hasObject)))
-	}
-
-	// Add anyOf validation if we have type validations
-	if len(typeValidations) > 0 {
-		constraints = append(constraints, "# anyOf validation - at least one of the following must be true:")
-		for _, validation := range typeValidations {
-			// Format each validation option
-			lines := strings.Split(validation, "\n")
-			for i, line := range lines {
-				if i == 0 {
-					constraints = append(constraints, line)
-				} else {
-					constraints = append(constraints, "# "+line)
-				}
-			}
-		}
+	// Each branch becomes a predicate testing its type, required keys and constraints,
+	// and exactly one of them must hold
+	var predicates []string
+	for _, subSchema := range schema.OneOf {
+		predicates = append(predicates, jsonPredicate(subSchema, make(map[*jsonschema.Schema]bool)).expression(fieldName))
 	}
+	constraints = append(constraints, exactlyOneCheck(fieldName, predicates, false))
 
 	return kclType, constraints, nil
 }
 
-// describeTypeUnion creates a human-readable description of type combinations
-func describeTypeUnion(hasString, hasNumber, hasInteger, hasBoolean, hasArray, hasObject bool) string {
-	var types []string
-
-	if hasString {
-		types = append(types, "string")
-	}
-	if hasNumber {
-		types = append(types, "number")
+// jsonPredicate builds the predicate of a JSON Schema, accepting any value for schemas already being visited
+func jsonPredicate(schema *jsonschema.Schema, visiting map[*jsonschema.Schema]bool) *schemaPredicate {
+	if schema == nil || visiting[schema] {
+		return &schemaPredicate{constraints: &schemaConstraints{}}
 	}
-	if hasInteger {
-		types = append(types, "integer")
+	visiting[schema] = true
+	defer delete(visiting, schema)
+
+	constraints, _ := jsonConstraints(schema)
+	p := &schemaPredicate{constraints: constraints, properties: make(map[string]*schemaPredicate)}
+	for name, prop := range schema.Properties {
+		p.properties[name] = jsonPredicate(prop, visiting)
 	}
-	if hasBoolean {
-		types = append(types, "boolean")
+	members := func(schemas []*jsonschema.Schema) []*schemaPredicate {
+		var predicates []*schemaPredicate
+		for _, member := range schemas {
+			predicates = append(predicates, jsonPredicate(member, visiting))
+		}
+		return predicates
 	}
-	if hasArray {
-		types = append(types, "array")
+	// A reference applies like another allOf member
+	if schema.Ref != nil {
+		p.allOf = members([]*jsonschema.Schema{schema.Ref})
 	}
-	if hasObject {
-		types = append(types, "object")
+	p.allOf = append(p.allOf, members(schema.AllOf)...)
+	p.anyOf = members(schema.AnyOf)
+	p.oneOf = members(schema.OneOf)
+	if schema.If != nil {
+		copy(p.ifThenElse[:], members([]*jsonschema.Schema{schema.If, schema.Then, schema.Else}))
 	}
-
-	return strings.Join(types, " | ")
+	p.dependentRequired, p.dependentSchemas = jsonDependencies(schema, visiting)
+	return p
 }
 
-// handleIfThenElse processes JSON Schema if-then-else conditional validation
-func handleIfThenElse(schema *jsonschema.Schema, fieldName string) []string {
-	var constraints []string
-
-	// Check if we have the if-then-else structure
-	if schema.If == nil {
-		return constraints
-	}
-
-	// Create a conditional validation constraint
-	// In KCL, we'll use a check block with conditional logic
-
-	// Determine what kind of condition we're dealing with
-	// Often, the "if" schema is checking for specific property values
-	var conditionType string
-	var conditionField string
-	var conditionValue interface{}
-
-	// First, generate constraints for the "if" condition
-	ifConstraints := generateJSONSchemaConstraints(schema.If, fieldName)
-
-	// Try to detect common patterns for conditions
-	if len(schema.If.Required) == 1 && len(schema.If.Properties) == 1 {
-		// This is likely checking if a specific property exists and has a value
-		conditionType = "propertyExists"
-		conditionField = schema.If.Required[0]
-	} else if len(schema.If.Properties) == 1 {
-		// This is likely checking a specific property value
-		for propName, propSchema := range schema.If.Properties {
-			conditionType = "propertyValue"
-			conditionField = propName
-
-			// Try to extract the expected value
-			if propSchema.Enum != nil && len(propSchema.Enum) > 0 {
-				conditionValue = propSchema.Enum[0]
-			}
-		}
-	}
-
-	// Generate constraints for the "then" schema
-	var thenConstraints []string
-	if schema.Then != nil {
-		thenConstraints = generateJSONSchemaConstraints(schema.Then, fieldName)
+// handleAnyOf processes JSON Schema anyOf compositions
+func handleAnyOf(schema *jsonschema.Schema, fieldName string) (string, []string, error) {
+	if len(schema.AnyOf) == 0 {
+		return "any", nil, nil
 	}
 
-	// Generate constraints for the "else" schema
-	var elseConstraints []string
-	if schema.Else != nil {
-		elseConstraints = generateJSONSchemaConstraints(schema.Else, fieldName)
+	// Branches sharing a type category give the field that type, mixed branches leave it as any
+	summary := branchTypeSummary{}
+	for _, subSchema := range schema.AnyOf {
+		summary = summary.merge(summarizeBranchTypes(subSchema.Types))
 	}
 
-	// Format the field reference based on whether we're at the top level or a property
-	fieldRef := ""
-	if fieldName == "" {
-		// Top-level schema
-		fieldRef = ""
-	} else {
-		// Property within a schema
-		fieldRef = fieldName + "."
+	// Each branch becomes a predicate testing its type, required keys and constraints,
+	// and at least one of them must hold
+	var predicates []string
+	for _, subSchema := range schema.AnyOf {
+		predicates = append(predicates, jsonPredicate(subSchema, make(map[*jsonschema.Schema]bool)).expression(fieldName))
 	}
+	check := fmt.Sprintf("(%s) if %s != None, \"%s must match at least one anyOf schema\"", strings.Join(predicates, " or "), fieldName, fieldName)
 
-	// Now generate the KCL constraints based on the condition type
-	if conditionType == "propertyExists" {
-		constraints = append(constraints, fmt.Sprintf("# Conditional validation: if %s exists", conditionField))
-		constraints = append(constraints, fmt.Sprintf("if %s%s has %s:", fieldRef, fieldName, conditionField))
-
-		// Add then constraints indented
-		for _, constraint := range thenConstraints {
-			constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-		}
-
-		// Add else constraints if available
-		if len(elseConstraints) > 0 {
-			constraints = append(constraints, "else:")
-			for _, constraint := range elseConstraints {
-				constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-			}
-		}
-	} else if conditionType == "propertyValue" && conditionValue != nil {
-		// This checks for a specific property value
-		var valueStr string
-		switch v := conditionValue.(type) {
-		case string:
-			valueStr = fmt.Sprintf("\"%s\"", v)
-		case bool:
-			if v {
-				valueStr = "True"
-			} else {
-				valueStr = "False"
-			}
-		default:
-			valueStr = fmt.Sprintf("%v", v)
-		}
-
-		constraints = append(constraints, fmt.Sprintf("# Conditional validation: if %s%s == %s", fieldRef, conditionField, valueStr))
-		constraints = append(constraints, fmt.Sprintf("if %s%s == %s:", fieldRef, conditionField, valueStr))
-
-		// Add then constraints indented
-		for _, constraint := range thenConstraints {
-			constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-		}
-
-		// Add else constraints if available
-		if len(elseConstraints) > 0 {
-			constraints = append(constraints, "else:")
-			for _, constraint := range elseConstraints {
-				constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-			}
-		}
-	} else {
-		// Generic if-then-else handling
-		constraints = append(constraints, "# Conditional validation with if-then-else")
-
-		// Add if constraints
-		if len(ifConstraints) > 0 {
-			constraints = append(constraints, "# If condition:")
-			for _, constraint := range ifConstraints {
-				constraints = append(constraints, fmt.Sprintf("if %s:", constraint))
-			}
-		}
+	return summary.kclType(), []string{check}, nil
+}
 
-		// Add then constraints
-		if len(thenConstraints) > 0 {
-			constraints = append(constraints, "    # Then constraints:")
-			for _, constraint := range thenConstraints {
-				constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-			}
-		}
+// jsonConditionalChecks translates the if, then and else keywords of a JSON Schema into check expressions
+// applying the then schema when the if schema holds, and the else schema when it does not, followed by
+// the checks of its property dependencies. They test the instance of a KCL schema, or else the value of
+// fieldName, and only apply when guard holds.
+func jsonConditionalChecks(schema *jsonschema.Schema, fieldName string, instance *schemaInstance, guard string) []string {
+	if schema == nil {
+		return nil
+	}
 
-		// Add else constraints
-		if len(elseConstraints) > 0 {
-			constraints = append(constraints, "else:")
-			constraints = append(constraints, "    # Else constraints:")
-			for _, constraint := range elseConstraints {
-				constraints = append(constraints, fmt.Sprintf("    %s", constraint))
-			}
+	predicate := func(schema *jsonschema.Schema) *schemaPredicate {
+		return jsonPredicate(schema, make(map[*jsonschema.Schema]bool))
+	}
+	var checks []string
+	if schema.If != nil {
+		ifPredicate, thenPredicate, elsePredicate := predicate(schema.If), predicate(schema.Then), predicate(schema.Else)
+		if instance != nil {
+			checks = conditionalChecks("", ifPredicate.instanceExpression(instance),
+				thenPredicate.parts("", instance), elsePredicate.parts("", instance))
+		} else {
+			checks = conditionalChecks(guard, ifPredicate.expression(fieldName),
+				thenPredicate.parts(fieldName, nil), elsePredicate.parts(fieldName, nil))
 		}
 	}
 
-	return constraints
+	dependencies := &schemaPredicate{constraints: &schemaConstraints{}}
+	dependencies.dependentRequired, dependencies.dependentSchemas = jsonDependencies(schema, make(map[*jsonschema.Schema]bool))
+	return append(checks, dependencies.dependencyChecks(fieldName, instance, guard)...)
 }
 
 // handleNestedCompositions processes nested JSON Schema compositions (allOf, oneOf, anyOf inside each other)
@@ -1264,35 +1129,6 @@ func handleNestedCompositions(schema *jsonschema.Schema, fieldName string) (stri
 	var constraints []string
 	kclType := "any" // Default type for complex compositions
 
-	// Special handling for the nested test case
-	// This is a specific fix for the test case with user.region in ["US", "Canada"]
-	if fieldName == "user" {
-		for _, subSchema := range schema.AllOf {
-			if subSchema.Properties != nil && subSchema.Properties["user"] != nil {
-				userSchema := subSchema.Properties["user"]
-				if userSchema.OneOf != nil && len(userSchema.OneOf) > 0 {
-					for _, oneOfSchema := range userSchema.OneOf {
-						// Check for organization type with region
-						if oneOfSchema.Properties != nil &&
-							oneOfSchema.Properties["type"] != nil &&
-							oneOfSchema.Properties["type"].Enum != nil {
-							for _, enumVal := range oneOfSchema.Properties["type"].Enum {
-								if strVal, ok := enumVal.(string); ok && strVal == "organization" {
-									// Check for region property with US/Canada enum values
-									if oneOfSchema.Properties["region"] != nil && oneOfSchema.If != nil {
-										// Add region-specific validation
-										constraints = append(constraints, "# Conditional validation for region")
-										constraints = append(constraints, "if user.region in [\"US\", \"Canada\"]:")
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
 	// Check for nested composition patterns
 	if schema.AllOf != nil && len(schema.AllOf) > 0 {
 		// allOf at the top level
@@ -1317,31 +1153,15 @@ func handleNestedCompositions(schema *jsonschema.Schema, fieldName string) (stri
 				// For allOf + anyOf, the type must satisfy both
 				kclType = nestedType // Type is determined by the nested anyOf
 				constraints = append(constraints, nestedConstraints...)
-			} else if subSchema.If != nil {
-				// Check for if-then-else conditions in subschemas
-				ifThenElseConstraints := handleIfThenElse(subSchema, fieldName)
-				constraints = append(constraints, ifThenElseConstraints...)
 			}
-
-			// Check for nested if-then-else within property schemas
-			for propName, propSchema := range subSchema.Properties {
-				if propSchema.If != nil {
-					// Handle if-then-else within a property
-					fullFieldName := fmt.Sprintf("%s.%s", fieldName, propName)
-					ifThenElseConstraints := handleIfThenElse(propSchema, fullFieldName)
-					constraints = append(constraints, ifThenElseConstraints...)
-				}
-
-				// Check for if-then-else in oneOf schemas within properties
-				if propSchema.OneOf != nil && len(propSchema.OneOf) > 0 {
-					for _, oneOfSchema := range propSchema.OneOf {
-						if oneOfSchema.If != nil {
-							fullFieldName := fmt.Sprintf("%s.%s", fieldName, propName)
-							ifThenElseConstraints := handleIfThenElse(oneOfSchema, fullFieldName)
-							constraints = append(constraints, ifThenElseConstraints...)
-						}
-					}
-				}
+			constraints = append(constraints, jsonConditionalChecks(subSchema, fieldName, nil, fieldName+" != None")...)
+
+			// Conditions within property schemas apply to the property when it is set. Those of
+			// oneOf branches are part of the branch predicates.
+			for _, propName := range sortedKeys(subSchema.Properties) {
+				propField := fmt.Sprintf("%s[\"%s\"]", fieldName, propName)
+				guard := fmt.Sprintf("%s != None and \"%s\" in %s", fieldName, propName, fieldName)
+				constraints = append(constraints, jsonConditionalChecks(subSchema.Properties[propName], propField, nil, guard)...)
 			}
 		}
 	} else if schema.OneOf != nil && len(schema.OneOf) > 0 {
@@ -1356,17 +1176,13 @@ func handleNestedCompositions(schema *jsonschema.Schema, fieldName string) (stri
 			subFieldName := fmt.Sprintf("%s_option%d", fieldName, i+1)
 
 			if subSchema.AllOf != nil && len(subSchema.AllOf) > 0 {
-				nestedType, nestedConstraints, err := handleAllOf(subSchema, subFieldName)
+				nestedType, _, err := handleAllOf(subSchema, subFieldName)
 				if err != nil {
 					return kclType, constraints, err
 				}
 
 				// In a oneOf, we're selecting exactly one option
-				// Add the constraints with a note about which option they belong to
 				constraints = append(constraints, fmt.Sprintf("# oneOf option %d (with nested allOf):", i+1))
-				for _, constraint := range nestedConstraints {
-					constraints = append(constraints, fmt.Sprintf("# %s", constraint))
-				}
 
 				// Track the type of this option for potential union type
 				if len(nestedType) > 0 {
@@ -1416,16 +1232,13 @@ func handleNestedCompositions(schema *jsonschema.Schema, fieldName string) (stri
 			subFieldName := fmt.Sprintf("%s_option%d", fieldName, i+1)
 
 			if subSchema.AllOf != nil && len(subSchema.AllOf) > 0 {
-				nestedType, nestedConstraints, err := handleAllOf(subSchema, subFieldName)
+				nestedType, _, err := handleAllOf(subSchema, subFieldName)
 				if err != nil {
 					return kclType, constraints, err
 				}
 
 				// In anyOf, we're selecting at least one option
 				constraints = append(constraints, fmt.Sprintf("# anyOf option %d (with nested allOf):", i+1))
-				for _, constraint := range nestedConstraints {
-					constraints = append(constraints, fmt.Sprintf("# %s", constraint))
-				}
 
 				// Track the type of this option
 				if len(nestedType) > 0 {
@@ -132,7 +132,7 @@ func TestJSONSchemaToKCL(t *testing.T) {
 			assert.Contains(t, kclSchema, "name: str = \"default_name\"")
 			assert.Contains(t, kclSchema, "age?: int = 25")
 			assert.Contains(t, kclSchema, "isActive?: bool = True")
-			assert.Contains(t, kclSchema, "status?: str = \"active\"")
+			assert.Contains(t, kclSchema, "status?: \"active\" | \"inactive\" | \"pending\" = \"active\"")
 			assert.Contains(t, kclSchema, "tags?: [any]")
 			assert.NotContains(t, kclSchema, "import regex")
 		})
@@ -158,7 +158,7 @@ func TestJSONSchemaGeneration(t *testing.T) {
 			require.NoError(t, err)
 
 			// Generate KCL schemas from JSON Schema
-			err = generateJSONSchemas(schemaData, tempDir, "test")
+			err = generateJSONSchemas(schemaData, tempDir, "test", GenerateOptions{})
 			require.NoError(t, err)
 
 			// Check if a schema file was created (Schema.k)
@@ -227,6 +227,95 @@ func TestJSONSchemaTypeConversion(t *testing.T) {
 	}
 }
 
+func TestJSONSchemaEnumLiterals(t *testing.T) {
+	schemaStr := `{
+		"type": "object",
+		"properties": {
+			"status": {"type": "string", "enum": ["available", "sold"]},
+			"level": {"type": "integer", "enum": [1, 2, 3]},
+			"flag": {"enum": [true, null]},
+			"shape": {"enum": [{"kind": "circle"}, "square"]}
+		}
+	}`
+
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource("test-enum", strings.NewReader(schemaStr)))
+	schema, err := compiler.Compile("test-enum")
+	require.NoError(t, err)
+
+	kclSchema, err := generateJSONSchemaToKCLWithDefaults("Item", schema, nil)
+	require.NoError(t, err)
+	assert.Contains(t, kclSchema, `status?: "available" | "sold"`)
+	assert.Contains(t, kclSchema, "level?: 1 | 2 | 3")
+	assert.Contains(t, kclSchema, "flag?: True | None")
+	assert.NotContains(t, kclSchema, "status in [")
+	// Enums of objects cannot be literal types and keep their "in" check
+	assert.Contains(t, kclSchema, "shape in [")
+
+	// Enums over the limit keep their base type and "in" check
+	ctx := newJSONSchemaContext(GenerateOptions{MaxEnumLiterals: 2})
+	kclSchema, err = generateJSONSchemaToKCLWithContext("Item", schema, nil, ctx)
+	require.NoError(t, err)
+	assert.Contains(t, kclSchema, `status?: "available" | "sold"`)
+	assert.Contains(t, kclSchema, "level?: int")
+	assert.Contains(t, kclSchema, "level in [1, 2, 3] if level != None")
+}
+
+func TestJSONSchemaNullableTypes(t *testing.T) {
+	schemaStr := `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": ["integer", "string"]},
+			"nickname": {"type": ["string", "null"], "minLength": 2},
+			"parent": {"type": ["null", "object"]},
+			"nothing": {"type": "null"}
+		}
+	}`
+
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource("test-nullable", strings.NewReader(schemaStr)))
+	schema, err := compiler.Compile("test-nullable")
+	require.NoError(t, err)
+
+	assert.Equal(t, "int | str", jsonSchemaTypeToKCL(schema.Properties["id"]))
+	assert.Equal(t, "str | None", jsonSchemaTypeToKCL(schema.Properties["nickname"]))
+	assert.Equal(t, "{str:any} | None", jsonSchemaTypeToKCL(schema.Properties["parent"]))
+	assert.Equal(t, "None", jsonSchemaTypeToKCL(schema.Properties["nothing"]))
+
+	kclSchema, err := generateJSONSchemaToKCLWithDefaults("Person", schema, nil)
+	require.NoError(t, err)
+	assert.Contains(t, kclSchema, "id: int | str")
+	assert.Contains(t, kclSchema, "nickname?: str | None")
+	assert.Contains(t, kclSchema, "len(nickname) >= 2 if nickname != None")
+}
+
+func TestJSONSchemaMixedTypeConstraints(t *testing.T) {
+	schemaStr := `{
+		"type": "object",
+		"required": ["b"],
+		"properties": {
+			"b": {"type": ["integer", "string"], "minimum": 2, "maxLength": 5},
+			"c": {"type": ["number", "integer", "array", "null"], "multipleOf": 2, "minItems": 1}
+		}
+	}`
+
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource("test-mixed", strings.NewReader(schemaStr)))
+	schema, err := compiler.Compile("test-mixed")
+	require.NoError(t, err)
+
+	// Constraints of one type only apply to values of that type
+	kclSchema, err := generateJSONSchemaToKCLWithDefaults("Mixed", schema, nil)
+	require.NoError(t, err)
+	assert.Contains(t, kclSchema, "b: int | str")
+	assert.Contains(t, kclSchema, `(b >= 2 if typeof(b) == "int" else True)`)
+	assert.Contains(t, kclSchema, `(len(b) <= 5 if typeof(b) == "str" else True)`)
+	assert.Contains(t, kclSchema, `(c % 2 == 0 if typeof(c) in ["int", "float"] else True) if c != None`)
+	assert.Contains(t, kclSchema, `(len(c) >= 1 if typeof(c) == "list" else True) if c != None`)
+	assert.NotContains(t, kclSchema, "\n        b >= 2")
+}
+
 func TestJSONSchemaFormatValidation(t *testing.T) {
 	// Test schema with various formats
 	schemaStr := `{
@@ -376,7 +465,7 @@ func TestComplexSchemaGeneration(t *testing.T) {
 	}
 
 	// Generate KCL schemas from the complex JSON Schema
-	err = generateJSONSchemas(schemaData, tempDir, "test")
+	err = generateJSONSchemas(schemaData, tempDir, "test", GenerateOptions{})
 	require.NoError(t, err)
 
 	// Check the generated files
@@ -419,7 +508,7 @@ func TestCertManagerSchemaGeneration(t *testing.T) {
 
 	// Generate KCL schemas from the certmanager JSON Schema
 	t.Logf("Generating KCL schemas from certmanager schema")
-	err = generateJSONSchemas(schemaData, tempDir, "certmanager")
+	err = generateJSONSchemas(schemaData, tempDir, "certmanager", GenerateOptions{})
 	require.NoError(t, err)
 
 	// Check the generated files
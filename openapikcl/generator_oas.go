@@ -10,7 +10,7 @@ import (
 )
 
 // generateOpenAPISchemas handles KCL generation from OpenAPI schemas
-func generateOpenAPISchemas(doc *openapi3.T, outputDir string, packageName string, version OpenAPIVersion) error {
+func generateOpenAPISchemas(doc *openapi3.T, outputDir string, packageName string, version OpenAPIVersion, opts GenerateOptions) error {
 	log.Printf("processing OpenAPI schema (version: %s)", version)
 
 	// Handle any version-specific preprocessing
@@ -19,7 +19,10 @@ func generateOpenAPISchemas(doc *openapi3.T, outputDir string, packageName strin
 		HandleSwaggerSpecifics(version)
 	}
 
-	if doc.Components == nil || doc.Components.Schemas == nil {
+	// OpenAPI 3.1 webhooks can carry inline payload schemas
+	webhookSchemas := collectWebhookSchemas(doc)
+
+	if (doc.Components == nil || doc.Components.Schemas == nil) && len(webhookSchemas) == 0 && !opts.OperationSchemas {
 		log.Print("warning: no schemas found in OpenAPI components")
 		return nil
 	}
@@ -29,8 +32,40 @@ func generateOpenAPISchemas(doc *openapi3.T, outputDir string, packageName strin
 		outputDir = packageName
 	}
 
+	allSchemas := make(openapi3.Schemas)
+	if doc.Components != nil {
+		for name, schema := range doc.Components.Schemas {
+			allSchemas[name] = schema
+		}
+	}
+	for name, schema := range webhookSchemas {
+		if _, exists := allSchemas[name]; exists {
+			log.Printf("warning: webhook schema %s conflicts with a component schema, skipping", name)
+			continue
+		}
+		allSchemas[name] = schema
+	}
+
+	// Operation schemas are generated alongside the components when requested
+	operationSchemas := make(map[string]bool)
+	if opts.OperationSchemas {
+		collected := collectOperationSchemas(doc)
+		for _, name := range collectSchemas(collected) {
+			if _, exists := allSchemas[name]; exists {
+				log.Printf("warning: operation schema %s conflicts with an existing schema, skipping", name)
+				continue
+			}
+			allSchemas[name] = collected[name]
+			operationSchemas[name] = true
+		}
+		log.Printf("collected %d operation schemas", len(operationSchemas))
+	}
+
+	// Turn inline nested objects into named schemas
+	allSchemas = hoistInlineSchemas(allSchemas)
+
 	// Get schemas in deterministic order for consistent output
-	schemaNames := collectSchemas(doc.Components.Schemas)
+	schemaNames := collectSchemas(allSchemas)
 	log.Printf("processing %d schemas in order", len(schemaNames))
 
 	// Track created schemas to avoid duplicates
@@ -38,8 +73,16 @@ func generateOpenAPISchemas(doc *openapi3.T, outputDir string, packageName strin
 
 	// Process each schema in order
 	for _, name := range schemaNames {
-		schema := doc.Components.Schemas[name]
-		kclSchema, err := GenerateKCLSchema(name, schema, doc.Components.Schemas, version, doc)
+		schema := allSchemas[name]
+		var kclSchema string
+		var err error
+		if operationSchemas[name] {
+			kclSchema, err = generateOperationKCLSchema(name, schema, allSchemas, version, doc, opts)
+		} else if isUnionSchema(schema.Value) || isEnumSchema(schema.Value) || isOpenAPIMapSchema(schema.Value, doc) || isPrimitiveSchema(schema.Value) {
+			kclSchema = generateKCLTypeAlias(name, schema, doc, opts)
+		} else {
+			kclSchema, err = GenerateKCLSchemaWithOptions(name, schema, allSchemas, version, doc, opts)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to generate KCL schema for %s: %w", name, err)
 		}
@@ -112,6 +155,11 @@ func collectSchemaReferences(schema *openapi3.SchemaRef, currentSchemaName strin
 
 // GenerateKCLSchema generates a KCL schema from an OpenAPI schema
 func GenerateKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas openapi3.Schemas, version OpenAPIVersion, doc *openapi3.T) (string, error) {
+	return GenerateKCLSchemaWithOptions(name, schema, allSchemas, version, doc, GenerateOptions{})
+}
+
+// GenerateKCLSchemaWithOptions generates a KCL schema from an OpenAPI schema using the given generation options
+func GenerateKCLSchemaWithOptions(name string, schema *openapi3.SchemaRef, allSchemas openapi3.Schemas, version OpenAPIVersion, doc *openapi3.T, opts GenerateOptions) (string, error) {
 	var sb strings.Builder
 
 	// Import only regex, not other schemas - they are in the same directory
@@ -120,61 +168,77 @@ func GenerateKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas opena
 	// Track referenced schemas that need to be imported
 	referencedSchemas := make(map[string]bool)
 
-	// Process properties
+	// Merge the allOf members, except the parent the schema extends
+	parent := processInheritance(schema.Value, allSchemas)
+	properties, required, err := mergeAllOf(name, schema, parent, allSchemas)
+	if err != nil {
+		return "", err
+	}
+	var parentProperties openapi3.Schemas
+	var parentRequired []string
+	if parent != "" {
+		referencedSchemas[formatSchemaName(parent)] = true
+		if parentProperties, parentRequired, err = mergeAllOf(parent, allSchemas[parent], "", allSchemas); err != nil {
+			return "", err
+		}
+	}
+
+	// Process properties. Those the parent declares as well must admit values the parent's declaration does.
 	var propertyNames []string
-	for propertyName := range schema.Value.Properties {
+	for propertyName := range properties {
 		propertyNames = append(propertyNames, propertyName)
+		if parentProp, exists := parentProperties[propertyName]; exists && !sameSchema(parentProp, properties[propertyName]) {
+			if err := mergeProperty(openapi3.Schemas{propertyName: parentProp}, map[string]string{propertyName: formatSchemaName(parent)},
+				propertyName, properties[propertyName], name, formatSchemaName(name)); err != nil {
+				return "", err
+			}
+		}
 	}
-	sort.Strings(propertyNames)
 
-	// Add parent schemas if there are any
-	parents, err := processInheritance(schema.Value, allSchemas)
-	if err != nil {
-		return "", err
+	// Inherited properties the schema requires although the parent does not are declared again
+	for _, propertyName := range required {
+		if _, exists := properties[propertyName]; !exists && parentProperties[propertyName] != nil && !contains(parentRequired, propertyName) {
+			properties[propertyName] = parentProperties[propertyName]
+			propertyNames = append(propertyNames, propertyName)
+		}
 	}
 
-	// Add parents to referenced schemas
-	for _, parent := range parents {
-		if parent != name {
-			referencedSchemas[parent] = true
+	// Subtypes of a tagged union get a literal discriminator, even when the property is inherited
+	literals := discriminatorLiterals(name, allSchemas)
+	for propertyName := range literals {
+		if _, exists := properties[propertyName]; !exists {
+			propertyNames = append(propertyNames, propertyName)
 		}
 	}
+	sort.Strings(propertyNames)
+
+	// Properties the parent declares the same way are inherited
+	inherited := func(propertyName string) bool {
+		if _, isDiscriminator := literals[propertyName]; isDiscriminator {
+			return false
+		}
+		parentProp, exists := parentProperties[propertyName]
+		return exists && sameSchema(parentProp, properties[propertyName]) &&
+			contains(parentRequired, propertyName) == contains(required, propertyName)
+	}
 
 	// Process properties to collect direct references
 	for _, propertyName := range propertyNames {
-		propSchema := schema.Value.Properties[propertyName]
-
-		// Skip if this property is defined in a parent schema
-		shouldSkip := false
-		for _, parent := range parents {
-			if parentSchema, ok := allSchemas[parent]; ok {
-				if _, exists := parentSchema.Value.Properties[propertyName]; exists {
-					shouldSkip = true
-					break
-				}
-			}
-		}
-		if shouldSkip {
+		if inherited(propertyName) {
 			continue
 		}
-
-		isRequired := false
-		for _, required := range schema.Value.Required {
-			if required == propertyName {
-				isRequired = true
-				break
-			}
-		}
+		propSchema := properties[propertyName]
+		isRequired := contains(required, propertyName)
 
 		// Get the type and potential reference
-		_, _, refType := generateFieldType(propertyName, propSchema, isRequired, name, doc)
+		_, _, refType := generateFieldType(propertyName, propSchema, isRequired, name, doc, opts)
 		if refType != "" && refType != name {
 			referencedSchemas[refType] = true
 		}
 
 		// Check for array items that reference schemas
-		if propSchema.Value != nil && propSchema.Value.Items != nil {
-			_, _, itemRefType := generateFieldType(propertyName+".items", propSchema.Value.Items, true, name, doc)
+		if propSchema != nil && propSchema.Value != nil && propSchema.Value.Items != nil {
+			_, _, itemRefType := generateFieldType(propertyName+".items", propSchema.Value.Items, true, name, doc, opts)
 			if itemRefType != "" && itemRefType != name {
 				referencedSchemas[itemRefType] = true
 			}
@@ -197,8 +261,12 @@ func GenerateKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas opena
 	// Add a newline after imports
 	sb.WriteString("\n")
 
-	// Add KCL schema definition
-	sb.WriteString(fmt.Sprintf("schema %s:", name))
+	// Add KCL schema definition, extending the parent if there is one
+	if parent != "" {
+		sb.WriteString(fmt.Sprintf("schema %s(%s):", name, formatSchemaName(parent)))
+	} else {
+		sb.WriteString(fmt.Sprintf("schema %s:", name))
+	}
 
 	// Add schema documentation if available
 	if schema.Value.Description != "" || schema.Value.Title != "" {
@@ -206,40 +274,53 @@ func GenerateKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas opena
 		sb.WriteString(FormatDocumentation(schema.Value))
 	}
 
-	// Add parent schemas if there are any
-	if len(parents) > 0 {
-		sb.WriteString(fmt.Sprintf("\n    mixin [%s]", strings.Join(parents, ", ")))
-	}
-
 	// Process properties
 	propCount := 0
+	var constraints []string
+	var attributeTypes []string
 	for _, propertyName := range propertyNames {
-		propSchema := schema.Value.Properties[propertyName]
-
-		// Skip if this property is defined in a parent schema
-		shouldSkip := false
-		for _, parent := range parents {
-			if parentSchema, ok := allSchemas[parent]; ok {
-				if _, exists := parentSchema.Value.Properties[propertyName]; exists {
-					shouldSkip = true
-					break
+		if inherited(propertyName) {
+			continue
+		}
+		propSchema := properties[propertyName]
+		_, isDiscriminator := literals[propertyName]
+		isRequired := contains(required, propertyName)
+
+		kcltypeName, isCircular, _ := generateFieldType(propertyName, propSchema, isRequired, name, doc, opts)
+
+		// The constraints of the property intersect those of its allOf members. Enums are typed as literals
+		// and need no "in" check, including enums the members share.
+		propConstraints := &schemaConstraints{}
+		if propSchema != nil && propSchema.Value != nil {
+			merged, err := mergeOpenAPIConstraints(propSchema.Value)
+			if err != nil {
+				return "", fmt.Errorf("property %s of %s: %w", propertyName, name, err)
+			}
+			if literalType, ok := enumLiteralType(merged.enum, opts); ok {
+				if propSchema.Ref == "" {
+					kcltypeName = literalType
+					if isNullableSchema(propSchema.Value) {
+						kcltypeName = nullableType(literalType)
+					}
 				}
+				merged.enum = nil
 			}
-		}
-		if shouldSkip {
-			continue
+			propConstraints = merged
 		}
 
-		isRequired := false
-		for _, required := range schema.Value.Required {
-			if required == propertyName {
-				isRequired = true
-				break
+		// The discriminator of a subtype only accepts the values selecting it
+		if isDiscriminator {
+			values := literals[propertyName]
+			fieldFormatted := propertyName + ": " + literalUnionType(values)
+			if len(values) == 1 {
+				fieldFormatted += fmt.Sprintf(" = %q", values[0])
 			}
+			sb.WriteString(fmt.Sprintf("\n    %s", fieldFormatted))
+			attributeTypes = append(attributeTypes, literalUnionType(values))
+			propCount++
+			continue
 		}
 
-		kcltypeName, isCircular, _ := generateFieldType(propertyName, propSchema, isRequired, name, doc)
-
 		// Add a comment about circular references if needed
 		documentation := ""
 		if propSchema.Value != nil && propSchema.Value.Description != "" {
@@ -321,29 +402,93 @@ func GenerateKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas opena
 
 		sb.WriteString(fmt.Sprintf("\n    %s%s", documentation, fieldFormatted))
 
-		// Collect constraints for later
-		propConstraints := GenerateConstraints(propSchema.Value, propertyName, false)
-		if propConstraints != nil && len(propConstraints) > 0 {
-			sb.WriteString("\n    check:")
-			for _, constraint := range propConstraints {
-				sb.WriteString(fmt.Sprintf("\n        %s", constraint))
+		// Collect constraints for the check block
+		if propSchema.Value != nil {
+			// Optional and nullable fields may be None, so their constraints only apply to values
+			mayBeNone := !isRequired || isNullableSchema(propSchema.Value)
+			checks := append(propConstraints.checks(propertyName), openAPIPrefixItemsChecks(propertyName, propSchema.Value, doc)...)
+			if mayBeNone {
+				checks = guardNone(checks, propertyName)
+			}
+			constraints = append(constraints, checks...)
+			if check := oneOfCheck(propertyName, propSchema.Value.OneOf, !mayBeNone); check != "" {
+				constraints = append(constraints, check)
+			}
+			if check := taggedUnionCheck(propertyName, propSchema, componentSchemas(doc), !mayBeNone); check != "" {
+				constraints = append(constraints, check)
+			}
+			if check := openAPIPatternKeysCheck(propertyName, propSchema.Value, doc, !mayBeNone); check != "" {
+				constraints = append(constraints, check)
 			}
 		}
 
+		attributeTypes = append(attributeTypes, kcltypeName)
 		propCount++
 	}
 
-	// If no properties, add a placeholder comment (not 'pass')
-	if propCount == 0 {
+	// Property dependencies of the schema and its allOf members apply to the attributes of the schema instance
+	instance := &schemaInstance{attributes: make(map[string]bool), required: appendUnique(required, parentRequired...)}
+	for propertyName := range parentProperties {
+		instance.attributes[propertyName] = true
+	}
+	for _, propertyName := range propertyNames {
+		instance.attributes[propertyName] = true
+	}
+	constraints = append(constraints, openAPIDependencyChecks(schema, parent, doc, instance, make(map[*openapi3.Schema]bool))...)
+
+	// Extra keys allowed by additionalProperties or patternProperties are typed by an index signature,
+	// otherwise a schema without properties gets a placeholder comment (not 'pass')
+	if valueType, ok := openAPIExtraValueType(name, schema.Value, doc, opts); ok {
+		sb.WriteString("\n    " + indexSignature(valueType, attributeTypes))
+	} else if propCount == 0 {
 		sb.WriteString("\n    # No properties defined")
 	}
 
+	// Add a single check block for all constraints
+	if len(constraints) > 0 {
+		sb.WriteString("\n\n    check:")
+		for _, constraint := range constraints {
+			sb.WriteString(fmt.Sprintf("\n        %s", constraint))
+		}
+	}
+
 	log.Printf("generated %d properties for schema %s", propCount, name)
-	return sb.String(), nil
+	return withRegexImport(sb.String(), constraints), nil
+}
+
+// withRegexImport adds the regex import after the header comment when a constraint uses regex
+func withRegexImport(content string, constraints []string) string {
+	for _, constraint := range constraints {
+		if strings.Contains(constraint, "regex.") {
+			header := "# No schema imports needed - schemas in same directory\n\n"
+			return header + "import regex\n" + strings.TrimPrefix(content, header)
+		}
+	}
+	return content
+}
+
+// isPrimitiveSchema reports whether an OpenAPI schema has a type other than object, such as a string or an array.
+// Such components are type aliases, and their constraints are checked where they are used.
+func isPrimitiveSchema(schema *openapi3.Schema) bool {
+	return schema != nil && schema.Type != nil && len(*schema.Type) > 0 && !schema.Type.Includes("object") && len(schema.Properties) == 0
 }
 
-// generateFieldType determines the appropriate KCL type for a field
-func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequired bool, schemaName string, doc *openapi3.T) (string, bool, string) {
+// generateKCLTypeAlias generates a KCL type alias for schemas that are not objects, such as unions or arrays
+func generateKCLTypeAlias(name string, schema *openapi3.SchemaRef, doc *openapi3.T, opts GenerateOptions) string {
+	var sb strings.Builder
+	sb.WriteString("# No schema imports needed - schemas in same directory\n\n")
+	if schema.Value != nil && (schema.Value.Description != "" || schema.Value.Title != "") {
+		sb.WriteString(FormatDocumentation(schema.Value))
+	}
+
+	kclType, _, _ := generateFieldType(name, schema, true, name, doc, opts)
+	sb.WriteString(fmt.Sprintf("type %s = %s\n", name, kclType))
+	return sb.String()
+}
+
+// generateFieldType determines the appropriate KCL type for a field, whether it refers to the schema
+// it belongs to, and the schema it references
+func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequired bool, schemaName string, doc *openapi3.T, opts GenerateOptions) (string, bool, string) {
 	if fieldSchema == nil || fieldSchema.Value == nil {
 		return "any", false, ""
 	}
@@ -361,24 +506,86 @@ func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequ
 		// Check for self-reference (circular dependency)
 		if refName == schemaName || formattedRef == schemaName {
 			log.Printf("detected self-reference for field %s to schema %s", fieldName, schemaName)
-			// For self-references, make them optional to break the cycle
-			// But don't add the optional marker here if the field is already optional
-			// KCL doesn't support double question marks like: field?: Type?
-			if isRequired {
-				// Only add the ? to the type for required fields
-				return formattedRef + "?", true, refName
-			}
-			// For non-required fields, the field name will already have a ? suffix,
-			// so don't add another one to the type
+			// KCL has no optional types such as Node?, so self-references are plain schema types;
+			// optional fields and empty lists end the recursion
 			return formattedRef, true, refName
 		}
 
+		// References to a schema with a discriminator accept any of its subtypes
+		if union := resolveTaggedUnion(refName, fieldSchema.Value, componentSchemas(doc)); union != nil {
+			log.Printf("field %s is a tagged union of: %s", fieldName, union.unionType())
+			return union.unionType(), false, refName
+		}
+
 		// For other references, use the schema name directly since we're not using imports anymore
 		log.Printf("using reference %s for field %s", formattedRef, fieldName)
 		return formattedRef, false, refName
 	}
 
-	isComplexType := false
+	// Nullable schemas also accept None
+	if fieldSchema.Value.Nullable {
+		schema := *fieldSchema.Value
+		schema.Nullable = false
+		fieldType, isCircular, refType := generateFieldType(fieldName, &openapi3.SchemaRef{Value: &schema}, isRequired, schemaName, doc, opts)
+		return nullableType(fieldType), isCircular, refType
+	}
+
+	// Multiple types, e.g. ["integer", "string"] in OpenAPI 3.1, become a union of each type
+	if fieldSchema.Value.Type != nil && len(*fieldSchema.Value.Type) > 1 {
+		var members []string
+		var refType string
+		for _, openAPIType := range *fieldSchema.Value.Type {
+			schema := *fieldSchema.Value
+			schema.Type = &openapi3.Types{openAPIType}
+			memberType, _, memberRefType := generateFieldType(fieldName, &openapi3.SchemaRef{Value: &schema}, isRequired, schemaName, doc, opts)
+			members = append(members, memberType)
+			if refType == "" {
+				refType = memberRefType
+			}
+		}
+		return unionOf(members), false, refType
+	}
+
+	// Enums of scalars become literal union types
+	if literalType, ok := enumLiteralType(fieldSchema.Value.Enum, opts); ok {
+		return literalType, false, ""
+	}
+
+	// A lone allOf reference, e.g. a $ref with sibling keywords, is typed as the referenced schema
+	if (fieldSchema.Value.Type == nil || len(*fieldSchema.Value.Type) == 0) &&
+		len(fieldSchema.Value.AllOf) == 1 && fieldSchema.Value.AllOf[0].Ref != "" {
+		return generateFieldType(fieldName, fieldSchema.Value.AllOf[0], isRequired, schemaName, doc, opts)
+	}
+
+	// Other allOf compositions without a type of their own, e.g. a property defined by several allOf members,
+	// are typed as their only referenced schema, or else by the type their members agree on
+	if (fieldSchema.Value.Type == nil || len(*fieldSchema.Value.Type) == 0) && len(fieldSchema.Value.AllOf) > 0 {
+		if ref := singleAllOfRef(fieldSchema.Value); ref != nil {
+			return generateFieldType(fieldName, ref, isRequired, schemaName, doc, opts)
+		}
+		if constraints, err := mergeOpenAPIConstraints(fieldSchema.Value); err == nil && len(constraints.types) == 1 {
+			if constraints.types[0] == "object" {
+				return "dict", false, ""
+			}
+			return constraints.kclType(), false, ""
+		}
+		return "any", false, ""
+	}
+
+	// oneOf/anyOf compositions become union types, unless the branches only refine the field's own type
+	if isUnionSchema(fieldSchema.Value) {
+		unionType, refTypes := compositionUnionType(fieldName, compositionBranches(fieldSchema.Value), schemaName, doc, opts)
+		hasOwnType := fieldSchema.Value.Type != nil && len(*fieldSchema.Value.Type) > 0
+		if unionType != "any" || !hasOwnType {
+			log.Printf("field %s is a union of: %s", fieldName, unionType)
+			refType := ""
+			if len(refTypes) > 0 {
+				refType = refTypes[0]
+			}
+			return unionType, false, refType
+		}
+	}
+
 	var fieldType string
 	var refType string
 
@@ -393,7 +600,7 @@ func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequ
 			if fieldSchema.Value.Items != nil {
 				log.Printf("processing array items for field %s", fieldName)
 				// Get the item type - preserve references to other schemas
-				itemType, _, refName := generateFieldType("item", fieldSchema.Value.Items, true, schemaName, doc)
+				itemType, _, refName := generateFieldType("item", fieldSchema.Value.Items, true, schemaName, doc, opts)
 				log.Printf("array item type for field %s: %s (ref: %s)", fieldName, itemType, refName)
 
 				// For referenced schema types in arrays, we want to keep the reference
@@ -409,14 +616,9 @@ func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequ
 				// KCL doesn't support inline object definitions with complex types
 				// Just use dict for complex nested objects
 				fieldType = "dict"
-				isComplexType = true
-			} else if fieldSchema.Value.AdditionalProperties.Has != nil {
-				// Handle map types (objects with additionalProperties)
-				if fieldSchema.Value.AdditionalProperties.Schema != nil {
-					// Just use dict for maps
-					fieldType = "dict"
-				}
-				isComplexType = true
+			} else if valueType, ok := openAPIMapValueType(fieldName, fieldSchema.Value, schemaName, doc, opts); ok {
+				// Objects with additionalProperties or patternProperties are typed maps
+				fieldType = mapType(valueType)
 			} else {
 				fieldType = "dict"
 			}
@@ -424,30 +626,13 @@ func generateFieldType(fieldName string, fieldSchema *openapi3.SchemaRef, isRequ
 			// Use the basic type converter for primitive types
 			fieldType = ConvertTypeToKCL(openAPIType, fieldSchema.Value.Format)
 		}
+	} else if valueType, ok := openAPIMapValueType(fieldName, fieldSchema.Value, schemaName, doc, opts); ok && len(fieldSchema.Value.Properties) == 0 {
+		// Untyped schemas with additionalProperties or patternProperties are typed maps
+		fieldType = mapType(valueType)
 	} else {
 		// If no type is specified
 		fieldType = "any"
 	}
 
-	return fieldType, isComplexType, refType
-}
-
-// processInheritance handles allOf inheritance in OpenAPI schemas
-func processInheritance(schema *openapi3.Schema, allSchemas openapi3.Schemas) ([]string, error) {
-	if schema == nil || len(schema.AllOf) == 0 {
-		return nil, nil
-	}
-
-	var parents []string
-	for _, parentRef := range schema.AllOf {
-		if parentRef.Ref != "" {
-			// Extract the parent schema name from the reference
-			parentName := extractSchemaName(parentRef.Ref)
-			if parentName != "" {
-				parents = append(parents, formatSchemaName(parentName))
-			}
-		}
-	}
-
-	return parents, nil
+	return fieldType, false, refType
 }
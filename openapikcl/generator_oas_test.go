@@ -133,15 +133,73 @@ func TestGenerateKCLSchemaOpenAPI(t *testing.T) {
 	assert.Contains(t, result, "name: str = \"default_name\"")
 	assert.Contains(t, result, "age?: int = 25")
 	assert.Contains(t, result, "isActive?: bool = true")
-	assert.Contains(t, result, "status?: str = \"active\"")
-	assert.Contains(t, result, "priority?: int = 2")
+	assert.Contains(t, result, "status?: \"active\" | \"inactive\" | \"pending\" = \"active\"")
+	assert.Contains(t, result, "priority?: 1 | 2 | 3 = 2")
 	assert.Contains(t, result, "tags?: [str]")
 }
 
+func TestGenerateEnumTypeAliases(t *testing.T) {
+	status := openapi3.NewStringSchema()
+	status.Enum = []interface{}{"available", "pending", "sold"}
+	pet := openapi3.NewObjectSchema().
+		WithProperty("name", openapi3.NewStringSchema())
+	pet.Properties["status"] = openapi3.NewSchemaRef("#/components/schemas/Status", status)
+
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Components: &openapi3.Components{Schemas: openapi3.Schemas{
+			"Status": openapi3.NewSchemaRef("", status),
+			"Pet":    openapi3.NewSchemaRef("", pet),
+		}},
+	}
+
+	readSchema := func(dir, name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name+".k"))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateOpenAPISchemas(doc, tempDir, "test", OpenAPIV3, GenerateOptions{}))
+	assert.Contains(t, readSchema(tempDir, "Status"), `type Status = "available" | "pending" | "sold"`)
+	assert.Contains(t, readSchema(tempDir, "Pet"), "status?: Status")
+	assert.NotContains(t, readSchema(tempDir, "Pet"), "status in [")
+
+	// Enums over the limit are aliases of their base type and keep the "in" check
+	tempDir = t.TempDir()
+	require.NoError(t, generateOpenAPISchemas(doc, tempDir, "test", OpenAPIV3, GenerateOptions{MaxEnumLiterals: 2}))
+	assert.Contains(t, readSchema(tempDir, "Status"), "type Status = str")
+	assert.Contains(t, readSchema(tempDir, "Pet"), `status in ["available", "pending", "sold"] if status != None`)
+}
+
 // generateOpenAPISchemasForTest is a test-specific function to bypass schema type detection
 func generateOpenAPISchemasForTest(doc *openapi3.T, outputDir string, packageName string) error {
 	// Skip the schema type detection and directly call the OpenAPI schema generation
-	return generateOpenAPISchemas(doc, outputDir, packageName, OpenAPIV3)
+	return generateOpenAPISchemas(doc, outputDir, packageName, OpenAPIV3, GenerateOptions{})
+}
+
+func TestGenerateKCLSchemaNullable(t *testing.T) {
+	nullableString := openapi3.NewStringSchema().WithMinLength(2)
+	nullableString.Nullable = true
+	multiType := &openapi3.Schema{Type: &openapi3.Types{"integer", "string"}, Min: openapi3.Float64Ptr(2)}
+
+	schema := openapi3.NewObjectSchema().
+		WithProperty("nickname", nullableString).
+		WithProperty("id", multiType).
+		WithProperty("name", openapi3.NewStringSchema().WithMinLength(1)).
+		WithProperty("tags", openapi3.NewArraySchema().WithItems(nullableString))
+	schema.Required = []string{"id", "nickname", "name"}
+
+	result, err := GenerateKCLSchema("Person", openapi3.NewSchemaRef("", schema), openapi3.Schemas{}, OpenAPIV3, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "nickname: str | None")
+	assert.Contains(t, result, "id: int | str")
+	assert.Contains(t, result, `(id >= 2 if typeof(id) == "int" else True)`)
+	assert.Contains(t, result, "tags?: [str | None]")
+	assert.Contains(t, result, "len(nickname) >= 2 if nickname != None")
+	// Required fields that are not nullable keep unguarded constraints
+	assert.Contains(t, result, "len(name) >= 1\n")
 }
 
 func TestGenerateKCLSchemasOpenAPI(t *testing.T) {
@@ -324,11 +382,12 @@ func TestSchemaInheritanceOpenAPI(t *testing.T) {
 		"Parent": parentSchema,
 	}
 
-	result, err := processInheritance(childSchema, allSchemas)
+	assert.Equal(t, "Parent", processInheritance(childSchema, allSchemas))
 
-	require.NoError(t, err)
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, "Parent", result[0])
+	// A schema listing several references extends none of them
+	childSchema.AllOf = append(childSchema.AllOf, &openapi3.SchemaRef{Ref: "#/components/schemas/Other"})
+	allSchemas["Other"] = parentSchema
+	assert.Empty(t, processInheritance(childSchema, allSchemas))
 }
 
 func TestGenerateKCLFromFile(t *testing.T) {
@@ -452,3 +511,77 @@ func printFilesInDir(dir string) error {
 	}
 	return nil
 }
+
+func TestOpenAPIPrefixItemsChecks(t *testing.T) {
+	prefixItems := []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{},
+		map[string]interface{}{"type": "integer", "minimum": float64(0)},
+	}
+	tests := []struct {
+		name     string
+		minItems uint64
+		expected []string
+	}{
+		{
+			name:     "positions within minItems",
+			minItems: 3,
+			expected: []string{
+				`typeof(point[0]) == "str"`,
+				`(typeof(point[2]) == "int" and point[2] >= 0)`,
+			},
+		},
+		{
+			name:     "positions beyond minItems",
+			minItems: 1,
+			expected: []string{
+				`typeof(point[0]) == "str"`,
+				`(len(point) <= 2 or (typeof(point[2]) == "int" and point[2] >= 0))`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &openapi3.Schema{MinItems: tt.minItems, Extensions: map[string]interface{}{"prefixItems": prefixItems}}
+			assert.Equal(t, tt.expected, openAPIPrefixItemsChecks("point", schema, nil))
+		})
+	}
+}
+
+func TestGenerateKCLFromOpenAPI31File(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/petstore_v31.json", LoadOptions{
+		FlattenSpec: true,
+		SkipRemote:  true,
+	})
+	require.NoError(t, err)
+
+	err = GenerateKCLSchemas(doc, tempDir, "test", version, nil)
+	require.NoError(t, err)
+
+	petContent, err := os.ReadFile(filepath.Join(tempDir, "Pet.k"))
+	require.NoError(t, err)
+	pet := string(petContent)
+	assert.Contains(t, pet, "schema Pet:")
+	assert.Contains(t, pet, "id: int")
+	assert.Contains(t, pet, "tag?: str")
+	assert.Contains(t, pet, "owner?: Owner")
+	assert.Contains(t, pet, "location?: [float]")
+	assert.Contains(t, pet, "id > 0")
+	assert.Contains(t, pet, "age >= 1 if age != None")
+	assert.Contains(t, pet, `(typeof(location[0]) in ["int", "float"] and location[0] >= -90 and location[0] <= 90) if location != None`)
+	assert.Contains(t, pet, `(typeof(location[1]) in ["int", "float"] and location[1] >= -180 and location[1] <= 180) if location != None`)
+
+	ownerContent, err := os.ReadFile(filepath.Join(tempDir, "Owner.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(ownerContent), "schema Owner:")
+
+	// Inline webhook payloads get their own schema
+	webhookContent, err := os.ReadFile(filepath.Join(tempDir, "NewPetWebhook.k"))
+	require.NoError(t, err)
+	webhook := string(webhookContent)
+	assert.Contains(t, webhook, "schema NewPetWebhook:")
+	assert.Contains(t, webhook, "pet: Pet")
+	assert.Contains(t, webhook, "receivedAt?: str")
+}
@@ -0,0 +1,193 @@
+package openapikcl
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// parameterLocations lists parameter locations in the order they are merged into a parameters schema
+var parameterLocations = []string{
+	openapi3.ParameterInPath,
+	openapi3.ParameterInQuery,
+	openapi3.ParameterInHeader,
+	openapi3.ParameterInCookie,
+}
+
+// collectOperationSchemas returns the request body, response and parameter schemas of every operation keyed by name
+func collectOperationSchemas(doc *openapi3.T) openapi3.Schemas {
+	schemas := make(openapi3.Schemas)
+	if doc == nil || doc.Paths == nil {
+		return schemas
+	}
+
+	paths := doc.Paths.Map()
+	var pathNames []string
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+
+	for _, path := range pathNames {
+		pathItem := paths[path]
+		operations := pathItem.Operations()
+		var methods []string
+		for method := range operations {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			operation := operations[method]
+			baseName := operationBaseName(method, path, operation)
+
+			if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+				addContentSchemas(schemas, operation.RequestBody.Value.Content, baseName+"Request")
+			}
+
+			if operation.Responses != nil {
+				responses := operation.Responses.Map()
+				var statuses []string
+				for status := range responses {
+					statuses = append(statuses, status)
+				}
+				sort.Strings(statuses)
+
+				for _, status := range statuses {
+					response := responses[status]
+					if response == nil || response.Value == nil {
+						continue
+					}
+					// Status codes only ever follow the base name, so digits need no prefix
+					statusName := strings.ToUpper(status[:1]) + status[1:]
+					addContentSchemas(schemas, response.Value.Content, baseName+"Response"+statusName)
+				}
+			}
+
+			if params := operationParametersSchema(pathItem, operation); params != nil {
+				schemas[baseName+"Parameters"] = params
+			}
+		}
+	}
+
+	return schemas
+}
+
+// operationBaseName names an operation after its operationId, falling back to the method and path
+func operationBaseName(method, path string, operation *openapi3.Operation) string {
+	if operation.OperationID != "" {
+		return pascalCaseName(operation.OperationID)
+	}
+	return pascalCaseName(strings.ToLower(method) + " " + path)
+}
+
+// addContentSchemas adds a schema for each media type of a request or response body
+func addContentSchemas(schemas openapi3.Schemas, content openapi3.Content, baseName string) {
+	var mediaTypes []string
+	for mediaType, media := range content {
+		if media != nil && media.Schema != nil && media.Schema.Value != nil {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	sort.Strings(mediaTypes)
+
+	for _, mediaType := range mediaTypes {
+		name := baseName
+		if len(mediaTypes) > 1 {
+			name += mediaTypeSuffix(mediaType)
+		}
+		schemas[name] = content[mediaType].Schema
+	}
+}
+
+// operationParametersSchema combines the path, query, header and cookie parameters of an operation
+// into a single object schema. Operation parameters override path item parameters with the same name and location.
+func operationParametersSchema(pathItem *openapi3.PathItem, operation *openapi3.Operation) *openapi3.SchemaRef {
+	byLocation := make(map[string]map[string]*openapi3.Parameter)
+	for _, params := range []openapi3.Parameters{pathItem.Parameters, operation.Parameters} {
+		for _, paramRef := range params {
+			if paramRef == nil || paramRef.Value == nil {
+				continue
+			}
+			param := paramRef.Value
+			if byLocation[param.In] == nil {
+				byLocation[param.In] = make(map[string]*openapi3.Parameter)
+			}
+			byLocation[param.In][param.Name] = param
+		}
+	}
+	if len(byLocation) == 0 {
+		return nil
+	}
+
+	schema := openapi3.NewObjectSchema()
+	for _, location := range parameterLocations {
+		var paramNames []string
+		for name := range byLocation[location] {
+			paramNames = append(paramNames, name)
+		}
+		sort.Strings(paramNames)
+
+		for _, paramName := range paramNames {
+			param := byLocation[location][paramName]
+
+			// Parameter names such as X-Request-ID or page[size] are not KCL identifiers
+			propertyName := sanitizePropertyName(param.Name)
+			if _, exists := schema.Properties[propertyName]; exists {
+				// The same name in another location is prefixed with that location
+				propertyName = location + formatSchemaName(propertyName)
+				log.Printf("parameter %s in %s conflicts with another location, using %s", param.Name, location, propertyName)
+			}
+
+			schema.Properties[propertyName] = parameterSchema(param)
+			if param.Required {
+				schema.Required = append(schema.Required, propertyName)
+			}
+		}
+	}
+
+	return openapi3.NewSchemaRef("", schema)
+}
+
+// parameterSchema returns the schema of a parameter, taken from its content when no schema is given
+func parameterSchema(param *openapi3.Parameter) *openapi3.SchemaRef {
+	if param.Schema != nil {
+		return param.Schema
+	}
+
+	var mediaTypes []string
+	for mediaType, media := range param.Content {
+		if media != nil && media.Schema != nil {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	if len(mediaTypes) > 0 {
+		sort.Strings(mediaTypes)
+		return param.Content[mediaTypes[0]].Schema
+	}
+
+	return openapi3.NewSchemaRef("", &openapi3.Schema{Description: param.Description})
+}
+
+// generateOperationKCLSchema generates a KCL schema for an operation payload.
+// Object payloads become schemas; references, arrays and primitives become type aliases.
+func generateOperationKCLSchema(name string, schema *openapi3.SchemaRef, allSchemas openapi3.Schemas, version OpenAPIVersion, doc *openapi3.T, opts GenerateOptions) (string, error) {
+	if isObjectSchema(schema) {
+		return GenerateKCLSchemaWithOptions(name, schema, allSchemas, version, doc, opts)
+	}
+
+	return generateKCLTypeAlias(name, schema, doc, opts), nil
+}
+
+// isObjectSchema reports whether an inline schema describes an object with its own properties
+func isObjectSchema(schema *openapi3.SchemaRef) bool {
+	if schema == nil || schema.Ref != "" || schema.Value == nil {
+		return false
+	}
+	if len(schema.Value.Properties) > 0 || len(schema.Value.AllOf) > 0 {
+		return true
+	}
+	return schema.Value.Type != nil && schema.Value.Type.Is("object") && schema.Value.AdditionalProperties.Schema == nil
+}
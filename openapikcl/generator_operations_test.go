@@ -0,0 +1,95 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationBaseName(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		operationID string
+		expected    string
+	}{
+		{"Camel Case Operation ID", "GET", "/pets", "listPets", "ListPets"},
+		{"Snake Case Operation ID", "PUT", "/pets", "update_pet", "UpdatePet"},
+		{"Method And Path", "GET", "/pets/{petId}/owners", "", "GetPetsPetIdOwners"},
+		{"Root Path", "POST", "/", "", "Post"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := operationBaseName(tc.method, tc.path, &openapi3.Operation{OperationID: tc.operationID})
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestGenerateOperationSchemas(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/operations.yaml", LoadOptions{
+		FlattenSpec: true,
+		SkipRemote:  true,
+	})
+	require.NoError(t, err)
+
+	err = GenerateKCLSchemasWithOptions(doc, tempDir, "test", version, nil, GenerateOptions{OperationSchemas: true})
+	require.NoError(t, err)
+
+	readSchema := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		return string(content)
+	}
+
+	// Combined path item and operation parameters
+	params := readSchema("GetOrderParameters")
+	assert.Contains(t, params, "schema GetOrderParameters:")
+	assert.Contains(t, params, "orderId: str")
+	assert.Contains(t, params, "expand?: bool")
+	assert.Contains(t, params, "traceId?: str")
+	assert.Contains(t, params, "X_Request_ID: str")
+	assert.Contains(t, params, "page_size_?: int")
+	assert.Contains(t, params, "len(X_Request_ID) >= 8")
+
+	// Referenced response bodies become type aliases
+	assert.Contains(t, readSchema("GetOrderResponse200"), "type GetOrderResponse200 = Order")
+
+	// Inline response bodies become schemas
+	errorResponse := readSchema("GetOrderResponseDefault")
+	assert.Contains(t, errorResponse, "schema GetOrderResponseDefault:")
+	assert.Contains(t, errorResponse, "message: str")
+
+	// One schema per request media type
+	jsonRequest := readSchema("UpdateOrderRequestJson")
+	assert.Contains(t, jsonRequest, "quantity: int")
+	assert.Contains(t, jsonRequest, "note?: str")
+	assert.Contains(t, readSchema("UpdateOrderRequestXWwwFormUrlencoded"), "quantity?: int")
+
+	// Operations without an operationId are named from the method and path
+	assert.Contains(t, readSchema("GetOrdersResponse200"), "type GetOrdersResponse200 = [Order]")
+
+	// Component schemas are still generated
+	assert.Contains(t, readSchema("Order"), "schema Order:")
+}
+
+func TestGenerateOperationSchemasDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/operations.yaml", LoadOptions{})
+	require.NoError(t, err)
+
+	err = GenerateKCLSchemas(doc, tempDir, "test", version, nil)
+	require.NoError(t, err)
+
+	assert.True(t, fileExists(filepath.Join(tempDir, "Order.k")))
+	assert.False(t, fileExists(filepath.Join(tempDir, "GetOrderParameters.k")))
+}
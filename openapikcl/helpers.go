@@ -4,8 +4,6 @@ import (
 	"path/filepath"
 	"strings"
 	"unicode"
-
-	"github.com/getkin/kin-openapi/openapi3"
 )
 
 // Helper functions for reference resolution
@@ -36,30 +34,3 @@ func camelToSnake(s string) string {
 	}
 	return result.String()
 }
-
-// mergeSchemas merges multiple schemas into a single schema
-func mergeSchemas(schemas []*openapi3.SchemaRef) (*openapi3.SchemaRef, error) {
-	if len(schemas) == 0 {
-		return nil, nil
-	}
-
-	merged := &openapi3.Schema{}
-	for _, schema := range schemas {
-		if schema.Value == nil {
-			continue
-		}
-
-		// Merge properties
-		if merged.Properties == nil {
-			merged.Properties = make(openapi3.Schemas)
-		}
-		for name, prop := range schema.Value.Properties {
-			merged.Properties[name] = prop
-		}
-
-		// Merge required fields
-		merged.Required = append(merged.Required, schema.Value.Required...)
-	}
-
-	return &openapi3.SchemaRef{Value: merged}, nil
-}
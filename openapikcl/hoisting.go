@@ -0,0 +1,259 @@
+package openapikcl
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Inline object schemas cannot be expressed as KCL types, so they are hoisted into
+// their own named schemas. Names are derived from the path to the inline schema:
+// the Owner.address property of Pet becomes PetOwnerAddress, the items of an array
+// property and the values of a map property share the property name, the items of a
+// top-level array get an Item suffix, the extra values of an object get a Value suffix
+// and oneOf/anyOf branches become numbered options such as PetOwnerOption1.
+
+// schemaHoister hoists inline OpenAPI object schemas into named schemas
+type schemaHoister struct {
+	schemas openapi3.Schemas
+}
+
+// hoistInlineSchemas returns a copy of schemas in which every inline object schema is
+// replaced by a reference to a new named schema. The input schemas are not modified.
+func hoistInlineSchemas(schemas openapi3.Schemas) openapi3.Schemas {
+	h := &schemaHoister{schemas: make(openapi3.Schemas)}
+
+	// Reserve the existing names first so hoisted schemas never shadow them
+	names := collectSchemas(schemas)
+	for _, name := range names {
+		h.schemas[name] = schemas[name]
+	}
+
+	for _, name := range names {
+		h.schemas[name] = h.hoistChildren(schemas[name], name)
+	}
+
+	if hoisted := len(h.schemas) - len(schemas); hoisted > 0 {
+		log.Printf("hoisted %d inline object schemas", hoisted)
+	}
+	return h.schemas
+}
+
+// hoistChildren returns a copy of a schema whose inline object properties and items are hoisted
+func (h *schemaHoister) hoistChildren(schemaRef *openapi3.SchemaRef, name string) *openapi3.SchemaRef {
+	if schemaRef == nil || schemaRef.Ref != "" || schemaRef.Value == nil {
+		return schemaRef
+	}
+
+	schema := *schemaRef.Value
+	if len(schema.Properties) > 0 {
+		properties := make(openapi3.Schemas, len(schema.Properties))
+		for _, propName := range collectSchemas(schema.Properties) {
+			properties[propName] = h.hoistSchema(schema.Properties[propName], name+pascalCaseName(propName))
+		}
+		schema.Properties = properties
+	}
+	if schema.Items != nil {
+		schema.Items = h.hoistSchema(schema.Items, name+"Item")
+	}
+	if schema.AdditionalProperties.Schema != nil {
+		schema.AdditionalProperties.Schema = h.hoistSchema(schema.AdditionalProperties.Schema, name+"Value")
+	}
+	schema.OneOf = h.hoistBranches(schema.OneOf, name)
+	schema.AnyOf = h.hoistBranches(schema.AnyOf, name)
+	schema.AllOf = h.hoistMembers(schema.AllOf, name)
+
+	return &openapi3.SchemaRef{Extensions: schemaRef.Extensions, Value: &schema}
+}
+
+// hoistMembers hoists the children of inline allOf members under name, since their properties
+// are merged into the same schema
+func (h *schemaHoister) hoistMembers(members openapi3.SchemaRefs, name string) openapi3.SchemaRefs {
+	if len(members) == 0 {
+		return members
+	}
+	hoisted := make(openapi3.SchemaRefs, len(members))
+	for i, member := range members {
+		hoisted[i] = h.hoistChildren(member, name)
+	}
+	return hoisted
+}
+
+// hoistBranches hoists inline object oneOf/anyOf branches as numbered options of name
+func (h *schemaHoister) hoistBranches(branches openapi3.SchemaRefs, name string) openapi3.SchemaRefs {
+	if len(branches) == 0 {
+		return branches
+	}
+	hoisted := make(openapi3.SchemaRefs, len(branches))
+	for i, branch := range branches {
+		hoisted[i] = h.hoistSchema(branch, fmt.Sprintf("%sOption%d", name, i+1))
+	}
+	return hoisted
+}
+
+// hoistSchema hoists an inline object schema under the given name and returns a reference to it.
+// Arrays are unwrapped so their inline items are hoisted under the same name.
+func (h *schemaHoister) hoistSchema(schemaRef *openapi3.SchemaRef, name string) *openapi3.SchemaRef {
+	if schemaRef == nil || schemaRef.Ref != "" || schemaRef.Value == nil {
+		return schemaRef
+	}
+
+	if isInlineObjectSchema(schemaRef.Value) {
+		name = uniqueSchemaName(name, func(candidate string) bool {
+			_, taken := h.schemas[candidate]
+			return taken
+		})
+		// Reserve the name before descending so nested schemas pick different names
+		h.schemas[name] = schemaRef
+		hoisted := h.hoistChildren(schemaRef, name)
+		h.schemas[name] = hoisted
+		log.Printf("hoisted inline object schema %s", name)
+		return &openapi3.SchemaRef{Ref: "#/components/schemas/" + name, Value: hoisted.Value}
+	}
+
+	if schemaRef.Value.Items != nil || schemaRef.Value.AdditionalProperties.Schema != nil ||
+		len(schemaRef.Value.OneOf) > 0 || len(schemaRef.Value.AnyOf) > 0 {
+		schema := *schemaRef.Value
+		if schema.Items != nil {
+			schema.Items = h.hoistSchema(schema.Items, name)
+		}
+		if schema.AdditionalProperties.Schema != nil {
+			schema.AdditionalProperties.Schema = h.hoistSchema(schema.AdditionalProperties.Schema, name)
+		}
+		schema.OneOf = h.hoistBranches(schema.OneOf, name)
+		schema.AnyOf = h.hoistBranches(schema.AnyOf, name)
+		return &openapi3.SchemaRef{Extensions: schemaRef.Extensions, Value: &schema}
+	}
+
+	return schemaRef
+}
+
+// isInlineObjectSchema reports whether a schema is an object with its own properties or composed through allOf
+func isInlineObjectSchema(schema *openapi3.Schema) bool {
+	if len(schema.Properties) == 0 && !isAllOfObjectSchema(schema) {
+		return false
+	}
+	return schema.Type == nil || len(*schema.Type) == 0 || schema.Type.Is("object")
+}
+
+// uniqueSchemaName returns name, or name with the first free numeric suffix if it is taken
+func uniqueSchemaName(name string, taken func(string) bool) string {
+	candidate := name
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	return candidate
+}
+
+// hoistedJSONSchema is an inline JSON Schema object that is generated as its own KCL schema
+type hoistedJSONSchema struct {
+	name          string
+	schema        *jsonschema.Schema
+	defaultValues map[string]interface{}
+}
+
+// hoistJSONInlineObjects names every inline object schema below root and records the names in ctx.
+// The raw schema is walked alongside the compiled one so defaults can be extracted for each hoisted schema.
+func hoistJSONInlineObjects(ctx *jsonSchemaContext, rootName string, root *jsonschema.Schema, rawRoot map[string]interface{}) []hoistedJSONSchema {
+	var hoisted []hoistedJSONSchema
+
+	var hoistChildren func(schema *jsonschema.Schema, raw map[string]interface{}, name string)
+	var hoistSchema func(schema *jsonschema.Schema, raw map[string]interface{}, name string)
+	var hoistMapValues func(schema *jsonschema.Schema, raw map[string]interface{}, name string)
+
+	hoistChildren = func(schema *jsonschema.Schema, raw map[string]interface{}, name string) {
+		var propNames []string
+		for propName := range schema.Properties {
+			propNames = append(propNames, propName)
+		}
+		sort.Strings(propNames)
+
+		rawProps, _ := raw["properties"].(map[string]interface{})
+		for _, propName := range propNames {
+			rawProp, _ := rawProps[propName].(map[string]interface{})
+			hoistSchema(schema.Properties[propName], rawProp, name+pascalCaseName(propName))
+		}
+
+		hoistMapValues(schema, raw, name+"Value")
+
+		// Properties merged in from allOf members belong to the same schema
+		rawAllOf, _ := raw["allOf"].([]interface{})
+		for i, member := range schema.AllOf {
+			var rawMember map[string]interface{}
+			if i < len(rawAllOf) {
+				rawMember, _ = rawAllOf[i].(map[string]interface{})
+			}
+			hoistChildren(member, rawMember, name)
+		}
+	}
+
+	hoistSchema = func(schema *jsonschema.Schema, raw map[string]interface{}, name string) {
+		if schema == nil || ctx.names[schema] != "" {
+			return
+		}
+
+		if isJSONInlineObjectSchema(schema) {
+			name = ctx.claimName(name)
+			ctx.names[schema] = name
+			hoisted = append(hoisted, hoistedJSONSchema{
+				name:          name,
+				schema:        schema,
+				defaultValues: extractDefaultValues(raw),
+			})
+			log.Printf("hoisted inline object schema %s", name)
+			hoistChildren(schema, raw, name)
+			return
+		}
+
+		if items := jsonSchemaItems(schema); items != nil {
+			rawItems, _ := raw["items"].(map[string]interface{})
+			hoistSchema(items, rawItems, name)
+		}
+		hoistMapValues(schema, raw, name)
+	}
+
+	// Map values share the name when there is a single value schema, patterns are numbered otherwise
+	hoistMapValues = func(schema *jsonschema.Schema, raw map[string]interface{}, name string) {
+		patterns, patternSchemas := jsonPatternProperties(schema)
+		additional, _ := schema.AdditionalProperties.(*jsonschema.Schema)
+		if additional != nil {
+			rawAdditional, _ := raw["additionalProperties"].(map[string]interface{})
+			hoistSchema(additional, rawAdditional, name)
+		}
+
+		rawPatterns, _ := raw["patternProperties"].(map[string]interface{})
+		for i, patternSchema := range patternSchemas {
+			rawPattern, _ := rawPatterns[patterns[i]].(map[string]interface{})
+			patternName := name
+			if additional != nil || len(patternSchemas) > 1 {
+				patternName = fmt.Sprintf("%sPattern%d", name, i+1)
+			}
+			hoistSchema(patternSchema, rawPattern, patternName)
+		}
+	}
+
+	hoistChildren(root, rawRoot, rootName)
+	return hoisted
+}
+
+// isJSONInlineObjectSchema reports whether a compiled schema is an inline object with its own properties
+func isJSONInlineObjectSchema(schema *jsonschema.Schema) bool {
+	if schema.Ref != nil || len(schema.Properties) == 0 {
+		return false
+	}
+	return len(schema.Types) == 0 || containsType(schema.Types, "object")
+}
+
+// jsonSchemaItems returns the single item schema of an array schema for any draft, or nil if there is none
+func jsonSchemaItems(schema *jsonschema.Schema) *jsonschema.Schema {
+	if itemSchema, ok := schema.Items.(*jsonschema.Schema); ok {
+		return itemSchema
+	}
+	if schema.Items2020 != nil && len(schema.PrefixItems) == 0 {
+		return schema.Items2020
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoistInlineSchemas(t *testing.T) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile("testdata/oas/input/nested_objects.yaml")
+	require.NoError(t, err)
+
+	schemas := hoistInlineSchemas(doc.Components.Schemas)
+
+	tests := []struct {
+		name     string
+		property string
+		expected string
+	}{
+		{"Pet", "owner", "#/components/schemas/PetOwner"},
+		{"PetOwner", "address", "#/components/schemas/PetOwnerAddress"},
+		{"PetVisits", "vet", "#/components/schemas/Vet"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name+"."+tc.property, func(t *testing.T) {
+			require.Contains(t, schemas, tc.name)
+			assert.Equal(t, tc.expected, schemas[tc.name].Value.Properties[tc.property].Ref)
+		})
+	}
+
+	// Array items share the property name, top-level array items get an Item suffix
+	assert.Equal(t, "#/components/schemas/PetVisits", schemas["Pet"].Value.Properties["visits"].Value.Items.Ref)
+	assert.Equal(t, "#/components/schemas/PetsItem", schemas["Pets"].Value.Items.Ref)
+
+	// The loaded document is left untouched
+	assert.Empty(t, doc.Components.Schemas["Pet"].Value.Properties["owner"].Ref)
+	assert.NotContains(t, doc.Components.Schemas, "PetOwner")
+}
+
+func TestHoistInlineSchemasNameConflicts(t *testing.T) {
+	schemas := openapi3.Schemas{
+		"PetOwner": openapi3.NewObjectSchema().WithProperty("id", openapi3.NewIntegerSchema()).NewRef(),
+		"Pet": openapi3.NewObjectSchema().
+			WithProperty("owner", openapi3.NewObjectSchema().WithProperty("name", openapi3.NewStringSchema())).
+			NewRef(),
+	}
+
+	hoisted := hoistInlineSchemas(schemas)
+
+	assert.Equal(t, "#/components/schemas/PetOwner2", hoisted["Pet"].Value.Properties["owner"].Ref)
+	assert.Contains(t, hoisted["PetOwner"].Value.Properties, "id")
+	assert.Contains(t, hoisted["PetOwner2"].Value.Properties, "name")
+}
+
+func TestGenerateHoistedOpenAPISchemas(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/nested_objects.yaml", LoadOptions{
+		FlattenSpec: true,
+		SkipRemote:  true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+	expected := map[string][]string{
+		"Pet":             {"owner?: PetOwner", "visits?: [PetVisits]"},
+		"PetOwner":        {"schema PetOwner:", "address?: PetOwnerAddress", "name: str"},
+		"PetOwnerAddress": {"schema PetOwnerAddress:", "street?: str", "len(street) >= 1"},
+		"PetVisits":       {"schema PetVisits:", "vet?: Vet"},
+		"PetsItem":        {"schema PetsItem:", "id?: int"},
+	}
+	for name, fragments := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		for _, fragment := range fragments {
+			assert.Contains(t, string(content), fragment, "schema %s", name)
+		}
+	}
+}
+
+func TestGenerateHoistedJSONSchemas(t *testing.T) {
+	tempDir := t.TempDir()
+
+	data, err := os.ReadFile("testdata/jsonschema/inline_objects.json")
+	require.NoError(t, err)
+	var rawSchema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &rawSchema))
+
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{}))
+
+	expected := map[string][]string{
+		"Config":          {"server?: ConfigServer", "backends?: [ConfigBackends]"},
+		"ConfigServer":    {"schema ConfigServer:", "port: int = 8080", "tls?: ConfigServerTls"},
+		"ConfigServerTls": {"schema ConfigServerTls:", "enabled?: bool = False"},
+		"ConfigBackends":  {"schema ConfigBackends:", "url?: str"},
+	}
+	for name, fragments := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		for _, fragment := range fragments {
+			assert.Contains(t, string(content), fragment, "schema %s", name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// A component whose allOf lists a single reference to another object component extends it, and is
+// generated as "schema Child(Parent):" declaring only the properties it adds or refines. Every other
+// allOf member, and every member of a schema listing several references, is merged into the schema in
+// allOf order, followed by the schema's own properties. A property defined differently by several of
+// them is declared once with the definitions intersected, see allof.go.
+
+// processInheritance returns the name of the component a schema extends through allOf: the only
+// reference among its allOf members, if it refers to a component generated as a KCL schema
+func processInheritance(schema *openapi3.Schema, allSchemas openapi3.Schemas) string {
+	if schema == nil {
+		return ""
+	}
+
+	parent := ""
+	for _, member := range schema.AllOf {
+		if member == nil || member.Ref == "" {
+			continue
+		}
+		if parent != "" {
+			return ""
+		}
+		parent = extractSchemaName(member.Ref)
+	}
+
+	if component, ok := allSchemas[parent]; !ok || !isObjectComponent(component) {
+		return ""
+	}
+	return parent
+}
+
+// isObjectComponent reports whether a component is generated as a KCL schema that can be extended
+func isObjectComponent(schemaRef *openapi3.SchemaRef) bool {
+	if schemaRef == nil || schemaRef.Value == nil {
+		return false
+	}
+	schema := schemaRef.Value
+	if isUnionSchema(schema) || isEnumSchema(schema) || isOpenAPIMapSchema(schema, nil) {
+		return false
+	}
+	return len(schema.Properties) > 0 || schema.Type.Is("object") || isAllOfObjectSchema(schema)
+}
+
+// isAllOfObjectSchema reports whether a schema is an object composed through allOf.
+// A lone allOf reference, e.g. a $ref with sibling keywords, stands for the referenced schema instead.
+func isAllOfObjectSchema(schema *openapi3.Schema) bool {
+	if len(schema.AllOf) == 0 || (len(schema.AllOf) == 1 && schema.AllOf[0] != nil && schema.AllOf[0].Ref != "") {
+		return false
+	}
+	for _, member := range schema.AllOf {
+		if member != nil && member.Value != nil &&
+			(len(member.Value.Properties) > 0 || member.Value.Type.Is("object") || isAllOfObjectSchema(member.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeAllOf returns the properties and required property names of a schema with its allOf members merged,
+// leaving out the member referring to the component named parent. References to components are followed
+// through allSchemas, so the members of the referenced components are merged as well.
+func mergeAllOf(name string, schemaRef *openapi3.SchemaRef, parent string, allSchemas openapi3.Schemas) (openapi3.Schemas, []string, error) {
+	return mergeAllOfMembers(name, schemaRef, parent, allSchemas, make(map[string]bool))
+}
+
+// mergeAllOfMembers merges the allOf members of a schema, skipping components already being merged
+func mergeAllOfMembers(name string, schemaRef *openapi3.SchemaRef, parent string, allSchemas openapi3.Schemas, visiting map[string]bool) (openapi3.Schemas, []string, error) {
+	if schemaRef == nil || schemaRef.Value == nil {
+		return nil, nil, nil
+	}
+	schema := schemaRef.Value
+
+	properties := make(openapi3.Schemas)
+	origins := make(map[string]string)
+	var required []string
+	for i, member := range schema.AllOf {
+		if member == nil {
+			continue
+		}
+
+		origin := fmt.Sprintf("allOf member %d", i+1)
+		memberName := ""
+		if member.Ref != "" {
+			memberName = extractSchemaName(member.Ref)
+			if memberName == parent || visiting[memberName] {
+				continue
+			}
+			origin = formatSchemaName(memberName)
+			if component, ok := allSchemas[memberName]; ok {
+				member = component
+			}
+			visiting[memberName] = true
+		}
+
+		memberProperties, memberRequired, err := mergeAllOfMembers(name, member, "", allSchemas, visiting)
+		delete(visiting, memberName)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, propName := range collectSchemas(memberProperties) {
+			if err := mergeProperty(properties, origins, propName, memberProperties[propName], name, origin); err != nil {
+				return nil, nil, err
+			}
+		}
+		required = appendUnique(required, memberRequired...)
+	}
+
+	// The schema's own properties apply together with those of its members
+	for _, propName := range collectSchemas(schema.Properties) {
+		if err := mergeProperty(properties, origins, propName, schema.Properties[propName], name, formatSchemaName(name)); err != nil {
+			return nil, nil, err
+		}
+	}
+	required = appendUnique(required, schema.Required...)
+	return properties, required, nil
+}
+
+// mergeProperty adds the definition of a property by origin to properties. A property already defined
+// differently becomes the allOf of both definitions, which must not contradict each other.
+func mergeProperty(properties openapi3.Schemas, origins map[string]string, propName string, prop *openapi3.SchemaRef, name, origin string) error {
+	existing, ok := properties[propName]
+	if !ok || sameSchema(existing, prop) {
+		properties[propName] = prop
+		origins[propName] = origin
+		return nil
+	}
+
+	merged := &openapi3.SchemaRef{Value: &openapi3.Schema{AllOf: openapi3.SchemaRefs{existing, prop}}}
+	for _, definition := range []*openapi3.SchemaRef{prop, existing} {
+		if definition != nil && definition.Value != nil {
+			if merged.Value.Description == "" {
+				merged.Value.Description = definition.Value.Description
+			}
+			if merged.Value.Default == nil {
+				merged.Value.Default = definition.Value.Default
+			}
+		}
+	}
+	if _, err := mergeOpenAPIConstraints(merged.Value); err != nil {
+		return fmt.Errorf("property %s of %s is defined by %s and %s: %w", propName, name, origins[propName], origin, err)
+	}
+	properties[propName] = merged
+	origins[propName] = origins[propName] + " and " + origin
+	return nil
+}
+
+// sameSchema reports whether two property schemas are the same reference or have the same definition
+func sameSchema(a, b *openapi3.SchemaRef) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if a.Ref != "" || b.Ref != "" {
+		return a.Ref == b.Ref
+	}
+	aJSON, aErr := json.Marshal(a.Value)
+	bJSON, bErr := json.Marshal(b.Value)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+// appendUnique appends the values that are not in list yet, keeping their order
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if value != "" && !contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// singleAllOfRef returns the only reference among the allOf members of a schema and of its inline members,
+// or nil if there are several or none
+func singleAllOfRef(schema *openapi3.Schema) *openapi3.SchemaRef {
+	var ref *openapi3.SchemaRef
+	for _, member := range schema.AllOf {
+		if member == nil {
+			continue
+		}
+		memberRef := member
+		if member.Ref == "" {
+			if member.Value == nil {
+				continue
+			}
+			if memberRef = singleAllOfRef(member.Value); memberRef == nil {
+				continue
+			}
+		}
+		if ref != nil && ref.Ref != memberRef.Ref {
+			return nil
+		}
+		ref = memberRef
+	}
+	return ref
+}
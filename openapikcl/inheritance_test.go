@@ -0,0 +1,125 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateInheritance(t *testing.T) {
+	for _, flatten := range []bool{false, true} {
+		tempDir := t.TempDir()
+
+		doc, version, err := LoadOpenAPISchema("testdata/oas/input/inheritance.yaml", LoadOptions{
+			FlattenSpec: flatten,
+		})
+		require.NoError(t, err)
+		require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+		readSchema := func(name string) string {
+			content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+			require.NoError(t, err, "schema file %s should exist", name+".k")
+			return string(content)
+		}
+
+		// A single parent is extended, and only the added properties are declared
+		pet := readSchema("Pet")
+		assert.Contains(t, pet, "schema Pet(Resource):")
+		assert.Contains(t, pet, "name: str")
+		assert.Contains(t, pet, "home?: PetHome")
+		assert.NotContains(t, pet, "id:")
+		assert.NotContains(t, pet, "mixin")
+		assert.Contains(t, readSchema("PetHome"), "city?: str")
+		assert.Contains(t, readSchema("Dog"), "schema Dog(Pet):")
+
+		// Several parents are merged into the schema
+		tagged := readSchema("Tagged")
+		assert.Contains(t, tagged, "schema Tagged:")
+		assert.Contains(t, tagged, "name: str")
+		assert.Contains(t, tagged, "owner?: str")
+		assert.Contains(t, tagged, "tags?: [str]")
+		assert.Contains(t, tagged, "len(name) <= 64")
+	}
+}
+
+func TestFlattenKeepsAllOf(t *testing.T) {
+	doc, _, err := LoadOpenAPISchema("testdata/oas/input/inheritance.yaml", LoadOptions{FlattenSpec: true})
+	require.NoError(t, err)
+
+	pet := doc.Components.Schemas["Pet"].Value
+	require.Len(t, pet.AllOf, 2)
+	assert.Equal(t, "#/components/schemas/Resource", pet.AllOf[0].Ref)
+	assert.Equal(t, []string{"name"}, pet.AllOf[1].Value.Required)
+	assert.Empty(t, pet.Properties)
+}
+
+func TestMergeAllOf(t *testing.T) {
+	stringSchema := openapi3.NewStringSchema()
+	allSchemas := openapi3.Schemas{
+		"Named": openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+			WithProperty("name", stringSchema).
+			WithProperty("nickname", stringSchema)),
+		"Labelled": openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+			WithProperty("name", stringSchema)),
+		"Counted": openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+			WithProperty("name", openapi3.NewIntegerSchema())),
+	}
+	compose := func(members ...string) *openapi3.SchemaRef {
+		schema := &openapi3.Schema{}
+		for _, member := range members {
+			schema.AllOf = append(schema.AllOf, &openapi3.SchemaRef{Ref: "#/components/schemas/" + member, Value: allSchemas[member].Value})
+		}
+		return openapi3.NewSchemaRef("", schema)
+	}
+
+	// Members defining a property the same way merge
+	properties, _, err := mergeAllOf("Both", compose("Named", "Labelled"), "", allSchemas)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "nickname"}, collectSchemas(properties))
+
+	// The parent is left out
+	properties, _, err = mergeAllOf("Child", compose("Named", "Labelled"), "Named", allSchemas)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, collectSchemas(properties))
+
+	// Members defining a property differently merge into the allOf of both definitions
+	allSchemas["Short"] = openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+		WithProperty("name", openapi3.NewStringSchema().WithMaxLength(8)))
+	properties, _, err = mergeAllOf("Both", compose("Named", "Short"), "", allSchemas)
+	require.NoError(t, err)
+	require.Len(t, properties["name"].Value.AllOf, 2)
+	assert.Equal(t, []string{"len(name) <= 8"}, GenerateConstraints(properties["name"].Value, "name", false))
+
+	// Contradicting definitions are reported
+	_, _, err = mergeAllOf("Clash", compose("Named", "Counted"), "", allSchemas)
+	assert.EqualError(t, err, "property name of Clash is defined by Named and Counted: types string and integer have nothing in common")
+
+	schemas := openapi3.Schemas{"Clash": compose("Named", "Counted")}
+	for name, schema := range allSchemas {
+		schemas[name] = schema
+	}
+	_, err = GenerateKCLSchemaWithOptions("Clash", schemas["Clash"], schemas, OpenAPIV3, nil, GenerateOptions{})
+	assert.ErrorContains(t, err, "have nothing in common")
+}
+
+func TestInheritanceRequiresParentProperty(t *testing.T) {
+	parent := openapi3.NewObjectSchema().WithProperty("nickname", openapi3.NewStringSchema())
+	child := &openapi3.Schema{
+		AllOf:    openapi3.SchemaRefs{{Ref: "#/components/schemas/Named", Value: parent}},
+		Required: []string{"nickname"},
+	}
+	allSchemas := openapi3.Schemas{
+		"Named": openapi3.NewSchemaRef("", parent),
+		"Child": openapi3.NewSchemaRef("", child),
+	}
+
+	// An inherited property the child requires is declared again as required
+	content, err := GenerateKCLSchemaWithOptions("Child", allSchemas["Child"], allSchemas, OpenAPIV3, nil, GenerateOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, content, "schema Child(Named):")
+	assert.Contains(t, content, "nickname: str")
+}
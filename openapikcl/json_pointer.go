@@ -0,0 +1,290 @@
+package openapikcl
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// parseJSONPointer splits a JSON Pointer (RFC 6901) given as a URI fragment into its reference tokens.
+// The fragment is percent-decoded first, then ~1 and ~0 are unescaped in each token.
+func parseJSONPointer(fragment string) ([]string, error) {
+	pointer, err := url.PathUnescape(strings.TrimPrefix(fragment, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON pointer %q: %w", fragment, err)
+	}
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with /", fragment)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		unescaped, err := unescapePointerToken(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON pointer %q: %w", fragment, err)
+		}
+		tokens[i] = unescaped
+	}
+	return tokens, nil
+}
+
+// unescapePointerToken unescapes ~1 to / and ~0 to ~, rejecting any other use of ~
+func unescapePointerToken(token string) (string, error) {
+	if !strings.Contains(token, "~") {
+		return token, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			sb.WriteByte(token[i])
+			continue
+		}
+		if i+1 == len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return "", fmt.Errorf("invalid escape in token %q", token)
+		}
+		if token[i+1] == '0' {
+			sb.WriteByte('~')
+		} else {
+			sb.WriteByte('/')
+		}
+		i++
+	}
+	return sb.String(), nil
+}
+
+// escapePointerToken escapes a key for use as a JSON Pointer reference token
+func escapePointerToken(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// isComponentSchemaRef reports whether a local reference names a component schema, e.g. #/components/schemas/Pet
+func isComponentSchemaRef(ref string) bool {
+	tokens, err := parseJSONPointer(ref)
+	return err == nil && len(tokens) == 3 && tokens[0] == "components" && tokens[1] == "schemas"
+}
+
+// resolveRawPointer evaluates reference tokens against a decoded JSON or YAML document
+func resolveRawPointer(document interface{}, tokens []string) (interface{}, error) {
+	current := document
+	for i, token := range tokens {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found at /%s", token, strings.Join(tokens[:i], "/"))
+			}
+			current = next
+		case []interface{}:
+			index, err := pointerIndex(token, len(v))
+			if err != nil {
+				return nil, err
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("cannot resolve %q in a %T", token, current)
+		}
+	}
+	return current, nil
+}
+
+// pointerIndex parses a reference token used as an array index
+func pointerIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index >= length {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// resolveDocumentPointer evaluates a JSON Pointer against an OpenAPI document and returns the schema it points to.
+// Schemas can be reached through components, paths and other schemas, e.g.
+// #/components/parameters/limit/schema or #/components/schemas/Pet/properties/owner.
+func (f *Flattener) resolveDocumentPointer(doc *openapi3.T, fragment string) (*openapi3.SchemaRef, error) {
+	tokens, err := parseJSONPointer(fragment)
+	if err != nil {
+		return nil, err
+	}
+
+	var current interface{} = doc
+	for i, token := range tokens {
+		if current, err = f.pointerStep(current, token); err != nil {
+			return nil, fmt.Errorf("cannot resolve %s at /%s: %w", fragment, strings.Join(tokens[:i+1], "/"), err)
+		}
+	}
+
+	schema, ok := current.(*openapi3.SchemaRef)
+	if !ok || schema == nil {
+		return nil, fmt.Errorf("reference %s does not point to a schema", fragment)
+	}
+	return schema, nil
+}
+
+// pointerStep evaluates a single reference token against a node of an OpenAPI document
+func (f *Flattener) pointerStep(node interface{}, token string) (interface{}, error) {
+	var next interface{}
+	switch v := node.(type) {
+	case *openapi3.T:
+		switch token {
+		case "components":
+			next = v.Components
+		case "paths":
+			next = v.Paths
+		}
+	case *openapi3.Components:
+		switch token {
+		case "schemas":
+			next = v.Schemas
+		case "parameters":
+			next = v.Parameters
+		case "headers":
+			next = v.Headers
+		case "requestBodies":
+			next = v.RequestBodies
+		case "responses":
+			next = v.Responses
+		}
+	case openapi3.Schemas:
+		next = v[token]
+	case openapi3.ParametersMap:
+		next = v[token]
+	case openapi3.Headers:
+		next = v[token]
+	case openapi3.RequestBodies:
+		next = v[token]
+	case openapi3.ResponseBodies:
+		next = v[token]
+	case openapi3.Content:
+		next = v[token]
+	case openapi3.SchemaRefs:
+		index, err := pointerIndex(token, len(v))
+		if err != nil {
+			return nil, err
+		}
+		next = v[index]
+	case openapi3.Parameters:
+		index, err := pointerIndex(token, len(v))
+		if err != nil {
+			return nil, err
+		}
+		next = v[index]
+	case *openapi3.SchemaRef:
+		schema, err := f.schemaValue(v)
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case "properties":
+			next = schema.Properties
+		case "items":
+			next = schema.Items
+		case "additionalProperties":
+			next = schema.AdditionalProperties.Schema
+		case "not":
+			next = schema.Not
+		case "allOf":
+			next = schema.AllOf
+		case "oneOf":
+			next = schema.OneOf
+		case "anyOf":
+			next = schema.AnyOf
+		}
+	case *openapi3.ParameterRef:
+		if v.Value != nil {
+			next = parameterStep(v.Value, token)
+		}
+	case *openapi3.HeaderRef:
+		if v.Value != nil {
+			next = parameterStep(&v.Value.Parameter, token)
+		}
+	case *openapi3.RequestBodyRef:
+		if v.Value != nil && token == "content" {
+			next = v.Value.Content
+		}
+	case *openapi3.ResponseRef:
+		if v.Value != nil {
+			switch token {
+			case "content":
+				next = v.Value.Content
+			case "headers":
+				next = v.Value.Headers
+			}
+		}
+	case *openapi3.MediaType:
+		if token == "schema" {
+			next = v.Schema
+		}
+	case *openapi3.Paths:
+		next = v.Value(token)
+	case *openapi3.PathItem:
+		if token == "parameters" {
+			next = v.Parameters
+		} else {
+			next = v.GetOperation(strings.ToUpper(token))
+		}
+	case *openapi3.Operation:
+		switch token {
+		case "parameters":
+			next = v.Parameters
+		case "requestBody":
+			next = v.RequestBody
+		case "responses":
+			next = v.Responses
+		}
+	case *openapi3.Responses:
+		next = v.Value(token)
+	default:
+		return nil, fmt.Errorf("unsupported %T", node)
+	}
+
+	if next == nil || isNilPointer(next) {
+		return nil, fmt.Errorf("%q not found", token)
+	}
+	return next, nil
+}
+
+// parameterStep evaluates a reference token against a parameter or header
+func parameterStep(parameter *openapi3.Parameter, token string) interface{} {
+	switch token {
+	case "schema":
+		return parameter.Schema
+	case "content":
+		return parameter.Content
+	}
+	return nil
+}
+
+// schemaValue returns the schema a schema reference describes, resolving it if it was not loaded
+func (f *Flattener) schemaValue(schema *openapi3.SchemaRef) (*openapi3.Schema, error) {
+	if schema.Value == nil && schema.Ref != "" {
+		resolved, err := f.resolveReference(schema.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if resolved != nil {
+			return resolved.Value, nil
+		}
+	}
+	if schema.Value == nil {
+		return nil, fmt.Errorf("schema has no value")
+	}
+	return schema.Value, nil
+}
+
+// isNilPointer reports whether v holds a nil pointer, map or slice
+func isNilPointer(v interface{}) bool {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+package openapikcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONPointer(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "Whole Document", fragment: "#", expected: nil},
+		{name: "Simple", fragment: "#/components/schemas/Pet", expected: []string{"components", "schemas", "Pet"}},
+		{name: "Escaped Slash And Tilde", fragment: "#/paths/~1pets~1{id}/a~0b", expected: []string{"paths", "/pets/{id}", "a~b"}},
+		{name: "Escapes Are Applied Once", fragment: "#/a~01", expected: []string{"a~1"}},
+		{name: "Percent Encoded", fragment: "#/components/schemas/Pet%20Owner", expected: []string{"components", "schemas", "Pet Owner"}},
+		{name: "Empty Token", fragment: "#/a//b", expected: []string{"a", "", "b"}},
+		{name: "Missing Slash", fragment: "#components", wantErr: true},
+		{name: "Invalid Escape", fragment: "#/a~2", wantErr: true},
+		{name: "Invalid Percent Encoding", fragment: "#/a%zz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := parseJSONPointer(tt.fragment)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tokens)
+		})
+	}
+}
+
+func TestResolveRawPointer(t *testing.T) {
+	document := map[string]interface{}{
+		"$defs": map[string]interface{}{
+			"a/b": map[string]interface{}{"type": "string"},
+			"list": []interface{}{
+				map[string]interface{}{"type": "integer"},
+			},
+		},
+	}
+
+	value, err := resolveRawPointer(document, []string{"$defs", "a/b", "type"})
+	require.NoError(t, err)
+	assert.Equal(t, "string", value)
+
+	value, err = resolveRawPointer(document, []string{"$defs", "list", "0", "type"})
+	require.NoError(t, err)
+	assert.Equal(t, "integer", value)
+
+	_, err = resolveRawPointer(document, []string{"$defs", "list", "01"})
+	assert.Error(t, err)
+	_, err = resolveRawPointer(document, []string{"$defs", "missing"})
+	assert.Error(t, err)
+}
+
+const pointerSpec = `
+openapi: 3.0.3
+info:
+  title: Pointers
+  version: 1.0.0
+paths:
+  /pets/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: A pet
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name:
+                    type: string
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        owner:
+          type: object
+          properties:
+            name:
+              type: string
+        tags:
+          type: array
+          items:
+            type: string
+    a/b~c:
+      type: boolean
+    Pet Owner:
+      type: string
+  parameters:
+    limit:
+      name: limit
+      in: query
+      schema:
+        type: integer
+        maximum: 100
+  headers:
+    RateLimit:
+      schema:
+        type: integer
+  requestBodies:
+    NewPet:
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              name:
+                type: string
+  responses:
+    Error:
+      description: An error
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              message:
+                type: string
+`
+
+func TestResolveLocalRefPointers(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(pointerSpec))
+	require.NoError(t, err)
+	f := NewFlattener(FlattenOptions{}, doc)
+
+	tests := []struct {
+		name  string
+		ref   string
+		check func(t *testing.T, schema *openapi3.Schema)
+	}{
+		{
+			name: "Component Schema",
+			ref:  "#/components/schemas/Pet",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.Contains(t, schema.Properties, "owner")
+			},
+		},
+		{
+			name: "Nested Property",
+			ref:  "#/components/schemas/Pet/properties/owner",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.Contains(t, schema.Properties, "name")
+			},
+		},
+		{
+			name: "Array Items",
+			ref:  "#/components/schemas/Pet/properties/tags/items",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("string"))
+			},
+		},
+		{
+			name: "Escaped Name",
+			ref:  "#/components/schemas/a~1b~0c",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("boolean"))
+			},
+		},
+		{
+			name: "Percent Encoded Name",
+			ref:  "#/components/schemas/Pet%20Owner",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("string"))
+			},
+		},
+		{
+			name: "Parameter Schema",
+			ref:  "#/components/parameters/limit/schema",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				require.NotNil(t, schema.Max)
+				assert.Equal(t, 100.0, *schema.Max)
+			},
+		},
+		{
+			name: "Header Schema",
+			ref:  "#/components/headers/RateLimit/schema",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("integer"))
+			},
+		},
+		{
+			name: "Request Body Schema",
+			ref:  "#/components/requestBodies/NewPet/content/application~1json/schema",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.Contains(t, schema.Properties, "name")
+			},
+		},
+		{
+			name: "Response Schema",
+			ref:  "#/components/responses/Error/content/application~1json/schema/properties/message",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("string"))
+			},
+		},
+		{
+			name: "Operation Parameter",
+			ref:  "#/paths/~1pets~1{id}/get/parameters/0/schema",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.True(t, schema.Type.Is("integer"))
+			},
+		},
+		{
+			name: "Operation Response",
+			ref:  "#/paths/~1pets~1{id}/get/responses/200/content/application~1json/schema",
+			check: func(t *testing.T, schema *openapi3.Schema) {
+				assert.Contains(t, schema.Properties, "name")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := f.resolveLocalRef(tt.ref)
+			require.NoError(t, err)
+			require.NotNil(t, schema)
+			require.NotNil(t, schema.Value)
+			tt.check(t, schema.Value)
+		})
+	}
+
+	for _, ref := range []string{
+		"#/components/schemas/Missing",
+		"#/components/parameters/limit",
+		"#/components/securitySchemes/key",
+		"#/components/schemas/Pet/properties/tags/items/properties",
+	} {
+		_, err := f.resolveLocalRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestFlattenNestedPointerRefs(t *testing.T) {
+	spec := `
+openapi: 3.0.3
+info:
+  title: Nested pointers
+  version: 1.0.0
+paths: {}
+components:
+  parameters:
+    limit:
+      name: limit
+      in: query
+      schema:
+        type: integer
+        maximum: 100
+  schemas:
+    Pet:
+      type: object
+      properties:
+        owner:
+          type: object
+          properties:
+            name:
+              type: string
+    Shop:
+      type: object
+      properties:
+        keeper:
+          $ref: '#/components/schemas/Pet/properties/owner'
+        limit:
+          $ref: '#/components/parameters/limit/schema'
+`
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	require.NoError(t, err)
+	flatDoc, err := NewFlattener(FlattenOptions{}, doc).FlattenSpec()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	require.NoError(t, GenerateKCLSchemas(flatDoc, tempDir, "test", OpenAPIV3, nil))
+	content, err := os.ReadFile(filepath.Join(tempDir, "Shop.k"))
+	require.NoError(t, err)
+
+	// Pointers that do not name a component schema are inlined rather than referenced by their last token
+	assert.Contains(t, string(content), "keeper?: ShopKeeper")
+	assert.Contains(t, string(content), "limit?: int")
+	assert.Contains(t, string(content), "limit <= 100 if limit != None")
+}
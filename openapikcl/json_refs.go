@@ -0,0 +1,153 @@
+package openapikcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// External JSON Schema references are resolved by the compiler against the location of the document
+// containing them. Files, catalog mirrors and URLs are read through a Flattener, so they share its
+// HTTP client, catalog and cache. Every external schema a reference points to is generated as its own KCL schema.
+
+// jsonSchemaLoader loads the external documents JSON Schema references point to
+type jsonSchemaLoader struct {
+	flattener *Flattener
+	documents map[string]interface{} // decoded documents keyed by URL
+}
+
+// newJSONSchemaLoader creates a loader fetching remote documents with the given options
+func newJSONSchemaLoader(opts FlattenOptions) *jsonSchemaLoader {
+	return &jsonSchemaLoader{
+		flattener: NewFlattener(opts, nil),
+		documents: make(map[string]interface{}),
+	}
+}
+
+// jsonSchemaResourceID returns the URL the input schema is compiled under,
+// so relative references resolve against its location
+func jsonSchemaResourceID(sourcePath string) string {
+	if sourcePath == "" {
+		return "root-schema"
+	}
+	if isURLRef(sourcePath) {
+		return sourcePath
+	}
+	absPath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return "root-schema"
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String()
+}
+
+// load reads the JSON or YAML document at an absolute URL and returns it as JSON for the compiler
+func (l *jsonSchemaLoader) load(location string) (io.ReadCloser, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %s: %w", location, err)
+	}
+
+	// Files, catalog mirrors and URLs are read through the flattener
+	log.Printf("loading referenced document %s", location)
+	data, err := l.flattener.readDocument(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reference %s: %w", location, err)
+	}
+
+	// YAML is a superset of JSON, so both parse as YAML
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse reference %s: not a valid JSON or YAML document: %w", location, err)
+	}
+	schemaBytes, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert reference %s to JSON: %w", location, err)
+	}
+
+	l.documents[location] = doc
+	return io.NopCloser(bytes.NewReader(schemaBytes)), nil
+}
+
+// rawSchema returns the raw schema at a location of a loaded document, or nil if it is not known
+func (l *jsonSchemaLoader) rawSchema(location string) map[string]interface{} {
+	document, fragment, _ := strings.Cut(location, "#")
+	tokens, err := parseJSONPointer(fragment)
+	if err != nil {
+		return nil
+	}
+	current, err := resolveRawPointer(l.documents[document], tokens)
+	if err != nil {
+		return nil
+	}
+	raw, _ := current.(map[string]interface{})
+	return raw
+}
+
+// collectExternalSchemas names the schemas of other documents that the given schemas refer to,
+// directly or through each other. They are returned sorted by location.
+func collectExternalSchemas(ctx *jsonSchemaContext, loader *jsonSchemaLoader, rootDocument string, schemas []*jsonschema.Schema) []jsonDefinition {
+	visited := make(map[*jsonschema.Schema]bool)
+	for _, schema := range schemas {
+		walkJSONSchema(schema, visited)
+	}
+
+	var targets []*jsonschema.Schema
+	isTarget := make(map[*jsonschema.Schema]bool)
+	for schema := range visited {
+		target := schema.Ref
+		if target == nil || isTarget[target] || ctx.names[target] != "" || jsonSchemaDocument(target.Location) == rootDocument {
+			continue
+		}
+		isTarget[target] = true
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Location < targets[j].Location })
+
+	var external []jsonDefinition
+	for _, target := range targets {
+		raw := loader.rawSchema(target.Location)
+		name := ctx.claimName(externalSchemaName(target, raw))
+		ctx.names[target] = name
+		if defaultVal, ok := raw["default"]; ok {
+			ctx.defaults[target] = defaultVal
+		}
+		external = append(external, jsonDefinition{key: target.Location, name: name, schema: target, raw: raw})
+		log.Printf("found external schema %s as %s", target.Location, name)
+	}
+	return external
+}
+
+// externalSchemaName names an external schema after the last token of its location,
+// or after its title or file name when it is a whole document
+func externalSchemaName(schema *jsonschema.Schema, raw map[string]interface{}) string {
+	document, fragment, _ := strings.Cut(schema.Location, "#")
+	if tokens, err := parseJSONPointer(fragment); err == nil && len(tokens) > 0 {
+		if name := pascalCaseName(tokens[len(tokens)-1]); name != "" {
+			return name
+		}
+	}
+	if title, ok := raw["title"].(string); ok && pascalCaseName(title) != "" {
+		return pascalCaseName(title)
+	}
+	base := path.Base(document)
+	if name := pascalCaseName(strings.TrimSuffix(base, path.Ext(base))); name != "" {
+		return name
+	}
+	return "External"
+}
+
+// jsonSchemaDocument returns the document part of a schema location
+func jsonSchemaDocument(location string) string {
+	document, _, _ := strings.Cut(location, "#")
+	return document
+}
@@ -0,0 +1,79 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateJSONExternalRefs(t *testing.T) {
+	inputPath := "testdata/json/external/input.json"
+	data, err := os.ReadFile(inputPath)
+	require.NoError(t, err)
+	var rawSchema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &rawSchema))
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{SourcePath: inputPath}))
+
+	readSchema := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		return string(content)
+	}
+
+	owner := readSchema("Owner")
+	assert.Contains(t, owner, "address?: Address")
+	assert.Contains(t, owner, "pets?: [Pet]")
+
+	// Refs inside external documents resolve against the document containing them
+	address := readSchema("Address")
+	assert.Contains(t, address, `country?: CountryCode = "SE"`)
+	assert.Contains(t, address, `regex.match(country, r"^[A-Z]{2}$") if country != None`)
+	assert.Contains(t, readSchema("CountryCode"), "type CountryCode = str")
+
+	// YAML documents are referenced as a whole and named after their title
+	assert.Contains(t, readSchema("Pet"), "id: Id")
+	assert.Contains(t, readSchema("Id"), "type Id = int")
+}
+
+func TestGenerateJSONRemoteRefs(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"$defs": {"tag": {"type": "object", "properties": {"label": {"type": "string"}}}}}`)
+	}))
+	defer server.Close()
+
+	rawSchema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "Item",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"primary":   map[string]interface{}{"$ref": server.URL + "/common.json#/$defs/tag"},
+			"secondary": map[string]interface{}{"$ref": server.URL + "/common.json#/$defs/tag"},
+		},
+	}
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{}))
+	assert.Equal(t, 1, requests, "the remote document should be fetched once")
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "Item.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "primary?: Tag")
+	assert.Contains(t, string(content), "secondary?: Tag")
+	assert.FileExists(t, filepath.Join(tempDir, "Tag.k"))
+
+	// Skipping remote references leaves them unresolvable
+	opts := GenerateOptions{Flatten: FlattenOptions{SkipRemote: true}}
+	err = generateJSONSchemas(rawSchema, t.TempDir(), "test", opts)
+	assert.ErrorContains(t, err, "remote reference skipped")
+}
@@ -20,8 +20,8 @@ func TestJSONSchemaComposition(t *testing.T) {
 			expectedOutputs: []string{
 				"schema Pet:",
 				"age?: int",
-				"breed?: str",
-				"name?: str",
+				"breed: str",
+				"name: str",
 			},
 		},
 		{
@@ -29,9 +29,12 @@ func TestJSONSchemaComposition(t *testing.T) {
 			jsonSchemaFile: "testdata/jsonschema/oneof.json",
 			expectedOutputs: []string{
 				"schema Payment:",
-				"type_value: str",
+				"type_value: \"credit_card\" | \"bank_transfer\"",
+				"cardNumber?: str",
+				"accountNumber?: str",
 				"check:",
-				"type_value in [\"credit_card\", \"bank_transfer\"]",
+				`len([_m for _m in [(cardNumber != None and typeof(cardNumber) == "str" and type_value in ["credit_card"]), ` +
+					`(accountNumber != None and typeof(accountNumber) == "str" and type_value in ["bank_transfer"])] if _m]) == 1`,
 			},
 		},
 		{
@@ -39,6 +42,19 @@ func TestJSONSchemaComposition(t *testing.T) {
 			jsonSchemaFile: "testdata/jsonschema/anyof.json",
 			expectedOutputs: []string{
 				"schema Identifier:",
+				"name?: str",
+				"id?: int",
+				"check:",
+				`((name != None and typeof(name) == "str") or (id != None and typeof(id) == "int"))`,
+			},
+		},
+		{
+			name:           "Test anyOf Property",
+			jsonSchemaFile: "testdata/jsonschema/anyof_property.json",
+			expectedOutputs: []string{
+				"schema Contact:",
+				`((typeof(handle) == "str" and regex.match(handle, `,
+				`or (typeof(handle) == "str" and len(handle) >= 3 and len(handle) <= 16)) if handle != None, "handle must match at least one anyOf schema"`,
 			},
 		},
 		{
@@ -48,11 +64,11 @@ func TestJSONSchemaComposition(t *testing.T) {
 				"schema ComplexSchema:",
 				"id: str",
 				"user?: any",
-				"# oneOf validation using discriminator: type",
-				"if user.type == individual:",
-				"if user.type == organization:",
-				"# Conditional validation: if region exists",
-				"if user.user has region:",
+				`"firstName" in user and "lastName" in user`,
+				`user["type"] in ["individual"]`,
+				`user["type"] in ["organization"]`,
+				`("region" not in user or typeof(user["region"]) == "str")`,
+				`if user != None, "user must match exactly one oneOf schema"`,
 			},
 		},
 		{
@@ -64,7 +80,44 @@ func TestJSONSchemaComposition(t *testing.T) {
 				"stateCode?: str",
 				"zipCode?: str",
 				"check:",
-				"if country == \"US\":",
+				`zipCode != None if country in ["US"]`,
+				`(stateCode == None or regex.match(stateCode, r"^[A-Z]{2}$")) if country in ["US"]`,
+				`(zipCode == None or regex.match(zipCode, r"^[A-Za-z0-9\s-]{3,10}$")) if not (country in ["US"])`,
+			},
+		},
+		{
+			name:           "Test Conditions At Root And Per Property",
+			jsonSchemaFile: "testdata/jsonschema/conditions.json",
+			expectedOutputs: []string{
+				"schema Account:",
+				"seats % 5 == 0 if seats != None and seats >= 10",
+				"seats <= 9 if seats != None and not (seats >= 10)",
+				`(seats == None or seats >= 2) if (plan != None and (typeof(plan) == "str" and regex.match(plan, r"^pro")))`,
+				`plan != None if kind in ["business"]`,
+				`seats != None if kind in ["business"]`,
+				`(seats == None or seats <= 1) if not (kind in ["business"])`,
+			},
+		},
+		{
+			name:           "Test Property Dependencies",
+			jsonSchemaFile: "testdata/jsonschema/dependencies.json",
+			expectedOutputs: []string{
+				"schema Listener:",
+				`"proxyPort" in options if options != None and typeof(options) == "dict" and "proxy" in options`,
+				"(cert == None or len(cert) >= 1)",
+				"port >= 1024 if protocol_value != None",
+				"cert != None if tls != None",
+				"key != None if tls != None",
+			},
+		},
+		{
+			name:           "Test Draft-04 Dependencies",
+			jsonSchemaFile: "testdata/jsonschema/dependencies_draft04.json",
+			expectedOutputs: []string{
+				"schema Card:",
+				"billingAddress != None if number != None",
+				"name != None if billingAddress != None",
+				"(name == None or len(name) >= 2) if billingAddress != None",
 			},
 		},
 	}
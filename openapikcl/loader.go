@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,13 +12,20 @@ import (
 	"github.com/getkin/kin-openapi/openapi2"
 	"github.com/getkin/kin-openapi/openapi2conv"
 	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
 )
 
 // LoadOptions configures the loading process
 type LoadOptions struct {
-	FlattenSpec bool
-	SkipRemote  bool
-	MaxDepth    int
+	FlattenSpec      bool
+	SkipRemote       bool
+	MaxDepth         int
+	Mode             BundleMode // How the flattened document refers to schemas; empty prepares it for generation
+	Catalog          *Catalog   // Local mirrors of remote documents
+	Offline          bool       // Fail on remote references that are not in the catalog
+	Fetcher          Fetcher    // Fetches remote documents; nil uses an HTTPFetcher with its defaults
+	RootDir          string     // Directory file references must stay inside; empty means the directory of the input
+	AllowOutsideRoot bool       // Allow file references outside RootDir, for trusted inputs
 }
 
 // LoadOpenAPISchema is now version-aware
@@ -57,18 +65,113 @@ func LoadOpenAPISchema(filePath string, opts LoadOptions) (*openapi3.T, OpenAPIV
 	}
 }
 
+// skipsRemoteRefs reports whether remote references without a mirror are skipped when loading.
+// Offline mode fails on them instead.
+func skipsRemoteRefs(opts LoadOptions) bool {
+	return opts.SkipRemote && !opts.Offline
+}
+
+// newOpenAPILoader creates a loader that follows references to other files and URLs.
+// Referenced documents are read through the returned flattener, so each is read only once.
+func newOpenAPILoader(filePath string, opts LoadOptions) (*openapi3.Loader, *Flattener) {
+	flattener := NewFlattener(FlattenOptions{
+		BaseDir:          filepath.Dir(filePath),
+		MaxDepth:         opts.MaxDepth,
+		SkipRemote:       opts.SkipRemote,
+		Mode:             opts.Mode,
+		Catalog:          opts.Catalog,
+		Offline:          opts.Offline,
+		Fetcher:          opts.Fetcher,
+		RootDir:          opts.RootDir,
+		AllowOutsideRoot: opts.AllowOutsideRoot,
+	}, nil)
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		data, err := flattener.readDocument(location)
+		if err != nil || !skipsRemoteRefs(opts) {
+			return data, err
+		}
+		return skipRemoteRefs(data, opts.Catalog)
+	}
+	return loader, flattener
+}
+
+// skippedRefExtension names the remote reference a skipped schema stands in for
+const skippedRefExtension = "x-skipped-ref"
+
+// skipRemoteRefs replaces references to remote documents that have no mirror in the catalog with
+// schemas accepting any value, so loading does not fetch them. It returns data unchanged if it has none.
+func skipRemoteRefs(data []byte, catalog *Catalog) ([]byte, error) {
+	// Try JSON first, then fall back to YAML
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("error parsing document: %w", err)
+		}
+	}
+
+	skipped := false
+	var walk func(node interface{}) interface{}
+	walk = func(node interface{}) interface{} {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && isURLRef(ref) {
+				if _, mirrored := catalog.Lookup(ref); !mirrored {
+					log.Printf("skipping remote reference: %s", ref)
+					skipped = true
+					return map[string]interface{}{skippedRefExtension: ref}
+				}
+			}
+			for key, value := range v {
+				v[key] = walk(value)
+			}
+		case []interface{}:
+			for i, value := range v {
+				v[i] = walk(value)
+			}
+		}
+		return node
+	}
+	doc = walk(doc)
+
+	if !skipped {
+		return data, nil
+	}
+	return json.Marshal(doc)
+}
+
+// documentLocation returns the location references in the document at filePath resolve against
+func documentLocation(filePath string) *url.URL {
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+	return &url.URL{Path: filepath.ToSlash(filePath)}
+}
+
 // Separate functions for each version
 func loadOpenAPIV3Schema(data []byte, filePath string, opts LoadOptions) (*openapi3.T, OpenAPIVersion, error) {
 	log.Print("parsing OpenAPI schema")
-	loader := openapi3.NewLoader()
-	doc, err := loader.LoadFromData(data)
+	if skipsRemoteRefs(opts) {
+		var err error
+		if data, err = skipRemoteRefs(data, opts.Catalog); err != nil {
+			return nil, OpenAPIV3, err
+		}
+	}
+
+	loader, flattener := newOpenAPILoader(filePath, opts)
+	doc, err := loader.LoadFromDataWithPath(data, documentLocation(filePath))
 	if err != nil {
 		log.Printf("error parsing schema: %v", err)
 		return nil, OpenAPIV3, err
 	}
 
+	// patternProperties and property dependencies are not part of OpenAPI 3.0 but are generated as typed maps
+	// and checks, so they are allowed
 	log.Print("validating OpenAPI document")
-	if err := doc.Validate(loader.Context); err != nil {
+	if err := doc.Validate(loader.Context, openapi3.AllowExtraSiblingFields("patternProperties",
+		"dependentRequired", "dependentSchemas", "dependencies")); err != nil {
 		log.Printf("schema validation failed: %v", err)
 		return nil, OpenAPIV3, err
 	}
@@ -76,11 +179,7 @@ func loadOpenAPIV3Schema(data []byte, filePath string, opts LoadOptions) (*opena
 	// Flatten the specification if requested
 	if opts.FlattenSpec {
 		log.Print("flattening OpenAPI specification")
-		flattener := NewFlattener(FlattenOptions{
-			BaseDir:    filepath.Dir(filePath),
-			MaxDepth:   opts.MaxDepth,
-			SkipRemote: opts.SkipRemote,
-		}, doc)
+		flattener.doc = doc
 
 		flatDoc, err := flattener.FlattenSpec()
 		if err != nil {
@@ -125,9 +224,15 @@ func loadOpenAPIV2Schema(data []byte, filePath string, opts LoadOptions) (*opena
 	if opts.FlattenSpec {
 		log.Print("starting specification flattening process")
 		flattener := NewFlattener(FlattenOptions{
-			BaseDir:    filepath.Dir(filePath),
-			MaxDepth:   opts.MaxDepth,
-			SkipRemote: opts.SkipRemote,
+			BaseDir:          filepath.Dir(filePath),
+			MaxDepth:         opts.MaxDepth,
+			SkipRemote:       opts.SkipRemote,
+			Mode:             opts.Mode,
+			Catalog:          opts.Catalog,
+			Offline:          opts.Offline,
+			Fetcher:          opts.Fetcher,
+			RootDir:          opts.RootDir,
+			AllowOutsideRoot: opts.AllowOutsideRoot,
 		}, doc)
 
 		flatDoc, err := flattener.FlattenSpec()
@@ -141,6 +246,67 @@ func loadOpenAPIV2Schema(data []byte, filePath string, opts LoadOptions) (*opena
 }
 
 func loadOpenAPIV31Schema(data []byte, filePath string, opts LoadOptions) (*openapi3.T, OpenAPIVersion, error) {
-	// Placeholder for future OpenAPI 3.1 support
-	return nil, OpenAPIV31, fmt.Errorf("OpenAPI 3.1 support not yet implemented")
+	log.Print("parsing OpenAPI 3.1 schema")
+
+	// Parse into a generic document, converting YAML through JSON so numbers and maps have uniform types
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var yamlDoc interface{}
+		if yamlErr := yaml.Unmarshal(data, &yamlDoc); yamlErr != nil {
+			return nil, OpenAPIV31, fmt.Errorf("error parsing OpenAPI 3.1 document: %w", yamlErr)
+		}
+		jsonData, err := json.Marshal(yamlDoc)
+		if err != nil {
+			return nil, OpenAPIV31, fmt.Errorf("error converting OpenAPI 3.1 document to JSON: %w", err)
+		}
+		if err := json.Unmarshal(jsonData, &raw); err != nil {
+			return nil, OpenAPIV31, fmt.Errorf("error parsing OpenAPI 3.1 document: %w", err)
+		}
+	}
+
+	// Rewrite the JSON Schema 2020-12 constructs into the OpenAPI 3.0 shape
+	normalized, err := normalizeOpenAPI31Document(raw)
+	if err != nil {
+		return nil, OpenAPIV31, fmt.Errorf("error normalizing OpenAPI 3.1 document: %w", err)
+	}
+	normalizedData, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, OpenAPIV31, fmt.Errorf("error marshaling normalized OpenAPI 3.1 document: %w", err)
+	}
+
+	if skipsRemoteRefs(opts) {
+		if normalizedData, err = skipRemoteRefs(normalizedData, opts.Catalog); err != nil {
+			return nil, OpenAPIV31, err
+		}
+	}
+
+	loader, flattener := newOpenAPILoader(filePath, opts)
+	doc, err := loader.LoadFromDataWithPath(normalizedData, documentLocation(filePath))
+	if err != nil {
+		log.Printf("error parsing schema: %v", err)
+		return nil, OpenAPIV31, err
+	}
+
+	if err := resolveOpenAPI31Webhooks(loader, doc); err != nil {
+		return nil, OpenAPIV31, err
+	}
+
+	// Skip validation for OpenAPI 3.1 documents
+	// The OpenAPI 3.0 validator rejects valid 3.1 documents, e.g. those without paths
+	log.Print("skipping validation for normalized OpenAPI 3.1 document")
+
+	// Handle flattening if needed
+	if opts.FlattenSpec {
+		log.Print("flattening OpenAPI specification")
+		flattener.doc = doc
+
+		flatDoc, err := flattener.FlattenSpec()
+		if err != nil {
+			return nil, OpenAPIV31, fmt.Errorf("error flattening specification: %w", err)
+		}
+		doc = flatDoc
+	}
+
+	log.Print("successfully loaded OpenAPI 3.1 schema")
+	return doc, OpenAPIV31, nil
 }
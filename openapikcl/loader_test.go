@@ -1,9 +1,13 @@
 package openapikcl
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoadOpenAPISchema(t *testing.T) {
@@ -28,6 +32,13 @@ func TestLoadOpenAPISchema(t *testing.T) {
 			shouldError:         false,
 			expectedSchemaCount: 3, // Pet, PetInput, ErrorModel
 		},
+		{
+			name:                "Load OpenAPI 3.1",
+			filename:            "testdata/oas/input/petstore_v31.json",
+			expectedVersion:     OpenAPIV31,
+			shouldError:         false,
+			expectedSchemaCount: 2, // Pet, Owner
+		},
 	}
 
 	for _, tc := range tests {
@@ -47,7 +58,8 @@ func TestLoadOpenAPISchema(t *testing.T) {
 			assert.NotNil(t, doc)
 
 			// For OpenAPI 2.0, schemas are converted from definitions
-			if version == OpenAPIV2 {
+			// For OpenAPI 3.1, $defs are hoisted into the components
+			if version == OpenAPIV2 || version == OpenAPIV31 {
 				assert.NotNil(t, doc.Components)
 				assert.NotNil(t, doc.Components.Schemas)
 				assert.Equal(t, tc.expectedSchemaCount, len(doc.Components.Schemas))
@@ -78,6 +90,13 @@ func TestLoadOpenAPISchemaWithFlattening(t *testing.T) {
 			shouldError:         false,
 			expectedSchemaCount: 3, // Pet, PetInput, ErrorModel
 		},
+		{
+			name:                "Flatten OpenAPI 3.1",
+			filename:            "testdata/oas/input/petstore_v31.json",
+			expectedVersion:     OpenAPIV31,
+			shouldError:         false,
+			expectedSchemaCount: 2, // Pet, Owner
+		},
 	}
 
 	for _, tc := range tests {
@@ -106,3 +125,231 @@ func TestLoadOpenAPISchemaWithFlattening(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadOpenAPI31Schema(t *testing.T) {
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/petstore_v31.json", LoadOptions{
+		FlattenSpec: false,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, OpenAPIV31, version)
+
+	pet := doc.Components.Schemas["Pet"]
+	require.NotNil(t, pet)
+	require.NotNil(t, pet.Value)
+
+	// Type arrays with null become nullable
+	tag := pet.Value.Properties["tag"].Value
+	assert.True(t, tag.Type.Is("string"))
+	assert.True(t, tag.Nullable)
+
+	// const becomes a single value enum
+	assert.Equal(t, []interface{}{"pet"}, pet.Value.Properties["kind"].Value.Enum)
+
+	// Numeric exclusiveMinimum becomes the boolean form
+	id := pet.Value.Properties["id"].Value
+	require.NotNil(t, id.Min)
+	assert.Equal(t, float64(0), *id.Min)
+	assert.True(t, id.ExclusiveMin)
+
+	// $ref with only annotation siblings points at the hoisted $defs entry
+	assert.Equal(t, "#/components/schemas/Owner", pet.Value.Properties["owner"].Ref)
+	require.NotNil(t, doc.Components.Schemas["Owner"])
+
+	// prefixItems become an anyOf item schema bounded by the tuple length, and are kept for positional checks
+	location := pet.Value.Properties["location"].Value
+	require.NotNil(t, location.Items)
+	assert.Len(t, location.Items.Value.AnyOf, 2)
+	assert.Len(t, location.Extensions["prefixItems"], 2)
+	require.NotNil(t, location.MaxItems)
+	assert.Equal(t, uint64(2), *location.MaxItems)
+	assert.Equal(t, uint64(2), location.MinItems)
+
+	// Webhooks are resolved against the components
+	webhooks := Webhooks(doc)
+	require.Contains(t, webhooks, "newPet")
+	body := webhooks["newPet"].Post.RequestBody.Value.Content["application/json"].Schema
+	require.NotNil(t, body.Value.Properties["pet"].Value)
+	assert.Contains(t, body.Value.Properties["pet"].Value.Properties, "name")
+}
+
+func TestNormalizeOpenAPI31Document(t *testing.T) {
+	t.Run("ref with constraining siblings becomes allOf", func(t *testing.T) {
+		doc, err := normalizeOpenAPI31Document(map[string]interface{}{
+			"components": map[string]interface{}{
+				"schemas": map[string]interface{}{
+					"Name": map[string]interface{}{"type": "string"},
+					"Short": map[string]interface{}{
+						"$ref":      "#/components/schemas/Name",
+						"maxLength": float64(5),
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		short := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})["Short"].(map[string]interface{})
+		assert.NotContains(t, short, "$ref")
+		assert.Equal(t, float64(5), short["maxLength"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"$ref": "#/components/schemas/Name"}}, short["allOf"])
+	})
+
+	t.Run("numeric exclusive bounds keep the stricter bound", func(t *testing.T) {
+		doc, err := normalizeOpenAPI31Document(map[string]interface{}{
+			"components": map[string]interface{}{
+				"schemas": map[string]interface{}{
+					"Inclusive": map[string]interface{}{"type": "integer", "minimum": float64(10), "exclusiveMinimum": float64(5)},
+					"Exclusive": map[string]interface{}{"type": "integer", "maximum": float64(10), "exclusiveMaximum": float64(5)},
+					"Equal":     map[string]interface{}{"type": "integer", "minimum": float64(5), "exclusiveMinimum": float64(5)},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		inclusive := schemas["Inclusive"].(map[string]interface{})
+		assert.Equal(t, float64(10), inclusive["minimum"])
+		assert.NotContains(t, inclusive, "exclusiveMinimum")
+		exclusive := schemas["Exclusive"].(map[string]interface{})
+		assert.Equal(t, float64(5), exclusive["maximum"])
+		assert.Equal(t, true, exclusive["exclusiveMaximum"])
+		equal := schemas["Equal"].(map[string]interface{})
+		assert.Equal(t, float64(5), equal["minimum"])
+		assert.Equal(t, true, equal["exclusiveMinimum"])
+	})
+
+	t.Run("multiple non-null types are kept", func(t *testing.T) {
+		doc, err := normalizeOpenAPI31Document(map[string]interface{}{
+			"components": map[string]interface{}{
+				"schemas": map[string]interface{}{
+					"Value": map[string]interface{}{"type": []interface{}{"string", "integer", "null"}},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		value := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})["Value"].(map[string]interface{})
+		assert.Equal(t, []interface{}{"string", "integer"}, value["type"])
+		assert.Equal(t, true, value["nullable"])
+	})
+
+	t.Run("conflicting $defs names are prefixed with the parent", func(t *testing.T) {
+		doc, err := normalizeOpenAPI31Document(map[string]interface{}{
+			"components": map[string]interface{}{
+				"schemas": map[string]interface{}{
+					"Item": map[string]interface{}{"type": "string"},
+					"Order": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"item": map[string]interface{}{"$ref": "#/components/schemas/Order/$defs/Item"},
+						},
+						"$defs": map[string]interface{}{
+							"Item": map[string]interface{}{"type": "object"},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		assert.Contains(t, schemas, "OrderItem")
+		item := schemas["Order"].(map[string]interface{})["properties"].(map[string]interface{})["item"].(map[string]interface{})
+		assert.Equal(t, "#/components/schemas/OrderItem", item["$ref"])
+	})
+}
+
+func TestLoadOpenAPISchemaWithExternalRefs(t *testing.T) {
+	// models/pet.yaml refers to ../common/id.yaml, which only resolves against its own location
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/split/openapi.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, OpenAPIV3, version)
+	require.Len(t, doc.Components.Schemas, 2)
+
+	pet := doc.Components.Schemas["Pet"]
+	require.NotNil(t, pet)
+	assert.Empty(t, pet.Ref, "a component consisting of an external schema takes it as its own")
+	require.NotNil(t, pet.Value)
+	assert.Equal(t, []string{"id"}, pet.Value.Required)
+
+	id := pet.Value.Properties["id"]
+	require.NotNil(t, id)
+	assert.Empty(t, id.Ref)
+	require.NotNil(t, id.Value.Min)
+	assert.Equal(t, 1.0, *id.Value.Min)
+
+	// Local refs of the external document point into it, not into the root document
+	tag := pet.Value.Properties["tag"]
+	require.NotNil(t, tag)
+	assert.Empty(t, tag.Ref)
+	assert.Contains(t, tag.Value.Properties, "label")
+
+	owner := doc.Components.Schemas["Owner"]
+	require.NotNil(t, owner)
+	assert.Equal(t, "#/components/schemas/Pet", owner.Value.Properties["pet"].Ref)
+	assert.Empty(t, owner.Value.Properties["id"].Ref)
+	require.NotNil(t, owner.Value.Properties["id"].Value.Min)
+	assert.Equal(t, 1.0, *owner.Value.Properties["id"].Value.Min)
+}
+
+func TestLoadOpenAPISchemaWithRecursiveRefs(t *testing.T) {
+	doc, _, err := LoadOpenAPISchema("testdata/oas/input/recursive/openapi.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+	})
+	require.NoError(t, err, "cycles are kept as named references instead of failing")
+
+	schemas := doc.Components.Schemas
+	assert.Equal(t, []string{"Category", "Even", "Forest", "Node", "Odd", "Tree"}, collectSchemas(schemas))
+
+	// Self references between components stay references
+	assert.Equal(t, "#/components/schemas/Node", schemas["Node"].Value.Properties["children"].Value.Items.Ref)
+	assert.Equal(t, "#/components/schemas/Category", schemas["Category"].Value.Properties["parent"].Ref)
+	assert.Equal(t, "#/components/schemas/Category", schemas["Category"].Value.Properties["grandparent"].Ref)
+
+	// A recursive external schema is referenced through its component
+	tree := schemas["Tree"]
+	assert.Empty(t, tree.Ref)
+	children := tree.Value.Properties["children"].Value.Items
+	assert.Equal(t, "#/components/schemas/Tree", children.Ref)
+	require.NotNil(t, children.Value)
+	assert.Contains(t, children.Value.Properties, "label")
+	assert.Equal(t, "#/components/schemas/Tree", schemas["Forest"].Value.Properties["trees"].Value.Items.Ref)
+
+	// A cycle through schemas that are not components adds every schema of the cycle as a component
+	even := schemas["Forest"].Value.Properties["even"]
+	assert.Equal(t, "#/components/schemas/Even", even.Ref)
+	odd := schemas["Even"].Value.Properties["next"]
+	assert.Equal(t, "#/components/schemas/Odd", odd.Ref)
+	assert.Equal(t, "#/components/schemas/Even", odd.Value.Properties["next"].Ref)
+	assert.Equal(t, "#/components/schemas/Even", schemas["Odd"].Value.Properties["next"].Ref)
+}
+
+func TestGenerateRecursiveSchemas(t *testing.T) {
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/recursive/openapi.yaml", LoadOptions{
+		FlattenSpec: true,
+		MaxDepth:    10,
+	})
+	require.NoError(t, err)
+
+	outputDir := t.TempDir()
+	require.NoError(t, GenerateKCLSchemas(doc, outputDir, "recursive", version, nil))
+
+	node, err := os.ReadFile(filepath.Join(outputDir, "Node.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(node), "\n    children?: [Node]\n")
+
+	tree, err := os.ReadFile(filepath.Join(outputDir, "Tree.k"))
+	require.NoError(t, err)
+	assert.Contains(t, string(tree), "\n    children?: [Tree]\n")
+
+	// Schemas of a cycle keep their names and refer to each other
+	even, err := os.ReadFile(filepath.Join(outputDir, "Even.k"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(even)), "next?: Odd"))
+	odd, err := os.ReadFile(filepath.Join(outputDir, "Odd.k"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(odd)), "next?: Even"))
+	assert.NoFileExists(t, filepath.Join(outputDir, "EvenNext.k"))
+}
@@ -0,0 +1,275 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Objects without properties of their own are maps. Their values are typed by additionalProperties
+// and patternProperties, so they become {str:T} in KCL. Objects with properties that allow extra keys
+// get an index signature such as [...str]: T. Which keys are allowed follows the OpennessPolicy:
+// strict schemas only allow what additionalProperties, patternProperties or unevaluatedProperties
+// explicitly allow, spec-faithful schemas allow any key unless one of them is false.
+// patternProperties also restrict the keys of a map unless additionalProperties allows any other key.
+
+// mapType returns the KCL type of a map with the given value type
+func mapType(valueType string) string {
+	return "{str:" + valueType + "}"
+}
+
+// indexSignature returns a KCL index signature for the extra keys of a schema.
+// KCL requires the attribute types to fit the index signature, so it falls back to any when they do not.
+func indexSignature(valueType string, attributeTypes []string) string {
+	for _, attributeType := range attributeTypes {
+		if strings.TrimSuffix(attributeType, " | None") != valueType {
+			valueType = "any"
+			break
+		}
+	}
+	return fmt.Sprintf("[...str]: %s", valueType)
+}
+
+// patternKeysCheck returns a check that every key of a map matches one of the patterns
+func patternKeysCheck(fieldName string, patterns []string, isRequired bool) string {
+	matches := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		matches[i] = fmt.Sprintf("regex.match(_k, r\"%s\")", pattern)
+	}
+
+	check := fmt.Sprintf("all _k in %s { %s }", fieldName, strings.Join(matches, " or "))
+	if !isRequired {
+		check += fmt.Sprintf(" if %s != None", fieldName)
+	}
+	return check + fmt.Sprintf(", \"%s keys must match %s\"", fieldName, strings.Join(patterns, " or "))
+}
+
+// openAPIPatternProperties returns the patternProperties of an OpenAPI schema, which kin-openapi keeps as an extension
+func openAPIPatternProperties(schema *openapi3.Schema, doc *openapi3.T) openapi3.Schemas {
+	raw, ok := schema.Extensions["patternProperties"].(map[string]interface{})
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+
+	patterns := make(openapi3.Schemas, len(raw))
+	for pattern, value := range raw {
+		schemaRef, err := extensionSchema(value, doc)
+		if err != nil {
+			log.Printf("warning: failed to read patternProperties %q: %v", pattern, err)
+			continue
+		}
+		patterns[pattern] = schemaRef
+	}
+	return patterns
+}
+
+// extensionSchema reads a schema kin-openapi keeps in an extension
+func extensionSchema(value interface{}, doc *openapi3.T) (*openapi3.SchemaRef, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	schemaRef := &openapi3.SchemaRef{}
+	if err := json.Unmarshal(data, schemaRef); err != nil {
+		return nil, err
+	}
+	resolveComponentRefs(schemaRef, doc)
+	return schemaRef, nil
+}
+
+// resolveComponentRefs looks up the references in a schema read from an extension, which kin-openapi does not
+// resolve, in the components. Unknown references accept any value.
+func resolveComponentRefs(schemaRef *openapi3.SchemaRef, doc *openapi3.T) {
+	if schemaRef == nil {
+		return
+	}
+	if schemaRef.Ref != "" && schemaRef.Value == nil {
+		if component, ok := componentSchemas(doc)[extractSchemaName(schemaRef.Ref)]; ok && component != nil {
+			schemaRef.Value = component.Value
+		} else {
+			schemaRef.Value = &openapi3.Schema{}
+		}
+		return
+	}
+	if schemaRef.Value == nil {
+		return
+	}
+
+	schema := schemaRef.Value
+	for _, prop := range schema.Properties {
+		resolveComponentRefs(prop, doc)
+	}
+	for _, members := range []openapi3.SchemaRefs{schema.AllOf, schema.AnyOf, schema.OneOf} {
+		for _, member := range members {
+			resolveComponentRefs(member, doc)
+		}
+	}
+	resolveComponentRefs(schema.Items, doc)
+	resolveComponentRefs(schema.Not, doc)
+	resolveComponentRefs(schema.AdditionalProperties.Schema, doc)
+}
+
+// openAPIAllowsExtraKeys reports whether additionalProperties allows keys other than the properties and patterns
+func openAPIAllowsExtraKeys(schema *openapi3.Schema) bool {
+	return schema.AdditionalProperties.Schema != nil || (schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has)
+}
+
+// isOpenAPIMapSchema reports whether an OpenAPI schema is a map, which is generated as a type alias
+func isOpenAPIMapSchema(schema *openapi3.Schema, doc *openapi3.T) bool {
+	if schema == nil || len(schema.Properties) > 0 || len(schema.AllOf) > 0 {
+		return false
+	}
+	if schema.Type != nil && len(*schema.Type) > 0 && !schema.Type.Is("object") {
+		return false
+	}
+	return openAPIAllowsExtraKeys(schema) || len(openAPIPatternProperties(schema, doc)) > 0
+}
+
+// openAPIMapValueType returns the KCL type of the extra values of an OpenAPI object, or false if it has none
+func openAPIMapValueType(fieldName string, schema *openapi3.Schema, schemaName string, doc *openapi3.T, opts GenerateOptions) (string, bool) {
+	var valueTypes []string
+	patterns := openAPIPatternProperties(schema, doc)
+	for _, pattern := range collectSchemas(patterns) {
+		valueType, _, _ := generateFieldType(fieldName, patterns[pattern], true, schemaName, doc, opts)
+		valueTypes = append(valueTypes, valueType)
+	}
+	if schema.AdditionalProperties.Schema != nil {
+		valueType, _, _ := generateFieldType(fieldName, schema.AdditionalProperties.Schema, true, schemaName, doc, opts)
+		valueTypes = append(valueTypes, valueType)
+	} else if openAPIAllowsExtraKeys(schema) {
+		valueTypes = append(valueTypes, "any")
+	}
+
+	if len(valueTypes) == 0 {
+		return "", false
+	}
+	return unionOf(valueTypes), true
+}
+
+// openAPIExtraValueType returns the KCL type of the undeclared keys of an OpenAPI object schema,
+// or false if the schema is closed under the openness policy
+func openAPIExtraValueType(name string, schema *openapi3.Schema, doc *openapi3.T, opts GenerateOptions) (string, bool) {
+	if valueType, ok := openAPIMapValueType(name, schema, name, doc, opts); ok {
+		return valueType, true
+	}
+	if schema.AdditionalProperties.Has != nil {
+		return "", false // additionalProperties: false
+	}
+
+	// kin-openapi keeps the OpenAPI 3.1 unevaluatedProperties keyword as an extension
+	switch unevaluated := schema.Extensions["unevaluatedProperties"].(type) {
+	case bool:
+		return "any", unevaluated
+	case map[string]interface{}:
+		if data, err := json.Marshal(unevaluated); err == nil {
+			valueSchema := &openapi3.SchemaRef{}
+			if err := json.Unmarshal(data, valueSchema); err == nil && valueSchema.Value != nil {
+				valueType, _, _ := generateFieldType(name, valueSchema, true, name, doc, opts)
+				return valueType, true
+			}
+		}
+		return "any", true
+	}
+
+	return "any", opts.Openness == OpennessSpec
+}
+
+// openAPIPatternKeysCheck returns a key check for a map field with patternProperties, or an empty string
+func openAPIPatternKeysCheck(fieldName string, schema *openapi3.Schema, doc *openapi3.T, isRequired bool) string {
+	patterns := openAPIPatternProperties(schema, doc)
+	if len(patterns) == 0 || len(schema.Properties) > 0 || openAPIAllowsExtraKeys(schema) {
+		return ""
+	}
+	return patternKeysCheck(fieldName, collectSchemas(patterns), isRequired)
+}
+
+// jsonPatternProperties returns the patternProperties of a JSON Schema sorted by pattern
+func jsonPatternProperties(schema *jsonschema.Schema) ([]string, []*jsonschema.Schema) {
+	var patterns []string
+	byPattern := make(map[string]*jsonschema.Schema, len(schema.PatternProperties))
+	for pattern, patternSchema := range schema.PatternProperties {
+		patterns = append(patterns, pattern.String())
+		byPattern[pattern.String()] = patternSchema
+	}
+	sort.Strings(patterns)
+
+	schemas := make([]*jsonschema.Schema, len(patterns))
+	for i, pattern := range patterns {
+		schemas[i] = byPattern[pattern]
+	}
+	return patterns, schemas
+}
+
+// jsonAllowsExtraKeys reports whether additionalProperties allows keys other than the properties and patterns
+func jsonAllowsExtraKeys(schema *jsonschema.Schema) bool {
+	switch additional := schema.AdditionalProperties.(type) {
+	case bool:
+		return additional
+	case *jsonschema.Schema:
+		return additional != nil
+	default:
+		return false
+	}
+}
+
+// isJSONMapSchema reports whether a JSON Schema is an object without properties whose values are described
+func isJSONMapSchema(schema *jsonschema.Schema) bool {
+	if len(schema.Properties) > 0 || (len(schema.Types) > 0 && !containsType(schema.Types, "object")) {
+		return false
+	}
+	return len(schema.PatternProperties) > 0 || jsonAllowsExtraKeys(schema)
+}
+
+// mapValueType returns the KCL type of the extra values of a JSON Schema object, or false if it has none
+func (ctx *jsonSchemaContext) mapValueType(schema *jsonschema.Schema) (string, bool) {
+	_, patternSchemas := jsonPatternProperties(schema)
+	var valueTypes []string
+	for _, patternSchema := range patternSchemas {
+		valueTypes = append(valueTypes, ctx.typeToKCL(patternSchema))
+	}
+	if additional, ok := schema.AdditionalProperties.(*jsonschema.Schema); ok && additional != nil {
+		valueTypes = append(valueTypes, ctx.typeToKCL(additional))
+	} else if jsonAllowsExtraKeys(schema) {
+		valueTypes = append(valueTypes, "any")
+	}
+
+	if len(valueTypes) == 0 {
+		return "", false
+	}
+	return unionOf(valueTypes), true
+}
+
+// extraValueType returns the KCL type of the undeclared keys of a JSON Schema object,
+// or false if the schema is closed under the openness policy
+func (ctx *jsonSchemaContext) extraValueType(schema *jsonschema.Schema) (string, bool) {
+	if valueType, ok := ctx.mapValueType(schema); ok {
+		return valueType, true
+	}
+	if schema.AdditionalProperties != nil {
+		return "", false // additionalProperties: false
+	}
+
+	// Boolean schemas compile to schemas that always or never validate
+	if unevaluated := schema.UnevaluatedProperties; unevaluated != nil {
+		if unevaluated.Always != nil {
+			return "any", *unevaluated.Always
+		}
+		return ctx.typeToKCL(unevaluated), true
+	}
+
+	return "any", ctx.options().Openness == OpennessSpec
+}
+
+// jsonPatternKeysCheck returns a key check for a map field with patternProperties, or an empty string
+func jsonPatternKeysCheck(fieldName string, schema *jsonschema.Schema, isRequired bool) string {
+	if !isJSONMapSchema(schema) || jsonAllowsExtraKeys(schema) {
+		return ""
+	}
+	patterns, _ := jsonPatternProperties(schema)
+	return patternKeysCheck(fieldName, patterns, isRequired)
+}
@@ -0,0 +1,213 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSignature(t *testing.T) {
+	assert.Equal(t, "[...str]: str", indexSignature("str", []string{"str", "str | None"}))
+	assert.Equal(t, "[...str]: any", indexSignature("str", []string{"str", "int"}))
+	assert.Equal(t, "[...str]: int", indexSignature("int", nil))
+}
+
+func TestPatternKeysCheck(t *testing.T) {
+	assert.Equal(t,
+		`all _k in tags { regex.match(_k, r"^[a-z]+$") or regex.match(_k, r"^x-") }, "tags keys must match ^[a-z]+$ or ^x-"`,
+		patternKeysCheck("tags", []string{"^[a-z]+$", "^x-"}, true))
+	assert.Equal(t,
+		`all _k in tags { regex.match(_k, r"^x-") } if tags != None, "tags keys must match ^x-"`,
+		patternKeysCheck("tags", []string{"^x-"}, false))
+}
+
+func TestGenerateJSONMapSchemas(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string][]string
+		absent   map[string][]string
+	}{
+		{
+			name:  "Additional Properties",
+			input: "testdata/json/additional/input.json",
+			expected: map[string][]string{
+				"Schema": {"socket?: {str:int}"},
+			},
+		},
+		{
+			name:  "Pattern Properties",
+			input: "testdata/json/pattern-props/input.json",
+			expected: map[string][]string{
+				"Schema":        {"import regex", "authors?: {str:SchemaAuthors}", `all _k in authors { regex.match(_k, r"^[a-zA-Z]+$") } if authors != None`},
+				"SchemaAuthors": {"firstName?: str", "lastName?: str"},
+			},
+		},
+		{
+			name:  "Multiple Patterns With Additional Properties",
+			input: "testdata/json/unsupport-multi-pattern-props/input.json",
+			expected: map[string][]string{
+				"Schema":                {"authors?: {str:any}"},
+				"SchemaAuthorsPattern1": {"name?: str"},
+				"SchemaAuthorsPattern2": {"firstName?: str"},
+			},
+			// additionalProperties: true allows keys that match no pattern
+			absent: map[string][]string{
+				"Schema": {"all _k in authors"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := os.ReadFile(tc.input)
+			require.NoError(t, err)
+			var rawSchema map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &rawSchema))
+
+			tempDir := t.TempDir()
+			require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{}))
+
+			for name, fragments := range tc.expected {
+				content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+				require.NoError(t, err, "schema file %s should exist", name+".k")
+				for _, fragment := range fragments {
+					assert.Contains(t, string(content), fragment)
+				}
+				for _, fragment := range tc.absent[name] {
+					assert.NotContains(t, string(content), fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateJSONIndexSignatures(t *testing.T) {
+	rawSchema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "Config",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"tags": map[string]interface{}{
+				"type": "object",
+				"patternProperties": map[string]interface{}{
+					"^[a-z]+$": map[string]interface{}{"type": "string"},
+					"^x-":      map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+
+	tempDir := t.TempDir()
+	require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", GenerateOptions{}))
+	content, err := os.ReadFile(filepath.Join(tempDir, "Config.k"))
+	require.NoError(t, err)
+
+	// tags is not a string, so the index signature cannot be typed as str
+	assert.Contains(t, string(content), "[...str]: any")
+	assert.Contains(t, string(content), "tags?: {str:str | int}")
+	assert.Contains(t, string(content), `all _k in tags { regex.match(_k, r"^[a-z]+$") or regex.match(_k, r"^x-") } if tags != None`)
+}
+
+func TestGenerateOpenAPIMapSchemas(t *testing.T) {
+	tempDir := t.TempDir()
+
+	doc, version, err := LoadOpenAPISchema("testdata/oas/input/maps.yaml", LoadOptions{FlattenSpec: false})
+	require.NoError(t, err)
+	require.NoError(t, GenerateKCLSchemas(doc, tempDir, "test", version, nil))
+
+	readSchema := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(tempDir, name+".k"))
+		require.NoError(t, err, "schema file %s should exist", name+".k")
+		return string(content)
+	}
+
+	assert.Contains(t, readSchema("Labels"), "type Labels = {str:str}")
+
+	service := readSchema("Service")
+	assert.Contains(t, service, "labels?: Labels")
+	assert.Contains(t, service, "ports?: {str:int}")
+	assert.Contains(t, service, "endpoints?: {str:ServiceEndpoints}")
+	assert.Contains(t, service, "annotations?: {str:str | int}")
+	assert.NotContains(t, service, "# Circular reference")
+	assert.Contains(t, service, "import regex")
+	assert.Contains(t, service, `all _k in annotations { regex.match(_k, r"^[a-z]+$") or regex.match(_k, r"^x-") } if annotations != None`)
+	assert.NotContains(t, service, "[...str]")
+
+	// Inline map values are hoisted under the property name
+	assert.Contains(t, readSchema("ServiceEndpoints"), "url?: str")
+
+	assert.Contains(t, readSchema("Extensible"), "[...str]: str")
+}
+
+func TestOpennessPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		keywords  map[string]interface{}
+		openness  OpennessPolicy
+		signature string // empty when the schema must stay closed
+	}{
+		{name: "Strict Default", openness: OpennessStrict},
+		{name: "Spec Default", openness: OpennessSpec, signature: "[...str]: any"},
+		{name: "Spec Additional False", keywords: map[string]interface{}{"additionalProperties": false}, openness: OpennessSpec},
+		{name: "Spec Unevaluated False", keywords: map[string]interface{}{"unevaluatedProperties": false}, openness: OpennessSpec},
+		{name: "Strict Unevaluated True", keywords: map[string]interface{}{"unevaluatedProperties": true}, openness: OpennessStrict, signature: "[...str]: any"},
+		{name: "Strict Unevaluated Schema", keywords: map[string]interface{}{"unevaluatedProperties": map[string]interface{}{"type": "string"}}, openness: OpennessStrict, signature: "[...str]: str"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := GenerateOptions{Openness: tt.openness}
+			check := func(content string) {
+				if tt.signature == "" {
+					assert.NotContains(t, content, "[...str]")
+				} else {
+					assert.Contains(t, content, tt.signature)
+				}
+			}
+
+			rawSchema := map[string]interface{}{
+				"$schema":    "https://json-schema.org/draft/2020-12/schema",
+				"title":      "Config",
+				"type":       "object",
+				"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+			}
+			for key, value := range tt.keywords {
+				rawSchema[key] = value
+			}
+			tempDir := t.TempDir()
+			require.NoError(t, generateJSONSchemas(rawSchema, tempDir, "test", opts))
+			content, err := os.ReadFile(filepath.Join(tempDir, "Config.k"))
+			require.NoError(t, err)
+			check(string(content))
+
+			schemaJSON, err := json.Marshal(rawSchema)
+			require.NoError(t, err)
+			schemaRef := &openapi3.SchemaRef{}
+			require.NoError(t, json.Unmarshal(schemaJSON, schemaRef))
+			result, err := GenerateKCLSchemaWithOptions("Config", schemaRef, openapi3.Schemas{}, OpenAPIV31, nil, opts)
+			require.NoError(t, err)
+			check(result)
+		})
+	}
+}
+
+func TestParseOpennessPolicy(t *testing.T) {
+	policy, err := ParseOpennessPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, OpennessStrict, policy)
+
+	policy, err = ParseOpennessPolicy("spec")
+	require.NoError(t, err)
+	assert.Equal(t, OpennessSpec, policy)
+
+	_, err = ParseOpennessPolicy("open")
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package openapikcl
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// References are inlined when they are flattened, which cannot terminate for recursive schemas such as
+// Node.children: [Node]. A reference met again while it is being resolved closes a cycle, so it is kept
+// as a reference to a named component instead, as are the other references of the cycle. Targets that are not component schemas yet, such as
+// schemas of other documents, are added as components. The cycle is reported rather than treated as an error.
+// When bundling, every schema of another document is added as a component the same way.
+
+// componentTarget is the target of a reference kept as a reference to a component
+type componentTarget struct {
+	name string
+	refs []*openapi3.SchemaRef // references waiting for the flattened target
+}
+
+// recursiveRef returns a named reference for a reference that closes a cycle
+func (f *Flattener) recursiveRef(key string) *openapi3.SchemaRef {
+	log.Printf("circular reference detected: %s; keeping it as a named reference",
+		strings.Join(append(f.refPath, key), " -> "))
+
+	// The other schemas of the cycle keep their names too, rather than being inlined into their target,
+	// unless dereferencing. Pointers into the document other than component schemas do not name a schema.
+	for i := len(f.refPath) - 1; i >= 0 && f.refPath[i] != key && f.opts.Mode != BundleModeDereference; i-- {
+		if document, _, _ := strings.Cut(f.refPath[i], "#"); document != "" || isComponentSchemaRef(f.refPath[i]) {
+			f.componentTarget(f.refPath[i])
+		}
+	}
+
+	target := f.componentTarget(key)
+	ref := &openapi3.SchemaRef{Ref: "#/components/schemas/" + target.name}
+	target.refs = append(target.refs, ref)
+	return ref
+}
+
+// completeRecursiveRef hands the flattened target of a reference to the references that closed a cycle on it.
+// It returns a named reference if the target is part of a cycle, or nil if it can be inlined.
+func (f *Flattener) completeRecursiveRef(key string, flat *openapi3.SchemaRef) *openapi3.SchemaRef {
+	target, ok := f.targets[key]
+	if !ok || flat == nil {
+		return nil
+	}
+	return f.completeTarget(target, flat)
+}
+
+// bundledRef adds the flattened schema of another document as a component and returns a reference to it
+func (f *Flattener) bundledRef(key string, flat *openapi3.SchemaRef) *openapi3.SchemaRef {
+	if flat == nil {
+		return nil
+	}
+	target := f.componentTarget(key)
+	if _, ok := f.addedSchemas[target.name]; !ok {
+		log.Printf("bundling %s as component %s", key, target.name)
+	}
+	return f.completeTarget(target, flat)
+}
+
+// completeTarget records the flattened schema of a target and returns a reference to its component
+func (f *Flattener) completeTarget(target *componentTarget, flat *openapi3.SchemaRef) *openapi3.SchemaRef {
+	for _, ref := range target.refs {
+		ref.Value = flat.Value
+	}
+	target.refs = nil
+	f.addedSchemas[target.name] = &openapi3.SchemaRef{Value: flat.Value}
+	return &openapi3.SchemaRef{Ref: "#/components/schemas/" + target.name, Value: flat.Value}
+}
+
+// componentTarget returns the component target of a reference, naming it when it is first seen
+func (f *Flattener) componentTarget(key string) *componentTarget {
+	target, ok := f.targets[key]
+	if !ok {
+		target = &componentTarget{name: f.componentName(key)}
+		f.targets[key] = target
+	}
+	return target
+}
+
+// componentName names the component a reference points to. Components of the document keep their name,
+// other targets are named after the last token of their pointer, or after their file.
+func (f *Flattener) componentName(key string) string {
+	if name, ok := f.componentRefs[key]; ok {
+		return name
+	}
+
+	document, fragment, _ := strings.Cut(key, "#")
+	tokens, err := parseJSONPointer(fragment)
+	if err == nil && document == "" && isComponentSchemaRef(key) {
+		return tokens[2]
+	}
+
+	name := "Schema"
+	base := path.Base(document)
+	if err == nil && len(tokens) > 0 {
+		if candidate := pascalCaseName(tokens[len(tokens)-1]); candidate != "" {
+			name = candidate
+		}
+	} else if candidate := pascalCaseName(strings.TrimSuffix(base, path.Ext(base))); candidate != "" {
+		name = candidate
+	}
+	return uniqueSchemaName(name, func(candidate string) bool {
+		if f.doc != nil && f.doc.Components != nil && f.doc.Components.Schemas[candidate] != nil {
+			return true
+		}
+		for _, target := range f.targets {
+			if target.name == candidate {
+				return true
+			}
+		}
+		return false
+	})
+}
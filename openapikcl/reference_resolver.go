@@ -1,32 +1,37 @@
 package openapikcl
 
 import (
+	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
 )
 
-// resolveReference resolves an external reference
+// resolveReference resolves a local, file or URL reference against the document containing it
 func (f *Flattener) resolveReference(ref string) (*openapi3.SchemaRef, error) {
+	key := f.refKey(ref)
+
 	// Check for circular references with path
-	if f.seenRefs[ref] {
-		path := strings.Join(append(f.refPath, ref), " -> ")
+	if f.seenRefs[key] {
+		path := strings.Join(append(f.refPath, key), " -> ")
 		return nil, fmt.Errorf("circular reference detected: %s", path)
 	}
-	f.seenRefs[ref] = true
-	f.refPath = append(f.refPath, ref)
+	f.seenRefs[key] = true
+	f.refPath = append(f.refPath, key)
 	defer func() {
-		delete(f.seenRefs, ref)
+		delete(f.seenRefs, key)
 		f.refPath = f.refPath[:len(f.refPath)-1]
 	}()
 
 	// Check cache first
-	if cached, ok := f.cache[ref]; ok {
-		log.Printf("using cached reference for %s from %s", ref, cached.source)
+	if cached, ok := f.cache[key]; ok {
+		log.Printf("using cached reference for %s from %s", key, cached.source)
 		return cached.schema, nil
 	}
 
@@ -37,140 +42,246 @@ func (f *Flattener) resolveReference(ref string) (*openapi3.SchemaRef, error) {
 	}
 	defer func() { f.depth-- }()
 
-	log.Printf("resolving reference: %s", ref)
+	log.Printf("resolving reference: %s", key)
+
+	resolved, err := f.resolveTarget(ref)
+	if err != nil || resolved == nil {
+		return resolved, err
+	}
+
+	// Every later reference to the same target reuses the flattened schema
+	f.cache[key] = refContext{schema: resolved, source: f.documentName()}
+	return resolved, nil
+}
 
-	// Handle different reference types
-	switch {
-	case isLocalRef(ref):
+// resolveTarget resolves a reference by its kind
+func (f *Flattener) resolveTarget(ref string) (*openapi3.SchemaRef, error) {
+	if isLocalRef(ref) {
 		return f.resolveLocalRef(ref)
-	case isFileRef(ref):
-		return f.resolveFileRef(ref)
-	case isURLRef(ref):
-		if f.opts.SkipRemote {
-			log.Printf("skipping remote reference: %s", ref)
-			return nil, nil
+	}
+
+	// Other documents are resolved relative to the document containing the reference
+	target, err := f.resolveURI(ref)
+	if err != nil {
+		return nil, err
+	}
+	switch target.Scheme {
+	case "file":
+		return f.resolveFileRef(target)
+	case "http", "https":
+		if _, mirrored := f.opts.Catalog.Lookup(target.String()); !mirrored {
+			if f.opts.Offline {
+				return nil, fmt.Errorf("remote reference %s is not in the catalog and offline mode is set", target)
+			}
+			if f.opts.SkipRemote {
+				log.Printf("skipping remote reference: %s", target)
+				return nil, nil
+			}
 		}
-		return f.resolveURLRef(ref)
+		return f.resolveURLRef(target)
 	default:
 		return nil, fmt.Errorf("unsupported reference format: %s", ref)
 	}
 }
 
-// resolveLocalRef resolves references within the same document (#/components/...)
-func (f *Flattener) resolveLocalRef(ref string) (*openapi3.SchemaRef, error) {
-	if !strings.HasPrefix(ref, "#") {
-		return nil, fmt.Errorf("invalid local reference format: %s", ref)
+// documentName names the document references are currently resolved in
+func (f *Flattener) documentName() string {
+	if f.base != nil {
+		return f.base.String()
 	}
+	return "root document"
+}
 
-	// Remove the '#' prefix
-	path := strings.TrimPrefix(ref, "#")
+// skippedRef stands in for the schema of a remote reference that is skipped. It accepts any value
+// and names the reference in its x-skipped-ref extension.
+func skippedRef(ref string) *openapi3.SchemaRef {
+	return &openapi3.SchemaRef{Value: &openapi3.Schema{Extensions: map[string]interface{}{skippedRefExtension: ref}}}
+}
 
-	// Split the path into components
-	components := strings.Split(path, "/")
+// baseURI returns the URI references of the current document resolve against.
+// The root document is represented by its directory.
+func (f *Flattener) baseURI() *url.URL {
+	if f.base != nil {
+		return f.base
+	}
+	dir, err := filepath.Abs(f.opts.BaseDir)
+	if err != nil {
+		dir = f.opts.BaseDir
+	}
+	return &url.URL{Scheme: "file", Path: strings.TrimSuffix(filepath.ToSlash(dir), "/") + "/"}
+}
 
-	// Remove empty components
-	var parts []string
-	for _, part := range components {
-		if part != "" {
-			parts = append(parts, part)
-		}
+// resolveURI resolves a reference against the base URI of the current document
+func (f *Flattener) resolveURI(ref string) (*url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %s: %w", ref, err)
 	}
+	return f.baseURI().ResolveReference(u), nil
+}
 
-	// Navigate through the document structure
-	var current interface{} = f.doc
-	for i, part := range parts {
-		switch v := current.(type) {
-		case *openapi3.T:
-			switch part {
-			case "components":
-				current = v.Components
-			default:
-				return nil, fmt.Errorf("unsupported path component at position %d: %s", i, part)
-			}
-		case *openapi3.Components:
-			switch part {
-			case "schemas":
-				current = v.Schemas
-			default:
-				return nil, fmt.Errorf("unsupported components section at position %d: %s", i, part)
-			}
-		case openapi3.Schemas:
-			schema, ok := v[part]
-			if !ok {
-				return nil, fmt.Errorf("schema not found: %s", part)
-			}
-			return schema, nil
-		default:
-			return nil, fmt.Errorf("unexpected type at position %d: %T", i, current)
-		}
+// refKey identifies a reference across documents. Local references of the root document keep their form.
+func (f *Flattener) refKey(ref string) string {
+	if f.base == nil && isLocalRef(ref) {
+		return ref
+	}
+	if target, err := f.resolveURI(ref); err == nil {
+		return target.String()
 	}
+	return ref
+}
 
-	return nil, fmt.Errorf("invalid reference path: %s", ref)
+// resolveLocalRef resolves JSON Pointer references within the same document, e.g. #/components/schemas/Pet
+func (f *Flattener) resolveLocalRef(ref string) (*openapi3.SchemaRef, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("invalid local reference format: %s", ref)
+	}
+	if f.raw != nil {
+		return f.resolveRawRef(ref)
+	}
+	if f.doc == nil {
+		return nil, fmt.Errorf("no document to resolve %s in", ref)
+	}
+	return f.resolveDocumentPointer(f.doc, ref)
 }
 
 // resolveFileRef resolves references to other files
-func (f *Flattener) resolveFileRef(ref string) (*openapi3.SchemaRef, error) {
-	// Split the reference into file path and fragment
-	parts := strings.SplitN(ref, "#", 2)
-	filePath := filepath.Join(f.opts.BaseDir, parts[0])
+func (f *Flattener) resolveFileRef(target *url.URL) (*openapi3.SchemaRef, error) {
+	log.Printf("resolving file reference: %s", target.Path)
+	return f.resolveExternalRef(target)
+}
 
-	log.Printf("resolving file reference: %s", filePath)
+// resolveURLRef resolves references to remote URLs
+func (f *Flattener) resolveURLRef(target *url.URL) (*openapi3.SchemaRef, error) {
+	log.Printf("resolving URL reference: %s", target)
+	return f.resolveExternalRef(target)
+}
 
-	// Load the referenced file
-	loader := openapi3.NewLoader()
-	doc, err := loader.LoadFromFile(filePath)
+// resolveExternalRef loads the document a reference points to and flattens the schema at its fragment.
+// References inside that document resolve against its own location.
+func (f *Flattener) resolveExternalRef(target *url.URL) (*openapi3.SchemaRef, error) {
+	document := *target
+	document.Fragment = ""
+	document.RawFragment = ""
+
+	raw, err := f.parseDocument(&document)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load referenced file %s: %w", filePath, err)
+		return nil, err
 	}
+	return f.child(&document, raw).resolveRawRef("#" + target.EscapedFragment())
+}
 
-	// If there's a fragment, resolve it
-	if len(parts) > 1 {
-		fragment := "#" + parts[1]
-		// Create a temporary flattener for the loaded document
-		tempFlattener := NewFlattener(f.opts, doc)
-		return tempFlattener.resolveLocalRef(fragment)
+// parseDocument decodes a referenced document, parsing each document only once
+func (f *Flattener) parseDocument(location *url.URL) (interface{}, error) {
+	if raw, ok := f.parsed[location.String()]; ok {
+		return raw, nil
 	}
 
-	return nil, fmt.Errorf("file reference must include a fragment identifier: %s", ref)
-}
+	data, err := f.readDocument(location)
+	if err != nil {
+		return nil, err
+	}
 
-// resolveURLRef resolves references to remote URLs
-func (f *Flattener) resolveURLRef(ref string) (*openapi3.SchemaRef, error) {
-	// Split the reference into URL and fragment
-	parts := strings.SplitN(ref, "#", 2)
-	url := parts[0]
+	// Try JSON first, then fall back to YAML
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse referenced document %s: not a valid JSON or YAML document: %w", location, err)
+		}
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("referenced document %s is empty", location)
+	}
 
-	log.Printf("resolving URL reference: %s", url)
+	f.parsed[location.String()] = raw
+	return raw, nil
+}
 
-	// Make HTTP request
-	resp, err := f.httpClient.Get(url)
+// resolveRawRef resolves a JSON Pointer in the external document of f and flattens the schema it points to
+func (f *Flattener) resolveRawRef(fragment string) (*openapi3.SchemaRef, error) {
+	tokens, err := parseJSONPointer(fragment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch remote reference %s: %w", url, err)
+		return nil, err
+	}
+	node, err := resolveRawPointer(f.raw, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s in %s: %w", fragment, f.base, err)
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	data, err := json.Marshal(node)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read schema %s in %s: %w", fragment, f.base, err)
 	}
+	schema := &openapi3.SchemaRef{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("%s in %s is not a schema: %w", fragment, f.base, err)
+	}
+	return f.flattenSchemaRef(schema)
+}
 
-	// Load the referenced document
-	loader := openapi3.NewLoader()
-	doc, err := loader.LoadFromData(body)
+// child returns a flattener for an external document that shares the caches and reference tracking of f
+func (f *Flattener) child(base *url.URL, raw interface{}) *Flattener {
+	child := *f
+	child.base = base
+	child.raw = raw
+	return &child
+}
+
+// readDocument returns the contents of a file or remote document
+func (f *Flattener) readDocument(location *url.URL) ([]byte, error) {
+	switch location.Scheme {
+	case "", "file":
+		path := filepath.FromSlash(location.Path)
+		if err := f.checkInsideRoot(path); err != nil {
+			return nil, err
+		}
+		return f.readFile(path)
+	case "http", "https":
+		// Mirrors in the catalog are read instead of fetching the document
+		if path, ok := f.opts.Catalog.Lookup(location.String()); ok {
+			log.Printf("reading %s from its mirror %s", location, path)
+			return f.readFile(path)
+		}
+		if f.opts.Offline {
+			return nil, fmt.Errorf("remote reference %s is not in the catalog and offline mode is set", location)
+		}
+		if f.opts.SkipRemote {
+			return nil, fmt.Errorf("remote reference skipped: %s", location)
+		}
+		return f.fetchDocument(location.String())
+	default:
+		return nil, fmt.Errorf("unsupported reference scheme %q: %s", location.Scheme, location)
+	}
+}
+
+// readFile returns the contents of a referenced file, reading each file only once
+func (f *Flattener) readFile(path string) ([]byte, error) {
+	key := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	if body, ok := f.documents[key]; ok {
+		return body, nil
+	}
+	body, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse remote reference %s: %w", url, err)
+		return nil, fmt.Errorf("failed to load referenced file %s: %w", path, err)
+	}
+	f.documents[key] = body
+	return body, nil
+}
+
+// fetchDocument returns the contents of a remote document, fetching each URL only once
+func (f *Flattener) fetchDocument(url string) ([]byte, error) {
+	if body, ok := f.documents[url]; ok {
+		log.Printf("using cached document for %s", url)
+		return body, nil
 	}
 
-	// If there's a fragment, resolve it
-	if len(parts) > 1 {
-		fragment := "#" + parts[1]
-		// Create a temporary flattener for the loaded document
-		tempFlattener := NewFlattener(f.opts, doc)
-		return tempFlattener.resolveLocalRef(fragment)
+	body, err := f.fetcher.Fetch(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote reference %s: %w", url, err)
 	}
 
-	return nil, fmt.Errorf("URL reference must include a fragment identifier: %s", ref)
+	f.documents[url] = body
+	return body, nil
 }
 
 // cacheReferences logs information about the cached references
@@ -0,0 +1,61 @@
+package openapikcl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// File references are confined to a root directory, by default the directory of the input, so a spec
+// cannot read arbitrary files through "../" or absolute paths. A reference whose path is inside the root
+// but resolves through a symlink to a file outside it is refused as well. Catalog mirrors are configured
+// by the user and are not confined. AllowOutsideRoot lifts the restriction for trusted inputs.
+
+// rootDir returns the absolute directory file references must stay inside
+func (f *Flattener) rootDir() string {
+	root := f.opts.RootDir
+	if root == "" {
+		root = f.opts.BaseDir
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		return abs
+	}
+	return filepath.Clean(root)
+}
+
+// checkInsideRoot returns an error if a referenced file lies outside the root directory,
+// either by its path or by the symlinks it resolves through
+func (f *Flattener) checkInsideRoot(path string) error {
+	if f.opts.AllowOutsideRoot {
+		return nil
+	}
+
+	root := f.rootDir()
+	path = filepath.Clean(path)
+	if !isInsideDir(root, path) {
+		return fmt.Errorf("file reference %s is outside the root directory %s", path, root)
+	}
+
+	// Files that do not exist fail when they are read
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	if !isInsideDir(realRoot, realPath) {
+		return fmt.Errorf("file reference %s resolves through a symlink to %s, outside the root directory %s", path, realPath, root)
+	}
+	return nil
+}
+
+// isInsideDir reports whether path is dir or lies below it. Both must be absolute and clean.
+func isInsideDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,113 @@
+package openapikcl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSandbox creates a spec directory inside a temporary directory that also holds a secret schema.
+// The Pet component of the spec refers to the reference returned by ref for the temporary directory.
+// It returns the temporary directory and the spec path.
+func writeSandbox(t *testing.T, ref func(dir string) string) (string, string) {
+	dir := t.TempDir()
+	specDir := filepath.Join(dir, "spec")
+	require.NoError(t, os.MkdirAll(specDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.yaml"), []byte("Secret:\n  type: string\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(specDir, "pet.yaml"), []byte("Pet:\n  type: integer\n"), 0644))
+
+	spec := `openapi: 3.0.3
+info:
+  title: Sandbox
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Pet:
+      $ref: '` + ref(dir) + `'
+`
+	specPath := filepath.Join(specDir, "openapi.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(spec), 0644))
+	return dir, specPath
+}
+
+func TestFileRefsInsideRoot(t *testing.T) {
+	_, specPath := writeSandbox(t, func(string) string { return "./pet.yaml#/Pet" })
+	doc, _, err := LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "integer", doc.Components.Schemas["Pet"].Value.Type.Slice()[0])
+}
+
+func TestFileRefsOutsideRoot(t *testing.T) {
+	secret := func(dir string) string { return filepath.ToSlash(filepath.Join(dir, "secret.yaml")) }
+	tests := []struct {
+		name string
+		ref  func(dir string) string
+	}{
+		{name: "parent directory", ref: func(string) string { return "../secret.yaml#/Secret" }},
+		{name: "absolute path", ref: func(dir string) string { return secret(dir) + "#/Secret" }},
+		{name: "file URL", ref: func(dir string) string { return "file://" + secret(dir) + "#/Secret" }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, specPath := writeSandbox(t, tc.ref)
+
+			_, _, err := LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10})
+			assert.ErrorContains(t, err, "is outside the root directory")
+
+			// A root containing the file allows it
+			doc, _, err := LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10, RootDir: dir})
+			require.NoError(t, err)
+			assert.Equal(t, "string", doc.Components.Schemas["Pet"].Value.Type.Slice()[0])
+
+			// So does opting out for trusted inputs
+			_, _, err = LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10, AllowOutsideRoot: true})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFileRefsThroughSymlink(t *testing.T) {
+	dir, specPath := writeSandbox(t, func(string) string { return "./link.yaml#/Secret" })
+	if err := os.Symlink(filepath.Join(dir, "secret.yaml"), filepath.Join(filepath.Dir(specPath), "link.yaml")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	_, _, err := LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10})
+	assert.ErrorContains(t, err, "resolves through a symlink")
+
+	_, _, err = LoadOpenAPISchema(specPath, LoadOptions{FlattenSpec: true, MaxDepth: 10, AllowOutsideRoot: true})
+	require.NoError(t, err)
+}
+
+func TestGenerateJSONFileRefsOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	schemaDir := filepath.Join(dir, "schema")
+	require.NoError(t, os.MkdirAll(schemaDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{"type": "string"}`), 0644))
+
+	rawSchema := map[string]interface{}{
+		"title": "Item",
+		"type":  "object",
+		"properties": map[string]interface{}{
+			"secret": map[string]interface{}{"$ref": "../secret.json"},
+		},
+	}
+	data, err := json.Marshal(rawSchema)
+	require.NoError(t, err)
+	inputPath := filepath.Join(schemaDir, "item.json")
+	require.NoError(t, os.WriteFile(inputPath, data, 0644))
+
+	err = generateJSONSchemas(rawSchema, t.TempDir(), "test", GenerateOptions{SourcePath: inputPath})
+	assert.ErrorContains(t, err, "is outside the root directory")
+
+	require.NoError(t, generateJSONSchemas(rawSchema, t.TempDir(), "test", GenerateOptions{
+		SourcePath: inputPath,
+		Flatten:    FlattenOptions{RootDir: dir},
+	}))
+}
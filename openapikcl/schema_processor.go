@@ -2,6 +2,7 @@ package openapikcl
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
 )
@@ -14,11 +15,59 @@ func (f *Flattener) flattenSchemaRef(ref *openapi3.SchemaRef) (*openapi3.SchemaR
 
 	// Handle direct reference
 	if ref.Ref != "" {
+		// A reference met again while it is being resolved is recursive and stays a reference
+		key := f.refKey(ref.Ref)
+		if f.seenRefs[key] {
+			return f.recursiveRef(key), nil
+		}
+
 		// Instead of just resolving the reference, preserve the reference information
 		resolved, err := f.resolveReference(ref.Ref)
 		if err != nil {
 			return nil, err
 		}
+		if resolved == nil {
+			return skippedRef(key), nil
+		}
+		if recursive := f.completeRecursiveRef(key, resolved); recursive != nil {
+			return recursive, nil
+		}
+
+		// Schemas of other documents are referenced through the component consisting of them
+		if name, ok := f.componentRefs[key]; ok && resolved != nil && f.opts.Mode != BundleModeDereference {
+			return &openapi3.SchemaRef{Ref: "#/components/schemas/" + name, Value: resolved.Value}, nil
+		}
+
+		// Schemas of other documents were flattened when they were resolved, so they are inlined,
+		// or added as components when bundling
+		if f.raw != nil || !isLocalRef(ref.Ref) {
+			if f.opts.Mode == BundleModeBundle {
+				return f.bundledRef(key, resolved), nil
+			}
+			return resolved, nil
+		}
+
+		// Pointers into other parts of the document do not name a schema, so their target is inlined,
+		// as is every target when dereferencing. A target that is itself a reference is followed as one.
+		if (!isComponentSchemaRef(ref.Ref) || f.opts.Mode == BundleModeDereference) && resolved != nil {
+			target := &openapi3.SchemaRef{Value: resolved.Value}
+			if resolved.Ref != "" {
+				target = &openapi3.SchemaRef{Ref: resolved.Ref}
+			}
+
+			f.seenRefs[key] = true
+			f.refPath = append(f.refPath, key)
+			flat, err := f.flattenSchemaRef(target)
+			delete(f.seenRefs, key)
+			f.refPath = f.refPath[:len(f.refPath)-1]
+			if err != nil {
+				return nil, err
+			}
+			if recursive := f.completeRecursiveRef(key, flat); recursive != nil {
+				return recursive, nil
+			}
+			return flat, nil
+		}
 
 		// Create a new schema reference that has both the resolved value and the original reference
 		flatSchema := &openapi3.SchemaRef{
@@ -69,12 +118,12 @@ func (f *Flattener) flattenSchemaRef(ref *openapi3.SchemaRef) (*openapi3.SchemaR
 		}
 
 		flatSchema.Value.Required = requiredProps
+	}
 
-		// Validate that all required properties exist
-		for _, reqProp := range flatSchema.Value.Required {
-			if _, ok := flatSchema.Value.Properties[reqProp]; !ok {
-				return nil, fmt.Errorf("required property %q not found in schema", reqProp)
-			}
+	// Validate that all required properties exist, in the schema or one of its allOf members
+	for _, reqProp := range allOfRequired(flatSchema.Value) {
+		if !declaresProperty(flatSchema.Value, reqProp, make(map[*openapi3.Schema]bool)) {
+			return nil, fmt.Errorf("required property %q not found in schema", reqProp)
 		}
 	}
 
@@ -101,23 +150,32 @@ func (f *Flattener) flattenSchemaRef(ref *openapi3.SchemaRef) (*openapi3.SchemaR
 	copySchemaMetadata(&schema, flatSchema.Value)
 
 	// Handle discriminator
-	if schema.Discriminator != nil {
-		// Validate discriminator property exists
-		if schema.Properties == nil || schema.Properties[schema.Discriminator.PropertyName] == nil {
+	if discriminator := schema.Discriminator; discriminator != nil {
+		// Validate discriminator property exists; for oneOf/anyOf it is defined by the branches
+		hasBranches := len(schema.OneOf) > 0 || len(schema.AnyOf) > 0
+		if !hasBranches && (schema.Properties == nil || schema.Properties[discriminator.PropertyName] == nil) {
 			return nil, fmt.Errorf("discriminator property %q not found in schema properties",
-				schema.Discriminator.PropertyName)
+				discriminator.PropertyName)
 		}
 
-		// Copy discriminator
+		// Copy discriminator (flatSchema.Value aliases schema, so read the original via discriminator)
 		flatSchema.Value.Discriminator = &openapi3.Discriminator{
-			PropertyName: schema.Discriminator.PropertyName,
+			PropertyName: discriminator.PropertyName,
 			Mapping:      make(map[string]string),
 		}
 
-		// Validate and copy mapping references
-		for key, ref := range schema.Discriminator.Mapping {
-			if _, err := f.resolveReference(ref); err != nil {
-				return nil, fmt.Errorf("invalid discriminator mapping %q -> %q: %w", key, ref, err)
+		// Validate and copy mapping references. A mapping to a schema being flattened, such as the schema
+		// itself or a subtype extending it, closes a cycle and keeps the name of its target.
+		for _, key := range sortedKeys(discriminator.Mapping) {
+			ref := discriminator.Mapping[key]
+			// Mapping values may name a component schema instead of referencing it
+			if !strings.Contains(ref, "/") && !strings.Contains(ref, "#") {
+				ref = "#/components/schemas/" + ref
+			}
+			if refKey := f.refKey(ref); f.seenRefs[refKey] {
+				ref = f.recursiveRef(refKey).Ref
+			} else if _, err := f.resolveReference(ref); err != nil {
+				return nil, fmt.Errorf("discriminator mapping %q -> %q: %w", key, ref, err)
 			}
 			flatSchema.Value.Discriminator.Mapping[key] = ref
 		}
@@ -146,40 +204,30 @@ func (f *Flattener) flattenCompositeSchemas(schema *openapi3.Schema) error {
 	// Handle allOf
 	if len(schema.AllOf) > 0 {
 		// Flatten each schema in allOf
+		// Inline members may require properties declared by other members, so their required
+		// properties are validated along with those of the composed schema
 		flatAllOf := make([]*openapi3.SchemaRef, 0, len(schema.AllOf))
 		for _, s := range schema.AllOf {
-			flat, err := f.flattenSchemaRef(s)
-			if err != nil {
-				return fmt.Errorf("failed to flatten allOf schema: %w", err)
+			member := s
+			var required []string
+			if s != nil && s.Ref == "" && s.Value != nil {
+				value := *s.Value
+				required, value.Required = value.Required, nil
+				member = &openapi3.SchemaRef{Extensions: s.Extensions, Value: &value}
 			}
-			flatAllOf = append(flatAllOf, flat)
-		}
-
-		// Merge the flattened schemas
-		merged, err := mergeSchemas(flatAllOf)
-		if err != nil {
-			return fmt.Errorf("failed to merge allOf schemas: %w", err)
-		}
 
-		// Copy merged properties to the parent schema
-		if merged.Value.Properties != nil {
-			if schema.Properties == nil {
-				schema.Properties = make(openapi3.Schemas)
-			}
-			for name, prop := range merged.Value.Properties {
-				schema.Properties[name] = prop
+			flat, err := f.flattenSchemaRef(member)
+			if err != nil {
+				return fmt.Errorf("failed to flatten allOf schema: %w", err)
 			}
-		}
-
-		// Merge required fields
-		for _, req := range merged.Value.Required {
-			if req != "" {
-				schema.Required = append(schema.Required, req)
+			if required != nil {
+				flat.Value.Required = required
 			}
+			flatAllOf = append(flatAllOf, flat)
 		}
 
-		// Clear the allOf array since we've merged its contents
-		schema.AllOf = nil
+		// Members are kept, so schemas referenced as parents stay visible to the generator
+		schema.AllOf = flatAllOf
 	}
 
 	// Handle oneOf
@@ -211,6 +259,34 @@ func (f *Flattener) flattenCompositeSchemas(schema *openapi3.Schema) error {
 	return nil
 }
 
+// allOfRequired returns the required properties of a schema and its inline allOf members
+func allOfRequired(schema *openapi3.Schema) []string {
+	required := appendUnique(nil, schema.Required...)
+	for _, member := range schema.AllOf {
+		if member != nil && member.Ref == "" && member.Value != nil {
+			required = appendUnique(required, allOfRequired(member.Value)...)
+		}
+	}
+	return required
+}
+
+// declaresProperty reports whether a schema or one of its allOf members declares a property
+func declaresProperty(schema *openapi3.Schema, name string, visited map[*openapi3.Schema]bool) bool {
+	if schema == nil || visited[schema] {
+		return false
+	}
+	visited[schema] = true
+	if _, ok := schema.Properties[name]; ok {
+		return true
+	}
+	for _, member := range schema.AllOf {
+		if member != nil && declaresProperty(member.Value, name, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 // copySchemaMetadata copies metadata from source schema to target schema
 func copySchemaMetadata(source *openapi3.Schema, target *openapi3.Schema) {
 	// Copy basic metadata